@@ -1,55 +1,98 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/joho/godotenv"
+	fiberlogger "github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/tracing"
 )
 
-func Start() {
+// runServe runs the HTTP server until it is interrupted
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "serve [--port port]")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
 
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatal(err)
-		return
+	logger, logFile, err := newLogger(cfg.Log)
+	if err != nil {
+		return err
 	}
+	defer logFile.Close()
 
-	app := fiber.New()
+	// tracing is set up before Init builds the MongoDB client
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("could not flush spans", "error", err)
+		}
+	}()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler(logger),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	})
+	app.Use(middleware.AssignRequestID())
+	app.Use(middleware.Trace())
 	app.Use(cors.New())
-	file, err := os.OpenFile("app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	file, err := os.OpenFile(cfg.HTTP.AccessLog, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		return fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
-	app.Use(logger.New(logger.Config{
-		Format:     "${time} | ${status} | ${latency} | ${ip} | ${method} | ${error} | ${pid} ${status} - ${method} ${path}\n",
+	app.Use(fiberlogger.New(fiberlogger.Config{
+		Format:     "${time} | ${locals:requestid} | ${status} | ${latency} | ${ip} | ${method} | ${error} | ${pid} ${status} - ${method} ${path}\n",
 		TimeFormat: "02-Jan-2006",
 		TimeZone:   "Local",
 		Output:     file,
 	}))
-	Init(app)
+	r, h := Init(app, cfg, logger)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go sweepHolds(ctx, r.Bookings(), r.Refunds(), cfg.Booking.SweepInterval)
+	go rotateKeys(ctx, r.Keys(), keyRefreshInterval)
 
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, os.Interrupt)
+		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 		<-sigint
+		stop()
 
-		if err := app.Shutdown(); err != nil {
+		// fail readiness first so load balancers drain this instance
+		h.ShutDown()
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+
+		if err := app.ShutdownWithTimeout(cfg.HTTP.ShutdownTimeout); err != nil {
 			log.Printf("Oops... Server is not shutting down! Reason: %v", err)
 		}
 
 		close(idleConnsClosed)
 	}()
 
-	if err := app.Listen(":" + os.Getenv("PORT")); err != nil {
+	if err := app.Listen(":" + cfg.HTTP.Port); err != nil {
 		log.Printf("Oops... Server is not running! Reason: %v", err)
+		return err
 	}
 
 	<-idleConnsClosed
-
+	return nil
 }
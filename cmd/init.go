@@ -1,36 +1,128 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
 	"github.com/tabed23/travel-api/database"
+	"github.com/tabed23/travel-api/health"
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/logging"
+	"github.com/tabed23/travel-api/mail"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/migrations"
+	"github.com/tabed23/travel-api/payments"
+	"github.com/tabed23/travel-api/ratelimit"
+	"github.com/tabed23/travel-api/repository/store"
 	"github.com/tabed23/travel-api/routes"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func Init(app *fiber.App) {
+// newLogger returns the service logger writing to the configured file and
+// stderr, the caller closes the returned file
+func newLogger(cfg config.Log) (*slog.Logger, *os.File, error) {
+	file, err := os.OpenFile(cfg.File, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening file: %w", err)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	logHandler := &slog.HandlerOptions{Level: level}
+	multiWriter := io.MultiWriter(file, os.Stderr)
+	var handler slog.Handler = slog.NewTextHandler(multiWriter, logHandler)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(multiWriter, logHandler)
+	}
+	return slog.New(logging.NewHandler(handler)), file, nil
+}
+
+// newKeyring returns the keyring of the token signing keys in s, it has no
+// keys until rotated
+func newKeyring(cfg *config.Config, s store.SigningKeyRepository) *keyring.Keyring {
+	policy := keyring.Policy{
+		Algorithm:   cfg.JWT.Algorithm,
+		RotateEvery: cfg.JWT.RotateEvery,
+		Overlap:     cfg.JWT.RotationOverlap,
+		TokenTTL:    max(cfg.JWT.AccessTTL, cfg.MFA.PendingTTL),
+	}
+	return keyring.New(s, policy, cfg.JWT.Secret)
+}
+
+// migrateOnStart applies pending migrations in "auto" mode, the default,
+// refuses to start with pending migrations in "strict" mode and does
+// nothing in "off" mode
+func migrateOnStart(db *mongo.Database, mode string, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	runner := migrations.NewRunner(db, logger)
+	switch mode {
+	case "auto":
+		_, err := runner.Up(ctx)
+		return err
+	case "strict":
+		return runner.Check(ctx)
+	case "off":
+		return nil
+	}
+	return fmt.Errorf("unknown MIGRATIONS_MODE %q, want auto, strict or off", mode)
+}
 
-	dbClient, err := database.NewDatabase(os.Getenv("MONGO_URL"))
+// Init connects the dependencies and registers every route, the returned
+// Health is marked as shutting down by the caller
+func Init(app *fiber.App, cfg *config.Config, logger *slog.Logger) (*routes.Routes, *health.Health) {
+
+	dbClient, err := database.NewDatabase(cfg.Mongo)
 	if err != nil {
 		log.Fatal(err)
-		return
+		return nil, nil
 	}
 	db := dbClient.GetDB()
-	file, err := os.OpenFile("service.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err := migrateOnStart(db, cfg.Migrations.Mode, logger); err != nil {
+		log.Fatal(err)
+		return nil, nil
+	}
+	gateway, err := payments.NewGateway(cfg.Payments.Provider, cfg.Payments.WebhookSecret)
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		log.Fatal(err)
+		return nil, nil
 	}
-	defer file.Close()
-	logHandler := &slog.HandlerOptions{}
-	multiWriter := io.MultiWriter(file, os.Stderr)
-	logger := slog.New(slog.NewTextHandler(multiWriter, logHandler))
-	r := routes.NewRoutes(db, logger)
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+		return nil, nil
+	}
+	m := metrics.New()
+	mongoStores := store.NewMongoStores(db, cfg, logger)
+	if cfg.RateLimit.Backend == "memory" {
+		mongoStores.RateLimits = ratelimit.NewMemory()
+	}
+	stores := store.Instrument(mongoStores, m)
+	keys := newKeyring(cfg, stores.SigningKeys)
+	if err := keys.Rotate(context.Background(), time.Now()); err != nil {
+		log.Fatal(err)
+		return nil, nil
+	}
+	r := routes.NewRoutes(cfg, stores, gateway, mailer, keys, m, logger)
+	h := health.New(cfg.HTTP.ReadyTimeout)
+	h.Register("mongo", dbClient.Ping)
+	r.HealthRoutes(app, h)
+	r.MetricsRoutes(app)
+	r.WellKnownRoutes(app)
 	r.TourRoutes(app)
 	r.UserRoutes(app)
 	r.AuthRoutes(app)
 	r.ReviewRoutes(app)
 	r.BookingRoutes(app)
+	r.PaymentRoutes(app)
+	return r, h
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tabed23/travel-api/database"
+	"github.com/tabed23/travel-api/migrations"
+)
+
+const migrateUsage = "migrate up | down [n] | status"
+
+// runMigrate applies, reverts or lists the schema migrations
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate", migrateUsage)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing migrate subcommand")
+	}
+
+	logger, logFile, err := newLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	dbClient, err := database.NewDatabase(cfg.Mongo)
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+	runner := migrations.NewRunner(dbClient.GetDB(), logger)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		n, err := runner.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		n, err := runner.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migration(s)\n", n)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Description, applied)
+		}
+		return w.Flush()
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate subcommand %q", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/database"
+	"github.com/tabed23/travel-api/repository/store"
+)
+
+const usage = `usage: travel-api <command> [arguments]
+
+commands:
+  serve                      run the HTTP server, the default
+  migrate up|down [n]|status apply, revert or list schema migrations
+  seed --fixtures dir        load demo data from JSON fixtures
+  user create-admin --email  create the first admin account
+  token issue --role         print an access token for scripts and tests
+`
+
+// command runs with the arguments following its name
+type command func(args []string) error
+
+var commands = map[string]command{
+	"serve":   runServe,
+	"migrate": runMigrate,
+	"seed":    runSeed,
+	"user":    runUser,
+	"token":   runToken,
+}
+
+// Execute runs the command named by args, the command line without the
+// program name, and returns the process exit code
+func Execute(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return 0
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+	if err := run(args); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet returns the flags of a command, errors are returned rather than
+// exiting so commands clean up after themselves
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: travel-api %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadConfig parses args with fs, which also gets the configuration flags,
+// and loads the configuration
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	loader := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return loader.Load()
+}
+
+// openStores connects to MongoDB and returns its stores and the logger with
+// a function releasing the connection and the log file
+func openStores(cfg *config.Config) (store.Stores, *slog.Logger, func(), error) {
+	logger, logFile, err := newLogger(cfg.Log)
+	if err != nil {
+		return store.Stores{}, nil, nil, err
+	}
+	dbClient, err := database.NewDatabase(cfg.Mongo)
+	if err != nil {
+		logFile.Close()
+		return store.Stores{}, nil, nil, err
+	}
+	release := func() {
+		dbClient.Close()
+		logFile.Close()
+	}
+	return store.NewMongoStores(dbClient.GetDB(), cfg, logger), logger, release, nil
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tabed23/travel-api/seed"
+)
+
+// runSeed loads demo data from a fixtures directory
+func runSeed(args []string) error {
+	fs := newFlagSet("seed", "seed --fixtures dir")
+	dir := fs.String("fixtures", "fixtures/demo", "directory holding users.json, tours.json, bookings.json and reviews.json")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	fixtures, err := seed.Load(*dir)
+	if err != nil {
+		return err
+	}
+	stores, logger, release, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+	sum, err := seed.Apply(context.Background(), stores, fixtures, logger)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %d user(s), %d tour(s), %d departure(s), %d booking(s), %d review(s), skipped %d existing\n",
+		sum.Users, sum.Tours, sum.Departures, sum.Bookings, sum.Reviews, sum.Skipped)
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tabed23/travel-api/controller"
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/repository/store"
+)
+
+// sweepHolds periodically expires the bookings whose seat hold ran out,
+// returning their seats to the departure inventory, and retries the refunds
+// the payment provider failed, until ctx is done
+func sweepHolds(ctx context.Context, bookings store.BookingRepository, refunds *controller.PaymentController, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := bookings.ExpireHolds(ctx, now.UTC()); err != nil {
+				log.Printf("Oops... could not expire booking holds! Reason: %v", err)
+			}
+			if _, err := refunds.RetryRefunds(ctx); err != nil {
+				log.Printf("Oops... could not retry the pending refunds! Reason: %v", err)
+			}
+		}
+	}
+}
+
+// keyRefreshInterval is how often the keyring is reloaded, it must stay well
+// below the rotation overlap so every instance knows a key before it signs
+const keyRefreshInterval = time.Minute
+
+// rotateKeys periodically reloads the signing keys, which other instances
+// may have created, and creates the next one when it is due, until ctx is
+// done
+func rotateKeys(ctx context.Context, keys *keyring.Keyring, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := keys.Rotate(ctx, now.UTC()); err != nil {
+				log.Printf("Oops... could not rotate the signing keys! Reason: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+const tokenUsage = "token issue --role role --email email"
+
+// runToken prints an access token signed with the current key of the
+// keyring, for scripts and manual testing. The token gets a session of its
+// own, the user sees and can sign it out like a login.
+func runToken(args []string) error {
+	if len(args) == 0 || args[0] != "issue" {
+		return fmt.Errorf("usage: travel-api %s", tokenUsage)
+	}
+	fs := newFlagSet("token issue", tokenUsage)
+	role := fs.String("role", constant.UserRole, "role claim of the token")
+	email := fs.String("email", "", "email claim of the token, required")
+	cfg, err := loadConfig(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if !constant.IsValidRole(*role) {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+	if *email == "" {
+		fs.Usage()
+		return fmt.Errorf("missing --email")
+	}
+	stores, _, release, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+	keys := newKeyring(cfg, stores.SigningKeys)
+	if err := keys.Rotate(context.Background(), time.Now()); err != nil {
+		return err
+	}
+	session, err := stores.Sessions.Create(context.Background(), models.Session{
+		UserEmail: *email,
+		UserAgent: "travel-api token issue",
+		ExpiresAt: time.Now().Add(cfg.JWT.AccessTTL).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	token, err := utils.NewJWT(keys, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.AccessTTL, cfg.JWT.RefreshTTL).GenrateNewToken(*role, *email, session.ID.Hex())
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
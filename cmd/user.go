@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+const userUsage = "user create-admin --email email [--password password] [--promote]"
+
+// runUser manages accounts, the API only lets admins create admins so the
+// first one is created here
+func runUser(args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		return fmt.Errorf("usage: travel-api %s", userUsage)
+	}
+	fs := newFlagSet("user create-admin", userUsage)
+	email := fs.String("email", "", "email of the admin, required")
+	password := fs.String("password", "", "password of the admin, generated and printed when empty")
+	firstName := fs.String("first-name", "Admin", "first name")
+	lastName := fs.String("last-name", "Admin", "last name")
+	userName := fs.String("user-name", "admin", "user name")
+	promote := fs.Bool("promote", false, "make an existing user with this email an admin")
+	cfg, err := loadConfig(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	generated := false
+	if *password == "" {
+		token, err := utils.GenerateRefreshToken()
+		if err != nil {
+			return err
+		}
+		*password, generated = token[:24], true
+	}
+	input := models.UserRegister{
+		FirstName: *firstName,
+		Lastname:  *lastName,
+		UserName:  *userName,
+		Email:     *email,
+		Password:  *password,
+	}
+	if err := validator.New().Struct(input); err != nil {
+		return err
+	}
+
+	stores, _, release, err := openStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx := context.Background()
+	usr := input.User()
+	usr.Role = constant.AdminRole
+	created, err := stores.Users.CreaterUser(ctx, usr)
+	if errors.Is(err, errors.ErrUserExists) && *promote {
+		if _, err := stores.Users.UpdateRole(ctx, *email, constant.AdminRole); err != nil {
+			return err
+		}
+		fmt.Printf("%v is now an admin\n", *email)
+		return nil
+	}
+	if errors.Is(err, errors.ErrUserExists) {
+		return fmt.Errorf("%v already exists, pass --promote to make it an admin", *email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created admin %v (%v)\n", created.Email, created.ID.Hex())
+	if generated {
+		fmt.Printf("password: %v\n", *password)
+	}
+	return nil
+}
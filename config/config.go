@@ -0,0 +1,491 @@
+// Package config loads the configuration of the service. Values come, from
+// lowest to highest precedence, from the defaults, an optional YAML or TOML
+// file, the environment, which is seeded from an optional .env file, and
+// command line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service
+type Config struct {
+	HTTP       HTTP       `yaml:"http" toml:"http"`
+	Mongo      Mongo      `yaml:"mongo" toml:"mongo"`
+	JWT        JWT        `yaml:"jwt" toml:"jwt"`
+	Log        Log        `yaml:"log" toml:"log"`
+	Payments   Payments   `yaml:"payments" toml:"payments"`
+	Booking    Booking    `yaml:"booking" toml:"booking"`
+	Migrations Migrations `yaml:"migrations" toml:"migrations"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	RateLimit  RateLimit  `yaml:"rate_limit" toml:"rate_limit"`
+	Lockout    Lockout    `yaml:"lockout" toml:"lockout"`
+	Mail       Mail       `yaml:"mail" toml:"mail"`
+	Account    Account    `yaml:"account" toml:"account"`
+	MFA        MFA        `yaml:"mfa" toml:"mfa"`
+	OIDC       OIDC       `yaml:"oidc" toml:"oidc"`
+}
+
+type HTTP struct {
+	Port            string        `yaml:"port" toml:"port" env:"PORT" flag:"port" usage:"port to listen on"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay is how long /readyz fails before the server stops
+	// accepting connections, so load balancers stop routing to it first
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY"`
+	// ReadyTimeout bounds each dependency check of /readyz
+	ReadyTimeout time.Duration `yaml:"ready_timeout" toml:"ready_timeout" env:"HTTP_READY_TIMEOUT"`
+	AccessLog    string        `yaml:"access_log" toml:"access_log" env:"ACCESS_LOG_FILE"`
+}
+
+type Mongo struct {
+	URL            string        `yaml:"url" toml:"url" env:"MONGO_URL" flag:"mongo-url" usage:"MongoDB connection string"`
+	Database       string        `yaml:"database" toml:"database" env:"MONGO_DATABASE" flag:"mongo-database" usage:"MongoDB database name"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"MONGO_CONNECT_TIMEOUT"`
+	// QueryTimeout bounds every store operation
+	QueryTimeout time.Duration `yaml:"query_timeout" toml:"query_timeout" env:"MONGO_QUERY_TIMEOUT"`
+}
+
+type JWT struct {
+	// Secret seals the private signing keys stored in MongoDB
+	Secret     string        `yaml:"secret" toml:"secret" env:"JWT_SECRET"`
+	AccessTTL  time.Duration `yaml:"access_ttl" toml:"access_ttl" env:"JWT_ACCESS_TTL"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"JWT_REFRESH_TTL"`
+	// Algorithm signs the tokens, RS256 or EdDSA. A change takes effect with
+	// a new key right away, the old keys keep verifying.
+	Algorithm string `yaml:"algorithm" toml:"algorithm" env:"JWT_ALGORITHM"`
+	Issuer    string `yaml:"issuer" toml:"issuer" env:"JWT_ISSUER"`
+	Audience  string `yaml:"audience" toml:"audience" env:"JWT_AUDIENCE"`
+	// RotateEvery is how long each signing key signs, RotationOverlap how
+	// long it is published in the JWKS before
+	RotateEvery     time.Duration `yaml:"rotate_every" toml:"rotate_every" env:"JWT_ROTATE_EVERY"`
+	RotationOverlap time.Duration `yaml:"rotation_overlap" toml:"rotation_overlap" env:"JWT_ROTATION_OVERLAP"`
+}
+
+type Log struct {
+	File  string `yaml:"file" toml:"file" env:"LOG_FILE"`
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"debug, info, warn or error"`
+	// Format is text for logfmt style records or json for one JSON object
+	// per record
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"text or json"`
+}
+
+type Payments struct {
+	Provider      string `yaml:"provider" toml:"provider" env:"PAYMENT_PROVIDER"`
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret" env:"PAYMENT_WEBHOOK_SECRET"`
+}
+
+type Booking struct {
+	HoldMinutes   int           `yaml:"hold_minutes" toml:"hold_minutes" env:"BOOKING_HOLD_MINUTES"`
+	SweepInterval time.Duration `yaml:"sweep_interval" toml:"sweep_interval" env:"BOOKING_SWEEP_INTERVAL"`
+}
+
+// HoldWindow returns how long new bookings hold their seats
+func (b Booking) HoldWindow() time.Duration {
+	return time.Duration(b.HoldMinutes) * time.Minute
+}
+
+type Migrations struct {
+	// Mode is auto to apply pending migrations at startup, strict to refuse
+	// starting while migrations are pending and off to skip both
+	Mode string `yaml:"mode" toml:"mode" env:"MIGRATIONS_MODE" flag:"migrations" usage:"auto, strict or off"`
+}
+
+type Tracing struct {
+	// Exporter is otlp to send spans to an OTLP/HTTP collector, stdout to
+	// print them or none to only propagate incoming trace context
+	Exporter    string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" flag:"tracing" usage:"otlp, stdout or none"`
+	Endpoint    string `yaml:"endpoint" toml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool   `yaml:"insecure" toml:"insecure" env:"TRACING_INSECURE"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+	// SampleRatio is the share of new traces that are recorded, traces
+	// started upstream follow the sampling decision of their parent
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// RateLimit holds the rate limit of each route group
+type RateLimit struct {
+	// Backend is memory to limit each instance on its own, mongo to share
+	// the limits between instances or off
+	Backend  string `yaml:"backend" toml:"backend" env:"RATE_LIMIT_BACKEND" flag:"rate-limit" usage:"memory, mongo or off"`
+	Auth     Rule   `yaml:"auth" toml:"auth" env:"RATE_LIMIT_AUTH"`
+	Tours    Rule   `yaml:"tours" toml:"tours" env:"RATE_LIMIT_TOURS"`
+	Users    Rule   `yaml:"users" toml:"users" env:"RATE_LIMIT_USERS"`
+	Reviews  Rule   `yaml:"reviews" toml:"reviews" env:"RATE_LIMIT_REVIEWS"`
+	Bookings Rule   `yaml:"bookings" toml:"bookings" env:"RATE_LIMIT_BOOKINGS"`
+	Payments Rule   `yaml:"payments" toml:"payments" env:"RATE_LIMIT_PAYMENTS"`
+}
+
+// Rule allows Requests per Window to each client, clients are told apart
+// by their Key. It is written like "10/1m by ip" or "off".
+type Rule struct {
+	Requests int
+	Window   time.Duration
+	// Key is ip, user for the email of the bearer token or api_key for the
+	// X-API-Key header, callers without one are limited by ip
+	Key string
+}
+
+// Off reports whether the rule limits nothing
+func (r Rule) Off() bool {
+	return r.Requests == 0
+}
+
+func (r Rule) String() string {
+	if r.Off() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%v by %v", r.Requests, r.Window, r.Key)
+}
+
+func (r Rule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rule) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "off" {
+		*r = Rule{}
+		return nil
+	}
+	rate, key, found := strings.Cut(s, " by ")
+	if !found {
+		key = "ip"
+	}
+	requests, window, ok := strings.Cut(strings.TrimSpace(rate), "/")
+	if !ok {
+		return fmt.Errorf("rate limit %q must look like 10/1m by ip", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return fmt.Errorf("rate limit %q must allow at least one request", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("rate limit %q must have a positive window", s)
+	}
+	switch key = strings.TrimSpace(key); key {
+	case "ip", "user", "api_key":
+	default:
+		return fmt.Errorf("rate limit %q must be by ip, user or api_key", s)
+	}
+	*r = Rule{Requests: n, Window: d, Key: key}
+	return nil
+}
+
+// Lockout throttles failed logins. After each failure of an email or IP its
+// next login is refused for Delay, doubled with every further failure, and
+// for Duration once it reached its maximum of failures.
+type Lockout struct {
+	MaxFailures   int           `yaml:"max_failures" toml:"max_failures" env:"LOCKOUT_MAX_FAILURES"`
+	IPMaxFailures int           `yaml:"ip_max_failures" toml:"ip_max_failures" env:"LOCKOUT_IP_MAX_FAILURES"`
+	Delay         time.Duration `yaml:"delay" toml:"delay" env:"LOCKOUT_DELAY"`
+	Duration      time.Duration `yaml:"duration" toml:"duration" env:"LOCKOUT_DURATION"`
+	// ForgetAfter is how long failures are counted after the last one
+	ForgetAfter time.Duration `yaml:"forget_after" toml:"forget_after" env:"LOCKOUT_FORGET_AFTER"`
+}
+
+type Mail struct {
+	// Driver is smtp to send mail, file to write it to OutboxDir or memory
+	// to only keep it in the process, the last two are for local testing
+	Driver    string `yaml:"driver" toml:"driver" env:"MAIL_DRIVER" flag:"mail" usage:"smtp, file or memory"`
+	From      string `yaml:"from" toml:"from" env:"MAIL_FROM"`
+	Host      string `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port      int    `yaml:"port" toml:"port" env:"SMTP_PORT"`
+	Username  string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password  string `yaml:"password" toml:"password" env:"SMTP_PASSWORD"`
+	OutboxDir string `yaml:"outbox_dir" toml:"outbox_dir" env:"MAIL_OUTBOX_DIR"`
+}
+
+type Account struct {
+	// PublicURL is where users reach the service, the links in mails point
+	// to it
+	PublicURL        string        `yaml:"public_url" toml:"public_url" env:"PUBLIC_URL"`
+	VerificationTTL  time.Duration `yaml:"verification_ttl" toml:"verification_ttl" env:"ACCOUNT_VERIFICATION_TTL"`
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl" env:"ACCOUNT_PASSWORD_RESET_TTL"`
+}
+
+type MFA struct {
+	// Issuer names the service in authenticator apps
+	Issuer string `yaml:"issuer" toml:"issuer" env:"MFA_ISSUER"`
+	// RequireAdmins makes admins without a second factor set one up at their
+	// next login before they get a token
+	RequireAdmins bool `yaml:"require_admins" toml:"require_admins" env:"MFA_REQUIRE_ADMINS"`
+	// PendingTTL is how long a login has to pass its second factor after the
+	// password check
+	PendingTTL    time.Duration `yaml:"pending_ttl" toml:"pending_ttl" env:"MFA_PENDING_TTL"`
+	RecoveryCodes int           `yaml:"recovery_codes" toml:"recovery_codes" env:"MFA_RECOVERY_CODES"`
+}
+
+type OIDC struct {
+	// StateTTL is how long users have to sign in at the provider
+	StateTTL time.Duration `yaml:"state_ttl" toml:"state_ttl" env:"OIDC_STATE_TTL"`
+	// Providers users may sign in with, their client secrets are best set in
+	// OIDC_<NAME>_CLIENT_SECRET
+	Providers []OIDCProvider `yaml:"providers" toml:"providers"`
+}
+
+// OIDCProvider is an OpenID Connect provider, signed in to with the
+// authorization code flow and PKCE
+type OIDCProvider struct {
+	// Name names the provider in the login routes
+	Name         string `yaml:"name" toml:"name"`
+	Issuer       string `yaml:"issuer" toml:"issuer"`
+	ClientID     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	// RedirectURL is the callback route of the provider, it must be
+	// registered at the provider
+	RedirectURL string `yaml:"redirect_url" toml:"redirect_url"`
+	// Scopes are requested besides openid, email and profile when empty
+	Scopes []string `yaml:"scopes" toml:"scopes"`
+}
+
+// SecretEnv returns the environment variable holding the client secret of p
+func (p OIDCProvider) SecretEnv() string {
+	return "OIDC_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_")) + "_CLIENT_SECRET"
+}
+
+var providerName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Default returns the configuration used for values no source sets
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Port:            "3000",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			ReadyTimeout:    2 * time.Second,
+			AccessLog:       "app.log",
+		},
+		Mongo: Mongo{
+			URL:            "mongodb://localhost:27017",
+			Database:       "travle",
+			ConnectTimeout: 10 * time.Second,
+			QueryTimeout:   10 * time.Second,
+		},
+		JWT: JWT{
+			AccessTTL:       30 * time.Minute,
+			RefreshTTL:      7 * 24 * time.Hour,
+			Algorithm:       "RS256",
+			Issuer:          "travel-api",
+			Audience:        "travel-api",
+			RotateEvery:     30 * 24 * time.Hour,
+			RotationOverlap: 24 * time.Hour,
+		},
+		Log: Log{
+			File:   "service.log",
+			Level:  "info",
+			Format: "text",
+		},
+		Payments: Payments{
+			Provider: "fake",
+		},
+		Booking: Booking{
+			HoldMinutes:   15,
+			SweepInterval: time.Minute,
+		},
+		Migrations: Migrations{
+			Mode: "auto",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			ServiceName: "travel-api",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimit{
+			Backend:  "memory",
+			Auth:     Rule{Requests: 10, Window: time.Minute, Key: "ip"},
+			Tours:    Rule{Requests: 300, Window: time.Minute, Key: "ip"},
+			Users:    Rule{Requests: 120, Window: time.Minute, Key: "user"},
+			Reviews:  Rule{Requests: 60, Window: time.Minute, Key: "user"},
+			Bookings: Rule{Requests: 60, Window: time.Minute, Key: "user"},
+			Payments: Rule{Requests: 60, Window: time.Minute, Key: "user"},
+		},
+		Lockout: Lockout{
+			MaxFailures:   5,
+			IPMaxFailures: 20,
+			Delay:         time.Second,
+			Duration:      15 * time.Minute,
+			ForgetAfter:   time.Hour,
+		},
+		Mail: Mail{
+			Driver:    "file",
+			From:      "travel-api <no-reply@localhost>",
+			Port:      587,
+			OutboxDir: "outbox",
+		},
+		Account: Account{
+			PublicURL:        "http://localhost:3000",
+			VerificationTTL:  48 * time.Hour,
+			PasswordResetTTL: time.Hour,
+		},
+		MFA: MFA{
+			Issuer:        "travel-api",
+			PendingTTL:    5 * time.Minute,
+			RecoveryCodes: 10,
+		},
+		OIDC: OIDC{
+			StateTTL: 10 * time.Minute,
+		},
+	}
+}
+
+// Validate reports every invalid value of c
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if c.JWT.Secret == "" {
+		invalid("jwt.secret (JWT_SECRET) is required")
+	}
+	if c.Payments.WebhookSecret == "" {
+		invalid("payments.webhook_secret (PAYMENT_WEBHOOK_SECRET) is required")
+	}
+	switch c.JWT.Algorithm {
+	case "RS256", "EdDSA":
+	default:
+		invalid("jwt.algorithm (JWT_ALGORITHM) must be RS256 or EdDSA, got %q", c.JWT.Algorithm)
+	}
+	if c.JWT.Issuer == "" || c.JWT.Audience == "" {
+		invalid("jwt.issuer (JWT_ISSUER) and jwt.audience (JWT_AUDIENCE) are required")
+	}
+	if c.JWT.RotationOverlap >= c.JWT.RotateEvery {
+		invalid("jwt.rotation_overlap (JWT_ROTATION_OVERLAP) must be shorter than jwt.rotate_every (JWT_ROTATE_EVERY)")
+	}
+	if c.Mongo.URL == "" {
+		invalid("mongo.url (MONGO_URL) is required")
+	}
+	if c.Mongo.Database == "" {
+		invalid("mongo.database (MONGO_DATABASE) is required")
+	}
+	if port, err := strconv.Atoi(c.HTTP.Port); err != nil || port < 1 || port > 65535 {
+		invalid("http.port (PORT) must be a port number, got %q", c.HTTP.Port)
+	}
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"http.read_timeout (HTTP_READ_TIMEOUT)", c.HTTP.ReadTimeout},
+		{"http.write_timeout (HTTP_WRITE_TIMEOUT)", c.HTTP.WriteTimeout},
+		{"http.idle_timeout (HTTP_IDLE_TIMEOUT)", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout (HTTP_SHUTDOWN_TIMEOUT)", c.HTTP.ShutdownTimeout},
+		{"http.ready_timeout (HTTP_READY_TIMEOUT)", c.HTTP.ReadyTimeout},
+		{"mongo.connect_timeout (MONGO_CONNECT_TIMEOUT)", c.Mongo.ConnectTimeout},
+		{"mongo.query_timeout (MONGO_QUERY_TIMEOUT)", c.Mongo.QueryTimeout},
+		{"jwt.access_ttl (JWT_ACCESS_TTL)", c.JWT.AccessTTL},
+		{"jwt.refresh_ttl (JWT_REFRESH_TTL)", c.JWT.RefreshTTL},
+		{"jwt.rotate_every (JWT_ROTATE_EVERY)", c.JWT.RotateEvery},
+		{"jwt.rotation_overlap (JWT_ROTATION_OVERLAP)", c.JWT.RotationOverlap},
+		{"booking.sweep_interval (BOOKING_SWEEP_INTERVAL)", c.Booking.SweepInterval},
+		{"lockout.delay (LOCKOUT_DELAY)", c.Lockout.Delay},
+		{"lockout.duration (LOCKOUT_DURATION)", c.Lockout.Duration},
+		{"lockout.forget_after (LOCKOUT_FORGET_AFTER)", c.Lockout.ForgetAfter},
+		{"account.verification_ttl (ACCOUNT_VERIFICATION_TTL)", c.Account.VerificationTTL},
+		{"account.password_reset_ttl (ACCOUNT_PASSWORD_RESET_TTL)", c.Account.PasswordResetTTL},
+		{"mfa.pending_ttl (MFA_PENDING_TTL)", c.MFA.PendingTTL},
+		{"oidc.state_ttl (OIDC_STATE_TTL)", c.OIDC.StateTTL},
+	}
+	for _, d := range durations {
+		if d.d <= 0 {
+			invalid("%v must be positive", d.name)
+		}
+	}
+	if c.HTTP.ShutdownDelay < 0 {
+		invalid("http.shutdown_delay (HTTP_SHUTDOWN_DELAY) must not be negative")
+	}
+	if c.Lockout.MaxFailures <= 0 || c.Lockout.IPMaxFailures <= 0 {
+		invalid("lockout.max_failures (LOCKOUT_MAX_FAILURES) and lockout.ip_max_failures (LOCKOUT_IP_MAX_FAILURES) must be positive")
+	}
+	if c.MFA.Issuer == "" {
+		invalid("mfa.issuer (MFA_ISSUER) is required")
+	}
+	if c.MFA.RecoveryCodes <= 0 {
+		invalid("mfa.recovery_codes (MFA_RECOVERY_CODES) must be positive")
+	}
+	names := map[string]bool{}
+	for i, p := range c.OIDC.Providers {
+		if !providerName.MatchString(p.Name) {
+			invalid("oidc.providers[%d].name must be lowercase letters, digits and dashes, got %q", i, p.Name)
+		} else if names[p.Name] {
+			invalid("oidc.providers[%d].name %q is used twice", i, p.Name)
+		}
+		names[p.Name] = true
+		if p.ClientID == "" {
+			invalid("oidc.providers[%d].client_id is required", i)
+		}
+		for _, u := range []struct{ name, value string }{{"issuer", p.Issuer}, {"redirect_url", p.RedirectURL}} {
+			if parsed, err := url.Parse(u.value); err != nil || !parsed.IsAbs() || parsed.Host == "" {
+				invalid("oidc.providers[%d].%v must be an absolute URL, got %q", i, u.name, u.value)
+			}
+		}
+	}
+	if c.Booking.HoldMinutes <= 0 {
+		invalid("booking.hold_minutes (BOOKING_HOLD_MINUTES) must be positive")
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		invalid("log.format (LOG_FORMAT) must be text or json, got %q", c.Log.Format)
+	}
+	switch c.Migrations.Mode {
+	case "auto", "strict", "off":
+	default:
+		invalid("migrations.mode (MIGRATIONS_MODE) must be auto, strict or off, got %q", c.Migrations.Mode)
+	}
+	switch c.Tracing.Exporter {
+	case "otlp", "stdout", "none":
+	default:
+		invalid("tracing.exporter (TRACING_EXPORTER) must be otlp, stdout or none, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+	}
+	switch c.RateLimit.Backend {
+	case "memory", "mongo", "off":
+	default:
+		invalid("rate_limit.backend (RATE_LIMIT_BACKEND) must be memory, mongo or off, got %q", c.RateLimit.Backend)
+	}
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.Host == "" {
+			invalid("mail.host (SMTP_HOST) is required for the smtp driver")
+		}
+		if c.Mail.Port < 1 || c.Mail.Port > 65535 {
+			invalid("mail.port (SMTP_PORT) must be a port number, got %d", c.Mail.Port)
+		}
+	case "file":
+		if c.Mail.OutboxDir == "" {
+			invalid("mail.outbox_dir (MAIL_OUTBOX_DIR) is required for the file driver")
+		}
+	case "memory":
+	default:
+		invalid("mail.driver (MAIL_DRIVER) must be smtp, file or memory, got %q", c.Mail.Driver)
+	}
+	if c.Mail.From == "" {
+		invalid("mail.from (MAIL_FROM) is required")
+	}
+	if u, err := url.Parse(c.Account.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		invalid("account.public_url (PUBLIC_URL) must be an absolute URL, got %q", c.Account.PublicURL)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
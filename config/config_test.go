@@ -0,0 +1,215 @@
+package config_test
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/config"
+)
+
+func TestRuleUnmarshalText(t *testing.T) {
+	tests := []struct {
+		text string
+		want config.Rule
+	}{
+		{"10/1m by ip", config.Rule{Requests: 10, Window: time.Minute, Key: "ip"}},
+		{"60/1h by user", config.Rule{Requests: 60, Window: time.Hour, Key: "user"}},
+		{" 5/30s by api_key ", config.Rule{Requests: 5, Window: 30 * time.Second, Key: "api_key"}},
+		{"10/1m", config.Rule{Requests: 10, Window: time.Minute, Key: "ip"}},
+		{"off", config.Rule{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got config.Rule
+			if err := got.UnmarshalText([]byte(tt.text)); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			// the rule reads back as it was written
+			var again config.Rule
+			if err := again.UnmarshalText([]byte(got.String())); err != nil || again != got {
+				t.Errorf("%q reads back as %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestRuleUnmarshalTextRefusals(t *testing.T) {
+	for _, text := range []string{"10 per minute", "0/1m by ip", "x/1m", "10/0s", "10/soon", "10/1m by email"} {
+		var r config.Rule
+		if err := r.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("%q: got %+v, want an error", text, r)
+		}
+	}
+}
+
+// unsetenv unsets name for the test, a .env file can only set variables
+// the environment does not have
+func unsetenv(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv(name, "")
+	os.Unsetenv(name)
+}
+
+// load loads the configuration of a command run with args
+func load(t *testing.T, args ...string) (*config.Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	l := config.NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	return l.Load()
+}
+
+// writeFile writes content to name in a directory of the test
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing %v: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	for _, name := range []string{"CONFIG_FILE", "PORT", "MONGO_DATABASE", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_SECRET", "PAYMENT_WEBHOOK_SECRET", "LOCKOUT_MAX_FAILURES"} {
+		unsetenv(t, name)
+	}
+	file := writeFile(t, "config.yaml", `
+http:
+  port: "4000"
+mongo:
+  database: file
+jwt:
+  secret: file-secret
+  issuer: file
+  audience: file
+lockout:
+  max_failures: 7
+payments:
+  webhook_secret: file-webhook-secret
+`)
+	dotenv := writeFile(t, ".env", "MONGO_DATABASE=dotenv\nJWT_ISSUER=dotenv\n")
+	t.Setenv("PORT", "5000")
+	t.Setenv("JWT_ISSUER", "env")
+	t.Setenv("JWT_AUDIENCE", "env")
+
+	cfg, err := load(t, "--config", file, "--env-file", dotenv, "--port", "6000")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"default", cfg.Mongo.URL, "mongodb://localhost:27017"},
+		{"file over default", cfg.Lockout.MaxFailures, 7},
+		{"file only", cfg.JWT.Secret, "file-secret"},
+		{".env over file", cfg.Mongo.Database, "dotenv"},
+		{"env over .env", cfg.JWT.Issuer, "env"},
+		{"env over file", cfg.JWT.Audience, "env"},
+		{"flag over env", cfg.HTTP.Port, "6000"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadRefusals(t *testing.T) {
+	unsetenv(t, "CONFIG_FILE")
+	t.Setenv("JWT_SECRET", "env-secret")
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "env-webhook-secret")
+	missing := filepath.Join(t.TempDir(), ".env")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unknown file key", []string{"--config", writeFile(t, "config.yaml", "http:\n  prot: \"4000\"\n")}, "prot"},
+		{"file format", []string{"--config", writeFile(t, "config.json", "{}")}, ".yaml, .yml or .toml"},
+		{"bad flag value", []string{"--port", "http"}, "http.port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, append([]string{"--env-file", missing}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+	t.Run("bad env value", func(t *testing.T) {
+		t.Setenv("LOCKOUT_MAX_FAILURES", "many")
+		if _, err := load(t, "--env-file", missing); err == nil || !strings.Contains(err.Error(), "LOCKOUT_MAX_FAILURES") {
+			t.Errorf("got %v, want an error about LOCKOUT_MAX_FAILURES", err)
+		}
+	})
+}
+
+// valid returns the default configuration with the secrets it lacks
+func valid() config.Config {
+	cfg := config.Default()
+	cfg.JWT.Secret = "jwt-secret"
+	cfg.Payments.WebhookSecret = "webhook-secret"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	cfg := valid()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid configuration: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*config.Config)
+		want   string
+	}{
+		{"no jwt secret", func(c *config.Config) { c.JWT.Secret = "" }, "jwt.secret"},
+		{"no webhook secret", func(c *config.Config) { c.Payments.WebhookSecret = "" }, "payments.webhook_secret"},
+		{"jwt algorithm", func(c *config.Config) { c.JWT.Algorithm = "HS256" }, "jwt.algorithm"},
+		{"overlap as long as the rotation", func(c *config.Config) { c.JWT.RotationOverlap = c.JWT.RotateEvery }, "jwt.rotation_overlap"},
+		{"port", func(c *config.Config) { c.HTTP.Port = "70000" }, "http.port"},
+		{"zero duration", func(c *config.Config) { c.Account.PasswordResetTTL = 0 }, "account.password_reset_ttl"},
+		{"negative shutdown delay", func(c *config.Config) { c.HTTP.ShutdownDelay = -time.Second }, "http.shutdown_delay"},
+		{"migrations mode", func(c *config.Config) { c.Migrations.Mode = "sometimes" }, "migrations.mode"},
+		{"sample ratio", func(c *config.Config) { c.Tracing.SampleRatio = 2 }, "tracing.sample_ratio"},
+		{"rate limit backend", func(c *config.Config) { c.RateLimit.Backend = "redis" }, "rate_limit.backend"},
+		{"oidc provider", func(c *config.Config) {
+			c.OIDC.Providers = []config.OIDCProvider{{Name: "Google", ClientID: "id", Issuer: "https://accounts.google.com", RedirectURL: "/callback"}}
+		}, "oidc.providers[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := config.Default()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("the defaults validate without the secrets")
+	}
+	for _, want := range []string{"jwt.secret", "payments.webhook_secret"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%v, want an error about %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Loader reads the configuration from every source, it is bound to the flag
+// set of a command
+type Loader struct {
+	fs      *flag.FlagSet
+	file    *string
+	dotenv  *string
+	flagged map[string]*string
+}
+
+// NewLoader registers the configuration flags on fs, which the caller
+// parses before calling Load
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:      fs,
+		file:    fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file"),
+		dotenv:  fs.String("env-file", ".env", "file of environment variables, skipped when missing"),
+		flagged: map[string]*string{},
+	}
+	cfg := Default()
+	walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if name := f.Tag.Get("flag"); name != "" {
+			usage := fmt.Sprintf("%v (default %v)", f.Tag.Get("usage"), v.Interface())
+			l.flagged[name] = fs.String(name, "", usage)
+		}
+	})
+	return l
+}
+
+// Load returns the validated configuration
+func (l *Loader) Load() (*Config, error) {
+	if !l.fs.Parsed() {
+		return nil, fmt.Errorf("config: flags must be parsed before loading")
+	}
+	if err := godotenv.Load(*l.dotenv); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("%v: %w", *l.dotenv, err)
+	}
+	cfg := Default()
+	if *l.file != "" {
+		if err := decodeFile(*l.file, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	set := map[string]bool{}
+	l.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if err != nil {
+			return
+		}
+		if name := f.Tag.Get("env"); name != "" {
+			if value, ok := os.LookupEnv(name); ok && value != "" {
+				if e := setValue(v, value); e != nil {
+					err = fmt.Errorf("%v: %w", name, e)
+					return
+				}
+			}
+		}
+		if name := f.Tag.Get("flag"); set[name] {
+			if e := setValue(v, *l.flagged[name]); e != nil {
+				err = fmt.Errorf("--%v: %w", name, e)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range cfg.OIDC.Providers {
+		if value, ok := os.LookupEnv(p.SecretEnv()); ok && value != "" {
+			cfg.OIDC.Providers[i].ClientSecret = value
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// decodeFile reads path into cfg, rejecting keys cfg does not have so typos
+// do not go unnoticed
+func decodeFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%v: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("%v: configuration files must be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// walk calls fn for every leaf field of the struct v, structs parsed from
+// text like Rule are leaves
+func walk(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		f, fv := v.Type().Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct && !reflect.PointerTo(f.Type).Implements(textUnmarshalerType) {
+			walk(fv, fn)
+			continue
+		}
+		fn(f, fv)
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setValue parses s into v
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %v", v.Type())
+		}
+		parts := []string{}
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/mail"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// AccountPolicy says where the links of account mails point and how long
+// their tokens work, see config.Account
+type AccountPolicy struct {
+	PublicURL        string
+	VerificationTTL  time.Duration
+	PasswordResetTTL time.Duration
+}
+
+// AccountController mails single-use tokens to verify email addresses and
+// reset passwords, and consumes them
+type AccountController struct {
+	users    store.UserRepository
+	tokens   store.UserTokenRepository
+	sessions *SessionController
+	mailer   mail.Mailer
+	policy   AccountPolicy
+	logger   *slog.Logger
+}
+
+func NewAccountController(u store.UserRepository, t store.UserTokenRepository, s *SessionController, m mail.Mailer, p AccountPolicy, l *slog.Logger) *AccountController {
+	return &AccountController{users: u, tokens: t, sessions: s, mailer: m, policy: p, logger: l}
+}
+
+// link returns the page of the public URL at path that takes token
+func (a *AccountController) link(path, token string) string {
+	return strings.TrimSuffix(a.policy.PublicURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// newToken stores a token of purpose for email and returns it, the store
+// only keeps its hash
+func (a *AccountController) newToken(ctx context.Context, purpose, email string, ttl time.Duration) (string, error) {
+	raw, err := utils.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	token := models.UserToken{
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(raw),
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	}
+	if _, err := a.tokens.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// SendVerification mails user the link to verify their email address
+func (a *AccountController) SendVerification(ctx context.Context, user models.User) error {
+	raw, err := a.newToken(ctx, models.TokenEmailVerification, user.Email, a.policy.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := a.link("/verify-email", raw)
+	return a.mailer.Send(ctx, mail.VerificationMessage(user.Email, link, a.policy.VerificationTTL))
+}
+
+// RequestVerification mails the caller a new verification link
+func (a *AccountController) RequestVerification(c *fiber.Ctx) error {
+	user, err := a.users.GetUserByEmail(c.UserContext(), middleware.GetClaims(c).Email)
+	if err != nil {
+		return err
+	}
+	if !user.Unverified {
+		return errors.ErrEmailVerified
+	}
+	if err := a.SendVerification(c.UserContext(), user); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{"success": "verification mail sent"})
+}
+
+// Verify marks the email address of the token verified
+func (a *AccountController) Verify(c *fiber.Ctx) error {
+	var input models.VerifyEmail
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	token, err := a.tokens.Consume(c.UserContext(), models.TokenEmailVerification, utils.HashToken(input.Token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	user, err := a.users.MarkVerified(c.UserContext(), token.Email)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrInvalidAccountToken
+	}
+	if err != nil {
+		return err
+	}
+	a.logger.InfoContext(c.UserContext(), "email verified", "email", user.Email)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "email verified", "data": user})
+}
+
+// ForgotPassword mails a password reset link to the account of the email.
+// It answers the same whether the email has an account or not, and mails
+// in the background so the response time does not tell either.
+func (a *AccountController) ForgotPassword(c *fiber.Ctx) error {
+	var input models.ForgotPassword
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	ctx := context.WithoutCancel(c.UserContext())
+	go func() {
+		if err := a.sendPasswordReset(ctx, input.Email); err != nil {
+			a.logger.ErrorContext(ctx, "sending password reset failed", "error", err)
+		}
+	}()
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{"success": "if the email has an account, a reset link was sent to it"})
+}
+
+func (a *AccountController) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := a.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		a.logger.InfoContext(ctx, "password reset of an unknown email", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	raw, err := a.newToken(ctx, models.TokenPasswordReset, user.Email, a.policy.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := a.link("/reset-password", raw)
+	return a.mailer.Send(ctx, mail.PasswordResetMessage(user.Email, link, a.policy.PasswordResetTTL))
+}
+
+// ResetPassword sets the password of the token's account. The reset link
+// was mailed to the account, so its email counts as verified too. Every
+// session of the account is signed out.
+func (a *AccountController) ResetPassword(c *fiber.Ctx) error {
+	var input models.ResetPassword
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	token, err := a.tokens.Consume(ctx, models.TokenPasswordReset, utils.HashToken(input.Token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	err = a.users.SetPassword(ctx, token.Email, input.Password)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrInvalidAccountToken
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := a.users.MarkVerified(ctx, token.Email); err != nil {
+		return err
+	}
+	if _, err := a.sessions.EndAll(ctx, token.Email, ""); err != nil {
+		return err
+	}
+	a.logger.InfoContext(ctx, "password reset", "email", token.Email)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "password reset, log in with the new password"})
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// APIKeyController manages the API keys integrations call the API with and
+// authenticates their requests. A key acts as its owner, limited to its
+// scopes, so changes to the role of the owner apply to the key at once.
+type APIKeyController struct {
+	keys   store.APIKeyRepository
+	users  store.UserRepository
+	logger *slog.Logger
+}
+
+func NewAPIKeyController(k store.APIKeyRepository, u store.UserRepository, l *slog.Logger) *APIKeyController {
+	return &APIKeyController{keys: k, users: u, logger: l}
+}
+
+// Authenticate returns the claims of the owner of key used from ip. Unknown,
+// revoked and expired keys get the same answer.
+func (a *APIKeyController) Authenticate(ctx context.Context, key, ip string) (*utils.Claims, error) {
+	now := time.Now()
+	res, err := a.live(ctx, key, now)
+	if err != nil {
+		return nil, err
+	}
+	if !allowedIP(res.AllowedIPs, ip) {
+		a.logger.WarnContext(ctx, "API key used from a denied address", "prefix", res.Prefix, "ip", ip)
+		return nil, errors.ErrAPIKeyIPDenied
+	}
+	owner, err := a.users.GetUserByEmail(ctx, res.OwnerEmail)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return nil, errors.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	// a failed write of the last use must not fail the request
+	if err := a.keys.Touch(ctx, res.ID, strings.Clone(ip), now); err != nil {
+		a.logger.ErrorContext(ctx, "recording API key use failed", "prefix", res.Prefix, "error", err)
+	}
+
+	return &utils.Claims{Role: owner.Role, Email: owner.Email, APIKey: res.Prefix, Scopes: res.Scopes}, nil
+}
+
+// Identify returns the id of key when it is a live key allowed from ip. It
+// records no use, the rate limiter calls it before Authenticate.
+func (a *APIKeyController) Identify(ctx context.Context, key, ip string) (string, error) {
+	res, err := a.live(ctx, key, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if !allowedIP(res.AllowedIPs, ip) {
+		return "", errors.ErrAPIKeyIPDenied
+	}
+	return res.ID.Hex(), nil
+}
+
+// live returns the stored key of key unless it is unknown, revoked or
+// expired at now
+func (a *APIKeyController) live(ctx context.Context, key string, now time.Time) (models.APIKey, error) {
+	res, err := a.keys.GetByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if res.RevokedAt != nil || (res.ExpiresAt != nil && !now.Before(*res.ExpiresAt)) {
+		return models.APIKey{}, errors.ErrInvalidAPIKey
+	}
+	return res, nil
+}
+
+// allowedIP reports whether ip is one of the addresses or in one of the
+// CIDR ranges of allowed, every ip is when allowed is empty
+func allowedIP(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if net.ParseIP(entry).Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create issues a key, the response is the only time the key is shown
+func (a *APIKeyController) Create(c *fiber.Ctx) error {
+	var input models.APIKeyCreate
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return errors.Validation(errors.FieldError{Field: "expires_at", Code: "gt", Message: "expires_at must be in the future"})
+	}
+	owner, err := a.users.GetUserByEmail(c.UserContext(), input.OwnerEmail)
+	if err != nil {
+		return err
+	}
+	// a key never grants more than its owner may do
+	var invalid []errors.FieldError
+	for i, scope := range input.Scopes {
+		if !constant.HasPermission(owner.Role, scope) {
+			invalid = append(invalid, errors.FieldError{
+				Field:   fmt.Sprintf("scopes[%d]", i),
+				Code:    "scope",
+				Message: fmt.Sprintf("%v is not a permission of the %v role of the owner", scope, owner.Role),
+			})
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.Validation(invalid...)
+	}
+
+	raw, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+	key := models.APIKey{
+		Name:       input.Name,
+		Prefix:     prefix,
+		KeyHash:    utils.HashToken(raw),
+		OwnerEmail: owner.Email,
+		Scopes:     input.Scopes,
+		AllowedIPs: input.AllowedIPs,
+		CreatedBy:  middleware.GetClaims(c).Email,
+	}
+	if input.ExpiresAt != nil {
+		expiresAt := input.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	res, err := a.keys.Create(c.UserContext(), key)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "store the api_key, it is not shown again", "api_key": raw, "data": res})
+}
+
+func (a *APIKeyController) Get(c *fiber.Ctx) error {
+	pageInt, limitInt, err := pagination(c)
+	if err != nil {
+		return err
+	}
+
+	keys, total, err := a.keys.GetAll(c.UserContext(), pageInt, limitInt)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
+		"api_keys": keys,
+		"page":     pageInt,
+		"limit":    limitInt,
+		"total":    total,
+	})
+}
+
+func (a *APIKeyController) GetKey(c *fiber.Ctx) error {
+	res, err := a.keys.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"api_key": res})
+}
+
+// Revoke stops a key from authenticating, it is kept for the record
+func (a *APIKeyController) Revoke(c *fiber.Ctx) error {
+	res, err := a.keys.Revoke(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "API key revoked", "data": res})
+}
@@ -1,91 +1,305 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/tabed23/travel-api/models"
 	"github.com/tabed23/travel-api/repository/store"
 	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
 	"github.com/tabed23/travel-api/utils/errors"
 )
 
-var validate = validator.New()
+const refreshCookie = "refresh_token"
 
 type AuthController struct {
-	s store.UserStore
-	logger *slog.Logger
+	s        store.UserRepository
+	tokens   store.RefreshTokenRepository
+	sessions *SessionController
+	logins   *LoginController
+	accounts *AccountController
+	mfa      *MFAController
+	jwt      *utils.JWT
+	logger   *slog.Logger
 }
 
-func NewAuthController(s store.UserStore, l *slog.Logger) *AuthController {
-	return &AuthController{s: s, logger: l}
+func NewAuthController(s store.UserRepository, t store.RefreshTokenRepository, n *SessionController, g *LoginController, a *AccountController, m *MFAController, j *utils.JWT, l *slog.Logger) *AuthController {
+	return &AuthController{s: s, tokens: t, sessions: n, logins: g, accounts: a, mfa: m, jwt: j, logger: l}
 }
 
 func (a *AuthController) Register(c *fiber.Ctx) error {
-	var usr models.User
-	if err := c.BodyParser(&usr); err != nil {
+	var input models.UserRegister
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	usr := input.User()
+	// self registered accounts are always plain users, admins grant other roles
+	usr.Role = constant.UserRole
+	usr.Unverified = true
 
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrBadRequest})
+	res, err := a.s.CreaterUser(c.UserContext(), usr)
+	if err != nil {
+		return err
+	}
+	// the user can ask for another verification mail, a failed one must not
+	// fail the registration
+	if err := a.accounts.SendVerification(c.UserContext(), res); err != nil {
+		a.logger.ErrorContext(c.UserContext(), "sending verification mail failed", "email", res.Email, "error", err)
 	}
-	validateErr := validate.Struct(usr)
 
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error(), "message": errors.ErrBadRequest})
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "user created successfully", "data": res})
+}
 
+func (a *AuthController) Login(c *fiber.Ctx) error {
+	var input models.UserLogin
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	res, err := a.s.CreaterUser(usr)
+	ctx := c.UserContext()
+	attempt := a.logins.NewAttempt(c, input.Email)
+	wait, err := a.logins.Locked(ctx, attempt)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	if wait > 0 {
+		SetRetryAfter(c, wait)
+		return errors.ErrLoginLocked
+	}
 
+	// unknown emails and wrong passwords get the same answer in the same
+	// time, so logins do not reveal which emails have accounts
+	user, err := a.s.GetUserByEmail(ctx, input.Email)
+	if err != nil && !errors.Is(err, errors.ErrUserNotFound) {
+		return err
+	}
+	reason := ""
+	if err != nil {
+		decoyPassword(input.Password)
+		reason = models.LoginUnknownEmail
+	} else if err := utils.VerifyPassword(user.Password, input.Password); err != nil {
+		reason = models.LoginWrongPassword
+	}
+	if reason != "" {
+		if err := a.logins.Failed(ctx, attempt, reason); err != nil {
+			return err
+		}
+		return errors.ErrInvalidCredentials
+	}
+	// the login only succeeds once it passed its second factor
+	stage, err := a.mfa.Challenge(ctx, user)
+	if err != nil {
+		return err
+	}
+	if stage != "" {
+		return a.mfa.RespondPending(c, user.Email, stage)
+	}
+	if err := a.logins.Succeeded(ctx, attempt); err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "user created successfully", "data": res})
+	return a.issueTokens(c, user, nil)
 }
 
-func (a *AuthController) Login(c *fiber.Ctx) error {
-	var input models.UserLogin
-	err := c.BodyParser(&input)
+// VerifyMFA is the second step of a login with a second factor, it takes
+// the mfa token of the first step and a code. Wrong codes count as failed
+// logins, so they lock the account out like wrong passwords.
+func (a *AuthController) VerifyMFA(c *fiber.Ctx) error {
+	var input models.MFAVerify
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	email, err := a.mfa.Pending(input.MFAToken, utils.MFAVerify)
 	if err != nil {
-		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err})
+		return err
 	}
 
-	user, err := a.s.GetUserByEmail(input.Email)
+	ctx := c.UserContext()
+	attempt := a.logins.NewAttempt(c, email)
+	wait, err := a.logins.Locked(ctx, attempt)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "user": "is not authorized"})
+		return err
+	}
+	if wait > 0 {
+		SetRetryAfter(c, wait)
+		return errors.ErrLoginLocked
+	}
+	user, err := a.s.GetUserByEmail(ctx, email)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrInvalidMFAToken
 	}
-	err = utils.VerifyPassword(user.Password, input.Password)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "user": "Password is not valid"})
+		return err
+	}
+
+	err = a.mfa.Verify(ctx, email, input.Code, input.RecoveryCode)
+	if errors.Is(err, errors.ErrInvalidMFACode) || errors.Is(err, errors.ErrMFANotEnrolled) {
+		if err := a.logins.Failed(ctx, attempt, models.LoginWrongMFACode); err != nil {
+			return err
+		}
+		return errors.ErrInvalidMFACode
 	}
-	token, err := utils.GenrateNewToken(user.Role, user.Email)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	if err := a.logins.Succeeded(ctx, attempt); err != nil {
+		return err
 	}
 
-	cookie := fiber.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Expires:  time.Now().Add(time.Hour * 24),
-		HTTPOnly: true,
+	return a.issueTokens(c, user, nil)
+}
+
+// EnrollMFA starts setting up the second factor a login must have before it
+// gets a token, it takes the mfa token of the login
+func (a *AuthController) EnrollMFA(c *fiber.Ctx) error {
+	var input models.MFAToken
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	email, err := a.mfa.Pending(input.MFAToken, utils.MFAEnroll)
+	if err != nil {
+		return err
 	}
 
-	c.Cookie(&cookie)
+	data, err := a.mfa.Enroll(c.UserContext(), email)
+	if err != nil {
+		return err
+	}
 
-	return c.
-		Status(http.StatusOK).
-		JSON(fiber.Map{
-			"user":  user,
-			"token": fmt.Sprintf("Bearer %s", token),
-		})
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "scan the otpauth_uri and confirm a code", "data": data})
+}
 
+// ConfirmMFAEnrollment enables the second factor set up by EnrollMFA and
+// completes the login with tokens and the recovery codes
+func (a *AuthController) ConfirmMFAEnrollment(c *fiber.Ctx) error {
+	var input models.MFAEnrollConfirm
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+	email, err := a.mfa.Pending(input.MFAToken, utils.MFAEnroll)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	user, err := a.s.GetUserByEmail(ctx, email)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrInvalidMFAToken
+	}
+	if err != nil {
+		return err
+	}
+	codes, err := a.mfa.Confirm(ctx, email, input.Code)
+	if err != nil {
+		return err
+	}
+	if err := a.logins.Succeeded(ctx, a.logins.NewAttempt(c, email)); err != nil {
+		return err
+	}
+
+	return a.issueTokens(c, user, fiber.Map{"recovery_codes": codes})
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. A refresh token can only be used once; presenting a token that was
+// already rotated signs its session out. Refresh tokens of sessions signed
+// out are refused.
+func (a *AuthController) Refresh(c *fiber.Ctx) error {
+	raw := a.refreshToken(c)
+	if raw == "" {
+		return errors.ErrInvalidRefreshToken
+	}
+
+	current, err := a.tokens.GetByHash(c.UserContext(), utils.HashToken(raw))
+	if err != nil {
+		return err
+	}
+	if current.RevokedAt != nil {
+		if !current.ReplacedBy.IsZero() {
+			a.logger.WarnContext(c.UserContext(), "refresh token reuse detected", "family", current.Family)
+			if err := a.endSession(c.UserContext(), current); err != nil {
+				return err
+			}
+			return errors.ErrRefreshTokenReused
+		}
+		return errors.ErrInvalidRefreshToken
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return errors.ErrInvalidRefreshToken
+	}
+	// the family of a refresh token is its session, signing the session out
+	// ends the refresh tokens too
+	session, err := a.sessions.Active(c.UserContext(), current.Family, current.UserEmail)
+	if errors.Is(err, errors.ErrSessionRevoked) {
+		if err := a.tokens.RevokeFamily(c.UserContext(), current.Family); err != nil {
+			return err
+		}
+		return errors.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := a.s.GetUserByEmail(c.UserContext(), current.UserEmail)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrUnAuthorized
+	}
+	if err != nil {
+		return err
+	}
+	// sessions started before a second factor became mandatory end, the
+	// user has to log in again and set it up
+	stage, err := a.mfa.Challenge(c.UserContext(), user)
+	if err != nil {
+		return err
+	}
+	if stage == utils.MFAEnroll {
+		return errors.ErrMFARequired
+	}
+
+	next, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return err
+	}
+	rotated := models.RefreshToken{
+		Family:    current.Family,
+		TokenHash: utils.HashToken(next),
+		UserEmail: user.Email,
+		ExpiresAt: time.Now().Add(a.jwt.RefreshTTL()).UTC(),
+	}
+	if _, err := a.tokens.Rotate(c.UserContext(), current.ID, rotated); err != nil {
+		if errors.Is(err, errors.ErrRefreshTokenReused) {
+			if err := a.endSession(c.UserContext(), current); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	if err := a.sessions.Extend(c, session, rotated.ExpiresAt); err != nil {
+		return err
+	}
+
+	return a.respondWithTokens(c, user, session, next, rotated.ExpiresAt, nil)
 }
 
 func (a *AuthController) Logout(c *fiber.Ctx) error {
+	if raw := a.refreshToken(c); raw != "" {
+		token, err := a.tokens.GetByHash(c.UserContext(), utils.HashToken(raw))
+		if err != nil && !errors.Is(err, errors.ErrInvalidRefreshToken) {
+			return err
+		}
+		if err == nil {
+			if err := a.endSession(c.UserContext(), token); err != nil {
+				return err
+			}
+		}
+	}
+
 	cookie := fiber.Cookie{
 		Name:     "jwt",
 		Value:    "",
@@ -94,9 +308,99 @@ func (a *AuthController) Logout(c *fiber.Ctx) error {
 	}
 
 	c.Cookie(&cookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookie,
+		Value:    "",
+		Path:     "/api/v1/auth",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+	})
 
 	return c.JSON(fiber.Map{
 		"message": "success",
 	})
 
 }
+
+// endSession signs out the session of a refresh token, tokens of families
+// older than sessions only have their family revoked
+func (a *AuthController) endSession(ctx context.Context, token models.RefreshToken) error {
+	_, err := a.sessions.End(ctx, token.UserEmail, token.Family)
+	if errors.Is(err, errors.ErrSessionNotFound) {
+		return a.tokens.RevokeFamily(ctx, token.Family)
+	}
+	return err
+}
+
+// issueTokens starts a session of the login of user, whose id is the family of
+// its refresh tokens, and responds with both tokens and the values of extra
+func (a *AuthController) issueTokens(c *fiber.Ctx, user models.User, extra fiber.Map) error {
+	raw, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(a.jwt.RefreshTTL()).UTC()
+	session, err := a.sessions.Start(c, user.Email, expiresAt)
+	if err != nil {
+		return err
+	}
+	token := models.RefreshToken{
+		Family:    session.ID.Hex(),
+		TokenHash: utils.HashToken(raw),
+		UserEmail: user.Email,
+		ExpiresAt: expiresAt,
+	}
+	if _, err := a.tokens.Create(c.UserContext(), token); err != nil {
+		return err
+	}
+
+	return a.respondWithTokens(c, user, session, raw, token.ExpiresAt, extra)
+}
+
+func (a *AuthController) respondWithTokens(c *fiber.Ctx, user models.User, session models.Session, refresh string, refreshExpires time.Time, extra fiber.Map) error {
+	token, err := a.jwt.GenrateNewToken(user.Role, user.Email, session.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	cookie := fiber.Cookie{
+		Name:     "jwt",
+		Value:    token,
+		Expires:  time.Now().Add(time.Hour * 24),
+		HTTPOnly: true,
+	}
+
+	c.Cookie(&cookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookie,
+		Value:    refresh,
+		Path:     "/api/v1/auth",
+		Expires:  refreshExpires,
+		HTTPOnly: true,
+	})
+
+	body := fiber.Map{
+		"user":          user,
+		"token":         fmt.Sprintf("Bearer %s", token),
+		"refresh_token": refresh,
+		"session_id":    session.ID.Hex(),
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+
+	return c.
+		Status(http.StatusOK).
+		JSON(body)
+}
+
+// refreshToken reads the refresh token from the request body, falling back to the cookie
+func (a *AuthController) refreshToken(c *fiber.Ctx) string {
+	var input models.RefreshRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err == nil && input.RefreshToken != "" {
+			return input.RefreshToken
+		}
+	}
+	return c.Cookies(refreshCookie)
+}
@@ -1,113 +1,122 @@
 package controller
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
-	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/middleware"
 	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/policy"
 	"github.com/tabed23/travel-api/repository/store"
-	"github.com/tabed23/travel-api/utils"
-	"github.com/tabed23/travel-api/utils/constant"
-	"github.com/tabed23/travel-api/utils/errors"
 )
 
+// Refunder pays back the refund amount of a cancelled booking, a refund it
+// could not pay back yet is kept pending and reported as ErrRefundPending
+type Refunder interface {
+	RefundBooking(ctx context.Context, booking models.Booking) error
+}
+
 type BookingController struct {
-	s store.BookingStore
-	logger *slog.Logger
+	s       store.BookingRepository
+	users   store.UserRepository
+	refunds Refunder
+	metrics *metrics.Metrics
+	logger  *slog.Logger
 }
 
-func NewBookingController(s store.BookingStore, l *slog.Logger) *BookingController {
-	return &BookingController{s: s, logger: l}
+func NewBookingController(s store.BookingRepository, users store.UserRepository, refunds Refunder, m *metrics.Metrics, l *slog.Logger) *BookingController {
+	return &BookingController{s: s, users: users, refunds: refunds, metrics: m, logger: l}
 }
 
 func (u *BookingController) CreatBooking(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
+	usrId := c.Params("id")
+	usr, err := u.users.GetByID(c.UserContext(), usrId)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrUnAuthorized})
+		return err
 	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
+	if err := policy.CanAccessUser(middleware.GetClaims(c), usr.Email); err != nil {
+		return err
 	}
-	usrId := c.Params("id")
-	var booking models.Booking
-	if err := c.BodyParser(&booking); err != nil {
+	return u.createBooking(c, usr)
+}
 
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrBadRequest})
+// CreateMyBooking creates a booking for the caller
+func (u *BookingController) CreateMyBooking(c *fiber.Ctx) error {
+	usr, err := u.users.GetUserByEmail(c.UserContext(), middleware.GetClaims(c).Email)
+	if err != nil {
+		return err
 	}
-	validateErr := validate.Struct(booking)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
+	return u.createBooking(c, usr)
+}
 
+func (u *BookingController) createBooking(c *fiber.Ctx, usr models.User) error {
+	if err := policy.CanBook(middleware.GetClaims(c), usr); err != nil {
+		return err
+	}
+	var input models.BookingInput
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	res, err := u.s.CreateBooking(usrId, booking)
+	res, err := u.s.CreateBooking(c.UserContext(), usr.ID.Hex(), input.Booking())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
 	}
+	u.metrics.BookingCreated()
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "Booking created successfully", "data": res})
 }
 
 func (u *BookingController) UpdateBooking(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrUnAuthorized})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
-	}
 	var updatebooking models.UpdateBooking
 	id := c.Params("id")
-	if err := c.BodyParser(&updatebooking); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrBadRequest})
+	if err := u.authorize(c, id); err != nil {
+		return err
 	}
-	validateErr := validate.Struct(updatebooking)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
-
+	if err := parseBody(c, &updatebooking); err != nil {
+		return err
 	}
 
-	res, err := u.s.Update(id, updatebooking)
+	res, err := u.s.Update(c.UserContext(), id, updatebooking)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrInternalServerError})
-
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "booking updated successfully", "data": res})
 }
 
 func (u *BookingController) Get(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
+	pageInt, limitInt, err := pagination(c)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrUnAuthorized})
+		return err
 	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
-	}
-	page := c.Query("page", "1")
-	limit := c.Query("limit", "10")
 
-	pageInt, err := strconv.Atoi(page)
+	bookings, total, err := u.s.GetAll(c.UserContext(), pageInt, limitInt)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page number"})
+		return err
 	}
-	limitInt, err := strconv.Atoi(limit)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
+		"bookings": bookings,
+		"page":     pageInt,
+		"limit":    limitInt,
+		"total":    total,
+	})
+}
+
+// GetMine lists the bookings of the caller
+func (u *BookingController) GetMine(c *fiber.Ctx) error {
+	pageInt, limitInt, err := pagination(c)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+		return err
 	}
 
-	bookings, total, err := u.s.GetAll(pageInt, limitInt)
+	bookings, total, err := u.s.GetByEmail(c.UserContext(), middleware.GetClaims(c).Email, pageInt, limitInt)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrInternalServerError})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
@@ -118,42 +127,79 @@ func (u *BookingController) Get(c *fiber.Ctx) error {
 	})
 }
 
+// Delete cancels the booking, bookings are never removed so their history is kept
 func (t *BookingController) Delete(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrUnAuthorized})
+	return t.Cancel(c)
+}
+
+// Cancel cancels a booking of the caller, or any booking for admins
+func (u *BookingController) Cancel(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := u.authorize(c, id); err != nil {
+		return err
 	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
+	return u.transition(c, id, models.BookingCancelled)
+}
+
+// Confirm confirms a booking, it is routed for admins only
+func (u *BookingController) Confirm(c *fiber.Ctx) error {
+	return u.transition(c, c.Params("id"), models.BookingConfirmed)
+}
+
+// Complete marks a booking as travelled, it is routed for admins only
+func (u *BookingController) Complete(c *fiber.Ctx) error {
+	return u.transition(c, c.Params("id"), models.BookingCompleted)
+}
+
+// NoShow marks a booking whose guests did not show up, it is routed for admins only
+func (u *BookingController) NoShow(c *fiber.Ctx) error {
+	return u.transition(c, c.Params("id"), models.BookingNoShow)
+}
+
+func (u *BookingController) transition(c *fiber.Ctx, id string, to models.BookingStatus) error {
+	var input models.BookingTransition
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &input); err != nil {
+			return err
+		}
 	}
-	id := c.Params("id")
 
-	ok, err := t.s.DeleteBook(id)
+	res, err := u.s.Transition(c.UserContext(), id, to, middleware.GetClaims(c).Email, input.Note)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrInternalServerError})
+		return err
 	}
-	if ok {
-		return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
-
+	if to == models.BookingCancelled {
+		u.metrics.BookingCancelled()
+	}
+	if to == models.BookingCancelled && u.refunds != nil {
+		// the booking stays cancelled, a failed refund is left pending for
+		// the sweeper to retry
+		if err := u.refunds.RefundBooking(c.UserContext(), res); err != nil {
+			return err
+		}
 	}
-	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err, "message": errors.ErrInternalServerError})
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "booking " + string(to), "data": res})
 }
 
 func (u *BookingController) GetBooking(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "message": errors.ErrUnAuthorized})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
-	}
 	id := c.Params("id")
-	res, err := u.s.GetBooking(id)
+	res, err := u.s.GetBooking(c.UserContext(), id)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+		return err
+	}
+	if err := policy.CanAccessBooking(middleware.GetClaims(c), res); err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"booking": res})
 }
+
+// authorize checks that the caller may act on the booking
+func (u *BookingController) authorize(c *fiber.Ctx, id string) error {
+	booking, err := u.s.GetBooking(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return policy.CanAccessBooking(middleware.GetClaims(c), booking)
+}
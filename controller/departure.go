@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+)
+
+type DepartureController struct {
+	s      store.DepartureRepository
+	logger *slog.Logger
+}
+
+func NewDepartureController(s store.DepartureRepository, l *slog.Logger) *DepartureController {
+	return &DepartureController{s: s, logger: l}
+}
+
+func (d *DepartureController) CreateDeparture(c *fiber.Ctx) error {
+	tourId := c.Params("id")
+	var input models.UpdateDeparture
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	res, err := d.s.Create(c.UserContext(), tourId, input.Departure())
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "departure created successfully", "data": res})
+}
+
+func (d *DepartureController) GetTourDepartures(c *fiber.Ctx) error {
+	tourId := c.Params("id")
+	res, err := d.s.GetByTour(c.UserContext(), tourId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"departures": res})
+}
+
+func (d *DepartureController) GetDeparture(c *fiber.Ctx) error {
+	id := c.Params("id")
+	res, err := d.s.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"departure": res, "seats_available": res.SeatsAvailable()})
+}
+
+func (d *DepartureController) UpdateDeparture(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var upd models.UpdateDeparture
+	if err := parseBody(c, &upd); err != nil {
+		return err
+	}
+
+	res, err := d.s.Update(c.UserContext(), id, upd)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "departure updated successfully", "data": res})
+}
+
+func (d *DepartureController) Delete(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ok, err := d.s.Delete(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/health"
+)
+
+type HealthController struct {
+	h *health.Health
+}
+
+func NewHealthController(h *health.Health) *HealthController {
+	return &HealthController{h: h}
+}
+
+// Live answers as long as the process serves requests
+func (h *HealthController) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": health.StatusOK})
+}
+
+// Ready answers 503 when a dependency is down or the service shuts down
+func (h *HealthController) Ready(c *fiber.Ctx) error {
+	report, ok := h.h.Ready(c.UserContext())
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(report)
+}
+
+func (h *HealthController) Version(c *fiber.Ctx) error {
+	return c.JSON(health.BuildInfo())
+}
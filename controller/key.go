@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/keyring"
+)
+
+type KeyController struct {
+	keys *keyring.Keyring
+}
+
+func NewKeyController(k *keyring.Keyring) *KeyController {
+	return &KeyController{keys: k}
+}
+
+// JWKS serves the public keys verifying the tokens. Keys are published a
+// rotation overlap before they sign, so caching the set briefly is safe.
+func (k *KeyController) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(k.keys.JWKS(time.Now()))
+}
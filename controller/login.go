@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/policy"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils"
+)
+
+// LockoutPolicy says how long logins are refused after failures, see
+// config.Lockout
+type LockoutPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Delay         time.Duration
+	Duration      time.Duration
+	ForgetAfter   time.Duration
+}
+
+// lockFor returns how long a key is locked after its failures, max is the
+// number of failures locking it for the full duration
+func (p LockoutPolicy) lockFor(failures, max int) time.Duration {
+	if failures >= max {
+		return p.Duration
+	}
+	d := p.Delay
+	for i := 1; i < failures && d < p.Duration; i++ {
+		d *= 2
+	}
+	if d > p.Duration {
+		return p.Duration
+	}
+	return d
+}
+
+// LoginController tracks login attempts per email and per IP. It locks out
+// the keys with too many failures, keeps the login history and serves it.
+type LoginController struct {
+	attempts store.LoginAttemptRepository
+	locks    store.LoginLockRepository
+	policy   LockoutPolicy
+	logger   *slog.Logger
+}
+
+func NewLoginController(a store.LoginAttemptRepository, k store.LoginLockRepository, p LockoutPolicy, l *slog.Logger) *LoginController {
+	return &LoginController{attempts: a, locks: k, policy: p, logger: l}
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// NewAttempt describes a login of email by the client of c
+func (l *LoginController) NewAttempt(c *fiber.Ctx, email string) models.LoginAttempt {
+	// fiber reuses the buffers of its strings once the request is done, the
+	// attempt is stored and logged after that
+	return models.LoginAttempt{
+		Email:     strings.ToLower(email),
+		IP:        strings.Clone(c.IP()),
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Locked returns how long the email and IP of attempt are still locked, a
+// locked attempt is recorded in the history. Emails are locked whether an
+// account has them or not, so a lockout does not reveal accounts.
+func (l *LoginController) Locked(ctx context.Context, attempt models.LoginAttempt) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{emailKey(attempt.Email), ipKey(attempt.IP)} {
+		lock, err := l.locks.Get(ctx, key, attempt.CreatedAt)
+		if err != nil {
+			return 0, err
+		}
+		if lock.Locked(attempt.CreatedAt) {
+			wait = max(wait, lock.LockedUntil.Sub(attempt.CreatedAt))
+		}
+	}
+	if wait > 0 {
+		attempt.Reason = models.LoginLocked
+		if _, err := l.attempts.Record(ctx, attempt); err != nil {
+			return 0, err
+		}
+	}
+	return wait, nil
+}
+
+// Failed records a failed attempt and locks its email and IP for longer with
+// every further failure
+func (l *LoginController) Failed(ctx context.Context, attempt models.LoginAttempt, reason string) error {
+	attempt.Reason = reason
+	if _, err := l.attempts.Record(ctx, attempt); err != nil {
+		return err
+	}
+	keys := []struct {
+		key string
+		max int
+	}{
+		{emailKey(attempt.Email), l.policy.MaxFailures},
+		{ipKey(attempt.IP), l.policy.IPMaxFailures},
+	}
+	for _, k := range keys {
+		lock, err := l.locks.Fail(ctx, k.key, attempt.CreatedAt, l.policy.ForgetAfter)
+		if err != nil {
+			return err
+		}
+		if lock.Failures == k.max {
+			l.logger.WarnContext(ctx, "login locked out", "key", k.key, "failures", lock.Failures)
+		}
+		until := attempt.CreatedAt.Add(l.policy.lockFor(lock.Failures, k.max))
+		if err := l.locks.Lock(ctx, k.key, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeeded records a successful attempt and forgets the failures of its
+// email. Logins from an IP the account never used are flagged, the
+// failures of the IP are kept so that logging into an own account does not
+// unlock guessing others.
+func (l *LoginController) Succeeded(ctx context.Context, attempt models.LoginAttempt) error {
+	attempt.Success = true
+	ips, err := l.attempts.SuccessfulIPs(ctx, attempt.Email)
+	if err != nil {
+		return err
+	}
+	if len(ips) > 0 && !contains(ips, attempt.IP) {
+		attempt.NewIP = true
+		l.logger.WarnContext(ctx, "login from a new ip", "email", attempt.Email, "ip", attempt.IP, "user_agent", attempt.UserAgent)
+	}
+	if _, err := l.attempts.Record(ctx, attempt); err != nil {
+		return err
+	}
+	return l.locks.Reset(ctx, emailKey(attempt.Email))
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetryAfter tells the client when to retry a locked login
+func SetRetryAfter(c *fiber.Ctx, wait time.Duration) {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// MyHistory lists the login attempts of the caller
+func (l *LoginController) MyHistory(c *fiber.Ctx) error {
+	return l.history(c, middleware.GetClaims(c).Email)
+}
+
+// UserHistory lists the login attempts of a user, to the user and admins
+func (l *LoginController) UserHistory(c *fiber.Ctx) error {
+	email := c.Params("email")
+	if err := policy.CanAccessUser(middleware.GetClaims(c), email); err != nil {
+		return err
+	}
+	return l.history(c, email)
+}
+
+func (l *LoginController) history(c *fiber.Ctx, email string) error {
+	pageInt, limitInt, err := pagination(c)
+	if err != nil {
+		return err
+	}
+
+	attempts, total, err := l.attempts.GetByEmail(c.UserContext(), strings.ToLower(email), pageInt, limitInt)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
+		"logins": attempts,
+		"page":   pageInt,
+		"limit":  limitInt,
+		"total":  total,
+	})
+}
+
+// Unlock forgets the failed logins of a user, and of an IP when the ip
+// query parameter is set
+func (l *LoginController) Unlock(c *fiber.Ctx) error {
+	email := c.Params("email")
+	if err := l.locks.Reset(c.UserContext(), emailKey(email)); err != nil {
+		return err
+	}
+	if ip := c.Query("ip"); ip != "" {
+		if err := l.locks.Reset(c.UserContext(), ipKey(ip)); err != nil {
+			return err
+		}
+	}
+	l.logger.InfoContext(c.UserContext(), "login unlocked", "email", email, "ip", c.Query("ip"))
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "login unlocked"})
+}
+
+var (
+	decoyOnce sync.Once
+	decoyHash string
+)
+
+// decoyPassword compares password with a throwaway hash, so a login of an
+// unknown email takes as long as a wrong password of a known one
+func decoyPassword(password string) {
+	decoyOnce.Do(func() {
+		decoyHash, _ = utils.EnscryptPassword("decoy password")
+	})
+	_ = utils.VerifyPassword(decoyHash, password)
+}
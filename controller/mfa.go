@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/totp"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// MFAPolicy says who must have a second factor and how logins pass it, see
+// config.MFA
+type MFAPolicy struct {
+	Issuer        string
+	RequireAdmins bool
+	PendingTTL    time.Duration
+	RecoveryCodes int
+}
+
+// MFAController manages the TOTP second factor of users: enrollment, the
+// codes checked by the second step of a login and recovery codes
+type MFAController struct {
+	mfa    store.MFARepository
+	users  store.UserRepository
+	jwt    *utils.JWT
+	policy MFAPolicy
+	logger *slog.Logger
+}
+
+func NewMFAController(m store.MFARepository, u store.UserRepository, j *utils.JWT, p MFAPolicy, l *slog.Logger) *MFAController {
+	return &MFAController{mfa: m, users: u, jwt: j, policy: p, logger: l}
+}
+
+// Required reports whether user must have a second factor
+func (m *MFAController) Required(user models.User) bool {
+	return m.policy.RequireAdmins && user.Role == constant.AdminRole
+}
+
+// Challenge returns the stage a login of user waits in after the password
+// check, or "" when the password is enough
+func (m *MFAController) Challenge(ctx context.Context, user models.User) (string, error) {
+	mfa, err := m.mfa.Get(ctx, user.Email)
+	if err != nil && !errors.Is(err, errors.ErrMFANotEnrolled) {
+		return "", err
+	}
+	if mfa.Enabled {
+		return utils.MFAVerify, nil
+	}
+	if m.Required(user) {
+		return utils.MFAEnroll, nil
+	}
+	return "", nil
+}
+
+// RespondPending answers a login waiting in stage with the token of its
+// next step instead of an access token
+func (m *MFAController) RespondPending(c *fiber.Ctx, email, stage string) error {
+	token, err := m.jwt.GenerateMFAToken(email, stage, m.policy.PendingTTL)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"mfa_required": true,
+		"mfa_stage":    stage,
+		"mfa_token":    token,
+		"expires_in":   int(m.policy.PendingTTL.Seconds()),
+	})
+}
+
+// Pending returns the email of a valid mfa pending token of stage
+func (m *MFAController) Pending(token, stage string) (string, error) {
+	claims, err := m.jwt.ParseMFAToken(token, stage)
+	if err != nil {
+		return "", errors.ErrInvalidMFAToken.Wrap(err)
+	}
+	return claims.Email, nil
+}
+
+// Verify checks the second factor of a login of email, a code of the
+// authenticator app or a recovery code. Both work once.
+func (m *MFAController) Verify(ctx context.Context, email, code, recoveryCode string) error {
+	if recoveryCode == "" {
+		return m.checkCode(ctx, email, code)
+	}
+	mfa, err := m.mfa.UseRecoveryCode(ctx, email, hashRecoveryCode(recoveryCode))
+	if err != nil {
+		return err
+	}
+	m.logger.WarnContext(ctx, "recovery code used", "email", email, "recovery_codes_left", len(mfa.RecoveryCodes))
+	return nil
+}
+
+// checkCode accepts a code of the enabled second factor of email once
+func (m *MFAController) checkCode(ctx context.Context, email, code string) error {
+	mfa, err := m.mfa.Get(ctx, email)
+	if errors.Is(err, errors.ErrMFANotEnrolled) || (err == nil && !mfa.Enabled) {
+		return errors.ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		return errors.ErrInvalidMFACode
+	}
+	return m.mfa.UseStep(ctx, email, step)
+}
+
+// Enroll starts setting up a second factor for email. The secret and its
+// otpauth:// URI are only shown here, the factor is enabled once a code of
+// it is confirmed.
+func (m *MFAController) Enroll(ctx context.Context, email string) (fiber.Map, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.mfa.Enroll(ctx, models.MFA{Email: email, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(m.policy.Issuer, email, secret),
+	}, nil
+}
+
+// Confirm enables the pending second factor of email with its first code
+// and returns the recovery codes, which are only shown here
+func (m *MFAController) Confirm(ctx context.Context, email, code string) ([]string, error) {
+	mfa, err := m.mfa.Get(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, errors.ErrMFAEnabled
+	}
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.ErrInvalidMFACode
+	}
+	codes, hashes, err := m.recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.mfa.Enable(ctx, email, step, hashes); err != nil {
+		return nil, err
+	}
+	m.logger.InfoContext(ctx, "mfa enabled", "email", email)
+	return codes, nil
+}
+
+// recoveryCodes returns new recovery codes and their hashes
+func (m *MFAController) recoveryCodes() ([]string, []string, error) {
+	codes := make([]string, m.policy.RecoveryCodes)
+	hashes := make([]string, m.policy.RecoveryCodes)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		s := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = s[:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code the way users may type it, in
+// any case and with or without dashes
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(code)
+}
+
+// Status tells the caller whether their second factor is enabled
+func (m *MFAController) Status(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	user, err := m.users.GetUserByEmail(c.UserContext(), claims.Email)
+	if err != nil {
+		return err
+	}
+	mfa, err := m.mfa.Get(c.UserContext(), claims.Email)
+	if err != nil && !errors.Is(err, errors.ErrMFANotEnrolled) {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true", "data": fiber.Map{
+		"enabled":             mfa.Enabled,
+		"enabled_at":          mfa.EnabledAt,
+		"required":            m.Required(user),
+		"recovery_codes_left": len(mfa.RecoveryCodes),
+	}})
+}
+
+// StartEnrollment starts setting up a second factor for the caller
+func (m *MFAController) StartEnrollment(c *fiber.Ctx) error {
+	data, err := m.Enroll(c.UserContext(), middleware.GetClaims(c).Email)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "scan the otpauth_uri and confirm a code", "data": data})
+}
+
+// ConfirmEnrollment enables the second factor of the caller
+func (m *MFAController) ConfirmEnrollment(c *fiber.Ctx) error {
+	var input models.MFACode
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	codes, err := m.Confirm(c.UserContext(), middleware.GetClaims(c).Email, input.Code)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the caller, it
+// takes a code of the authenticator app
+func (m *MFAController) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var input models.MFACode
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	email := middleware.GetClaims(c).Email
+	if err := m.checkCode(ctx, email, input.Code); err != nil {
+		return err
+	}
+	codes, hashes, err := m.recoveryCodes()
+	if err != nil {
+		return err
+	}
+	if err := m.mfa.SetRecoveryCodes(ctx, email, hashes); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "recovery codes replaced", "recovery_codes": codes})
+}
+
+// Disable removes the second factor of the caller, it takes a code of the
+// authenticator app. Users who must have a second factor can not.
+func (m *MFAController) Disable(c *fiber.Ctx) error {
+	var input models.MFACode
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	email := middleware.GetClaims(c).Email
+	user, err := m.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if m.Required(user) {
+		return errors.ErrMFARequired
+	}
+	if err := m.checkCode(ctx, email, input.Code); err != nil {
+		return err
+	}
+	if err := m.mfa.Delete(ctx, email); err != nil {
+		return err
+	}
+	m.logger.InfoContext(ctx, "mfa disabled", "email", email)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "two-factor authentication disabled"})
+}
+
+// Reset removes the second factor of a user who lost it, for admins
+func (m *MFAController) Reset(c *fiber.Ctx) error {
+	email := c.Params("email")
+	if err := m.mfa.Delete(c.UserContext(), email); err != nil {
+		return err
+	}
+	m.logger.WarnContext(c.UserContext(), "mfa reset", "email", email)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "two-factor authentication reset"})
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/oidc"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// OIDCController signs users in at OpenID Connect providers. Identities are
+// linked to the user with the email the provider verified, users signing in
+// for the first time get an account. The login then goes on like a login
+// with a password, second factor included.
+type OIDCController struct {
+	providers  map[string]*oidc.Provider
+	states     store.OIDCStateRepository
+	identities store.IdentityRepository
+	users      store.UserRepository
+	auth       *AuthController
+	stateTTL   time.Duration
+	logger     *slog.Logger
+}
+
+func NewOIDCController(p []*oidc.Provider, s store.OIDCStateRepository, i store.IdentityRepository, u store.UserRepository, a *AuthController, stateTTL time.Duration, l *slog.Logger) *OIDCController {
+	providers := make(map[string]*oidc.Provider, len(p))
+	for _, provider := range p {
+		providers[provider.Name()] = provider
+	}
+	return &OIDCController{providers: providers, states: s, identities: i, users: u, auth: a, stateTTL: stateTTL, logger: l}
+}
+
+// Providers lists the providers users may sign in with
+func (o *OIDCController) Providers(c *fiber.Ctx) error {
+	names := make([]string, 0, len(o.providers))
+	for name := range o.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"providers": names})
+}
+
+// Authorize sends the user to the provider to sign in
+func (o *OIDCController) Authorize(c *fiber.Ctx) error {
+	provider, ok := o.providers[c.Params("provider")]
+	if !ok {
+		return errors.ErrOIDCProviderNotFound
+	}
+	state, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	nonce, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	verifier, challenge, err := oidc.NewVerifier()
+	if err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	target, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return errors.ErrOIDCProvider.Wrap(err)
+	}
+	err = o.states.Create(ctx, models.OIDCState{
+		StateHash: utils.HashToken(state),
+		Provider:  provider.Name(),
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(o.stateTTL).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(target, http.StatusFound)
+}
+
+// Callback completes the sign-in the provider sent the user back from
+func (o *OIDCController) Callback(c *fiber.Ctx) error {
+	provider, ok := o.providers[c.Params("provider")]
+	if !ok {
+		return errors.ErrOIDCProviderNotFound
+	}
+	ctx := c.UserContext()
+	state, err := o.states.Consume(ctx, utils.HashToken(c.Query("state")), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if state.Provider != provider.Name() {
+		return errors.ErrInvalidOIDCState
+	}
+	if reason := c.Query("error"); reason != "" {
+		o.logger.InfoContext(ctx, "sign-in refused by the identity provider", "provider", provider.Name(), "error", reason)
+		return errors.ErrOIDCLoginFailed
+	}
+
+	claims, err := provider.Exchange(ctx, c.Query("code"), state.Verifier, state.Nonce)
+	if errors.Is(err, oidc.ErrRejected) {
+		o.logger.WarnContext(ctx, "sign-in rejected", "provider", provider.Name(), "error", err)
+		return errors.ErrOIDCLoginFailed.Wrap(err)
+	}
+	if err != nil {
+		return errors.ErrOIDCProvider.Wrap(err)
+	}
+	user, err := o.user(ctx, provider.Name(), claims)
+	if err != nil {
+		return err
+	}
+
+	stage, err := o.auth.mfa.Challenge(ctx, user)
+	if err != nil {
+		return err
+	}
+	if stage != "" {
+		return o.auth.mfa.RespondPending(c, user.Email, stage)
+	}
+	if err := o.auth.logins.Succeeded(ctx, o.auth.logins.NewAttempt(c, user.Email)); err != nil {
+		return err
+	}
+
+	return o.auth.issueTokens(c, user, fiber.Map{"provider": provider.Name()})
+}
+
+// user returns the user of the identity of claims, linking it by the email
+// the provider verified or creating the user when it signs in for the
+// first time
+func (o *OIDCController) user(ctx context.Context, provider string, claims oidc.Claims) (models.User, error) {
+	identity, err := o.identities.Get(ctx, provider, claims.Subject)
+	switch {
+	case err == nil:
+		user, err := o.users.GetUserByEmail(ctx, identity.Email)
+		if err == nil {
+			_, err = o.identities.Link(ctx, identity, time.Now())
+			return user, err
+		}
+		if !errors.Is(err, errors.ErrUserNotFound) {
+			return models.User{}, err
+		}
+		// the user was deleted, the identity is linked again below
+	case !errors.Is(err, errors.ErrIdentityNotFound):
+		return models.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return models.User{}, errors.ErrOIDCEmailUnverified
+	}
+	user, err := o.users.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case errors.Is(err, errors.ErrUserNotFound):
+		user, err = o.createUser(ctx, claims)
+		if err != nil {
+			return models.User{}, err
+		}
+	case err != nil:
+		return models.User{}, err
+	case user.Unverified:
+		// whoever registered the unverified account did not prove they own
+		// the email, their password must not keep access to it
+		if err := o.takeOver(ctx, user.Email); err != nil {
+			return models.User{}, err
+		}
+		user.Unverified = false
+	}
+
+	_, err = o.identities.Link(ctx, models.Identity{Provider: provider, Subject: claims.Subject, Email: user.Email}, time.Now())
+	if err != nil {
+		return models.User{}, err
+	}
+	o.logger.InfoContext(ctx, "identity linked", "provider", provider, "email", user.Email)
+
+	return user, nil
+}
+
+// createUser creates the user of a first sign-in, the password is random
+// until the user resets it
+func (o *OIDCController) createUser(ctx context.Context, claims oidc.Claims) (models.User, error) {
+	password, err := utils.GenerateToken()
+	if err != nil {
+		return models.User{}, err
+	}
+	first, last := claims.GivenName, claims.FamilyName
+	if first == "" && last == "" {
+		first, last, _ = strings.Cut(claims.Name, " ")
+	}
+	userName := alphanumeric(claims.PreferredUsername)
+	if len(userName) < 3 {
+		local, _, _ := strings.Cut(claims.Email, "@")
+		userName = alphanumeric(local)
+	}
+	return o.users.CreaterUser(ctx, models.User{
+		FirstName: first,
+		Lastname:  last,
+		UserName:  userName,
+		Email:     claims.Email,
+		Password:  password,
+		Role:      constant.UserRole,
+	})
+}
+
+// takeOver hands the unverified account of email to the owner of the email
+func (o *OIDCController) takeOver(ctx context.Context, email string) error {
+	password, err := utils.GenerateToken()
+	if err != nil {
+		return err
+	}
+	if err := o.users.SetPassword(ctx, email, password); err != nil {
+		return err
+	}
+	if _, err := o.users.MarkVerified(ctx, email); err != nil {
+		return err
+	}
+	_, err = o.auth.sessions.EndAll(ctx, email, "")
+	return err
+}
+
+// alphanumeric keeps the letters and digits of s, at most 30
+func alphanumeric(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) && b.Len() < 30 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/payments"
+	"github.com/tabed23/travel-api/policy"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Currency charged for bookings
+const Currency = "USD"
+
+type PaymentController struct {
+	s        store.PaymentRepository
+	bookings store.BookingRepository
+	gateway  payments.Gateway
+	metrics  *metrics.Metrics
+	logger   *slog.Logger
+}
+
+func NewPaymentController(s store.PaymentRepository, b store.BookingRepository, g payments.Gateway, m *metrics.Metrics, l *slog.Logger) *PaymentController {
+	return &PaymentController{s: s, bookings: b, gateway: g, metrics: m, logger: l}
+}
+
+// StartPayment creates a payment intent for the total price of a booking
+func (p *PaymentController) StartPayment(c *fiber.Ctx) error {
+	booking, err := p.bookings.GetBooking(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := policy.CanAccessBooking(middleware.GetClaims(c), booking); err != nil {
+		return err
+	}
+	if !booking.Status.CanTransitionTo(models.BookingConfirmed) {
+		return errors.ErrInvalidTransition
+	}
+	if booking.HoldExpiresAt != nil && booking.HoldExpiresAt.Before(time.Now()) {
+		return errors.ErrHoldExpired
+	}
+	var input models.StartPayment
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	intent, err := p.gateway.CreateIntent(c.UserContext(), payments.IntentRequest{
+		Amount:        booking.TotalPrice,
+		Currency:      Currency,
+		Reference:     booking.ID.Hex(),
+		PaymentMethod: input.PaymentMethod,
+	})
+	if err != nil {
+		return gatewayError(err)
+	}
+	payment, err := p.s.Create(c.UserContext(), models.Payment{
+		BookingID: booking.ID,
+		UserEmail: booking.UserEmail,
+		Provider:  p.gateway.Name(),
+		IntentID:  intent.ID,
+		Amount:    intent.Amount,
+		Currency:  intent.Currency,
+		Status:    models.PaymentPending,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "payment started", "data": payment})
+}
+
+// ConfirmPayment captures a pending payment and confirms its booking on success
+func (p *PaymentController) ConfirmPayment(c *fiber.Ctx) error {
+	payment, err := p.s.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := policy.CanAccessUser(middleware.GetClaims(c), payment.UserEmail); err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentPending {
+		return errors.ErrPaymentNotPending.Withf("payment is %v", payment.Status)
+	}
+
+	intent, err := p.gateway.Capture(c.UserContext(), payment.IntentID)
+	if errors.Is(err, payments.ErrDeclined) {
+		if _, err := p.s.UpdateStatus(c.UserContext(), payment.ID, models.PaymentPending, models.PaymentFailed, err.Error()); err != nil {
+			return err
+		}
+		return errors.ErrPaymentDeclined
+	}
+	if err != nil {
+		return gatewayError(err)
+	}
+	if intent.Status != payments.IntentSucceeded {
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{"success": "payment processing", "data": payment})
+	}
+
+	if err := p.settle(c.UserContext(), payment); err != nil {
+		return err
+	}
+	payment, err = p.s.Get(c.UserContext(), payment.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if payment.Status == models.PaymentRefunded {
+		return errors.ErrHoldExpired.Withf("booking hold expired, payment %v was refunded", payment.ID.Hex())
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "payment succeeded", "data": payment})
+}
+
+func (p *PaymentController) GetPayment(c *fiber.Ctx) error {
+	payment, err := p.s.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if err := policy.CanAccessUser(middleware.GetClaims(c), payment.UserEmail); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"payment": payment})
+}
+
+// Webhook receives asynchronous notifications from the payment provider
+func (p *PaymentController) Webhook(c *fiber.Ctx) error {
+	if err := p.ProcessWebhook(c.UserContext(), c.Body(), c.Get(payments.SignatureHeader)); err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			return errors.ErrInvalidSignature
+		}
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"received": true})
+}
+
+// ProcessWebhook verifies and applies a webhook payload
+func (p *PaymentController) ProcessWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := p.gateway.VerifyWebhook(payload, signature)
+	if err != nil {
+		p.logger.WarnContext(ctx, "invalid webhook", "error", err)
+		return err
+	}
+	payment, err := p.s.GetByIntent(ctx, event.IntentID)
+	if err != nil {
+		return err
+	}
+	p.logger.InfoContext(ctx, "webhook received", "event", event.Type, "payment", payment.ID.Hex())
+
+	switch event.Status {
+	case payments.IntentSucceeded:
+		return p.settle(ctx, payment)
+	case payments.IntentDeclined:
+		_, err := p.s.UpdateStatus(ctx, payment.ID, models.PaymentPending, models.PaymentFailed, payments.ErrDeclined.Error())
+		return err
+	}
+	return nil
+}
+
+// settle marks a payment as succeeded and confirms its booking, only the
+// first caller to settle a payment confirms the booking. A payment that
+// arrives after the seat hold expired is refunded in full.
+func (p *PaymentController) settle(ctx context.Context, payment models.Payment) error {
+	changed, err := p.s.UpdateStatus(ctx, payment.ID, models.PaymentPending, models.PaymentSucceeded, "")
+	if err != nil || !changed {
+		return err
+	}
+	p.metrics.Revenue(payment.Currency, payment.Amount)
+	note := fmt.Sprintf("paid with %v intent %v", payment.Provider, payment.IntentID)
+	_, err = p.bookings.Transition(ctx, payment.BookingID.Hex(), models.BookingConfirmed, "payment:"+payment.Provider, note)
+	if errors.Is(err, errors.ErrHoldExpired) || errors.Is(err, errors.ErrInvalidTransition) {
+		p.logger.WarnContext(ctx, "booking can not be confirmed, refunding", "booking", payment.BookingID.Hex(), "error", err)
+		if err := p.s.SetRefundPending(ctx, payment.ID, payment.Amount); err != nil {
+			return err
+		}
+		if _, err := p.refund(ctx, payment.ID); err != nil {
+			return errors.ErrRefundPending.Wrap(err)
+		}
+		return nil
+	}
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not confirm booking", "booking", payment.BookingID.Hex(), "error", err)
+		return err
+	}
+	return nil
+}
+
+// refundLease is how long a claimed refund is reserved for the caller sending
+// it to the provider, it must outlast a provider call
+const refundLease = 5 * time.Minute
+
+// RefundBooking refunds the refund amount of a cancelled booking to the
+// payment that paid for it. The refund is recorded as pending on the payment
+// first, when the provider fails it stays pending for RetryRefunds and
+// ErrRefundPending is returned.
+func (p *PaymentController) RefundBooking(ctx context.Context, booking models.Booking) error {
+	if booking.RefundAmount <= 0 {
+		return nil
+	}
+	payment, err := p.s.GetSucceededByBooking(ctx, booking.ID)
+	if errors.Is(err, errors.ErrPaymentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.s.SetRefundPending(ctx, payment.ID, booking.RefundAmount); err != nil {
+		return err
+	}
+	if _, err := p.refund(ctx, payment.ID); err != nil {
+		return errors.ErrRefundPending.Wrap(err)
+	}
+	return nil
+}
+
+// RetryRefunds refunds the payments whose refund is still pending, a refund
+// failing again stays pending for the next run. It returns the number of
+// refunds that went through.
+func (p *PaymentController) RetryRefunds(ctx context.Context) (int, error) {
+	pending, err := p.s.GetRefundPending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	refunded := 0
+	for _, payment := range pending {
+		if ok, err := p.refund(ctx, payment.ID); err != nil || !ok {
+			continue
+		}
+		refunded++
+	}
+	return refunded, nil
+}
+
+// refund claims the pending refund of a payment and pays it back, recording
+// why the provider failed it on the payment. It reports false without an
+// error when another caller holds the claim, that caller sends the refund.
+func (p *PaymentController) refund(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	payment, claimed, err := p.s.ClaimRefund(ctx, id, time.Now().UTC(), refundLease)
+	if err != nil || !claimed {
+		return false, err
+	}
+	amount := payment.RefundPending
+	if _, err := p.gateway.Refund(ctx, payment.IntentID, amount); err != nil {
+		p.logger.ErrorContext(ctx, "refund failed", "payment", payment.ID.Hex(), "booking", payment.BookingID.Hex(), "attempt", payment.RefundAttempts, "error", err)
+		if err := p.s.ReleaseRefund(ctx, payment.ID, payment.RefundClaim, err.Error()); err != nil {
+			p.logger.ErrorContext(ctx, "recording the failed refund failed", "payment", payment.ID.Hex(), "error", err)
+		}
+		return false, err
+	}
+	p.metrics.Refund(payment.Currency, amount)
+	recorded, err := p.s.AddRefund(ctx, payment.ID, payment.RefundClaim, amount, payment.RefundedAmount+amount >= payment.Amount)
+	if err != nil {
+		return false, err
+	}
+	if !recorded {
+		p.logger.ErrorContext(ctx, "refund lease ran out before the refund was recorded", "payment", payment.ID.Hex(), "amount", amount)
+	}
+	return true, nil
+}
+
+// gatewayError maps payment provider failures to domain errors
+func gatewayError(err error) error {
+	switch {
+	case errors.Is(err, payments.ErrDeclined):
+		return errors.ErrPaymentDeclined
+	case errors.Is(err, payments.ErrInvalidAmount):
+		return errors.ErrBadRequest.Wrap(err)
+	}
+	return errors.ErrPaymentGateway.Wrap(err)
+}
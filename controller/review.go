@@ -3,82 +3,55 @@ package controller
 import (
 	"log/slog"
 	"net/http"
-	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/middleware"
 	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/policy"
 	"github.com/tabed23/travel-api/repository/store"
-	"github.com/tabed23/travel-api/utils"
-	"github.com/tabed23/travel-api/utils/constant"
 	"github.com/tabed23/travel-api/utils/errors"
 )
 
 type ReviewController struct {
-	s store.ReviewStore
-	logger *slog.Logger
+	s       store.ReviewRepository
+	metrics *metrics.Metrics
+	logger  *slog.Logger
 }
 
-func NewReviewController(s store.ReviewStore, l *slog.Logger) *ReviewController {
-	return &ReviewController{s: s, logger: l}
+func NewReviewController(s store.ReviewRepository, m *metrics.Metrics, l *slog.Logger) *ReviewController {
+	return &ReviewController{s: s, metrics: m, logger: l}
 }
 
 func (r *ReviewController) CreateReview(c *fiber.Ctx) error {
 
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	tourId := c.Params("id")
-	var review models.Review
-	if err := c.BodyParser(&review); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	var input models.ReviewInput
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
-	validateErr := validate.Struct(review)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
 
-	}
+	review := input.Review()
+	review.UserEmail = middleware.GetClaims(c).Email
 
-	res, err := r.s.CreateReviw(tourId, review)
+	res, err := r.s.CreateReviw(c.UserContext(), tourId, review)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
 	}
+	r.metrics.ReviewPosted()
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "review created successfully", "data": res})
 }
 
 func (r *ReviewController) Get(c *fiber.Ctx) error {
-
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
+	pageInt, limitInt, err := pagination(c)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	page := c.Query("page", "1")
-	limit := c.Query("limit", "10")
 
-	pageInt, err := strconv.Atoi(page)
+	reviews, total, err := r.s.GetAll(c.UserContext(), pageInt, limitInt)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page number"})
-	}
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
-	}
-
-	reviews, total, err := r.s.GetAll(pageInt, limitInt)
-	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
@@ -89,40 +62,32 @@ func (r *ReviewController) Get(c *fiber.Ctx) error {
 }
 
 func (r *ReviewController) Delete(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
+	tourid := c.Params("tourid")
+	reviewid := c.Params("reviewid")
+	review, err := r.s.GetOne(c.UserContext(), reviewid)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	// the review would be deleted while its id stays on its real tour
+	if review.TourID.Hex() != tourid {
+		return errors.ErrReviewNotFound
 	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
+	if err := policy.CanModifyReview(middleware.GetClaims(c), review); err != nil {
+		return err
 	}
-	reviewid := c.Params("tourid")
-	tourid := c.Params("reviewid")
-	ok, err := r.s.Delete(reviewid, tourid)
+	ok, err := r.s.Delete(c.UserContext(), tourid, reviewid)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	if ok {
-		return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 
-	}
-	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 }
 
 func (r *ReviewController) GetReview(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrInValidRole})
-	}
 	id := c.Params("id")
-	res, err := r.s.GetOne(id)
+	res, err := r.s.GetOne(c.UserContext(), id)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"tour": res})
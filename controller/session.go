@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// SessionController records the logins of users as sessions, lets users
+// see and sign out their sessions and admins sign out users. The access
+// tokens of a login carry its session, so they stop working the moment it
+// is revoked, like its refresh tokens.
+type SessionController struct {
+	sessions store.SessionRepository
+	tokens   store.RefreshTokenRepository
+	logger   *slog.Logger
+}
+
+func NewSessionController(s store.SessionRepository, t store.RefreshTokenRepository, l *slog.Logger) *SessionController {
+	return &SessionController{sessions: s, tokens: t, logger: l}
+}
+
+// Start records a login of email by the client of c, the session lasts
+// until expiresAt unless its refresh token extends it
+func (s *SessionController) Start(c *fiber.Ctx, email string, expiresAt time.Time) (models.Session, error) {
+	// fiber reuses the buffers of its strings once the request is done,
+	// the session outlives it
+	return s.sessions.Create(c.UserContext(), models.Session{
+		UserEmail: email,
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+		IP:        strings.Clone(c.IP()),
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
+
+// Active returns the session id of email, unknown, revoked and expired
+// sessions get the same answer
+func (s *SessionController) Active(ctx context.Context, id, email string) (models.Session, error) {
+	session, err := s.sessions.Get(ctx, id)
+	if errors.Is(err, errors.ErrSessionNotFound) {
+		return models.Session{}, errors.ErrSessionRevoked
+	}
+	if err != nil {
+		return models.Session{}, err
+	}
+	if session.UserEmail != email || !session.Active(time.Now()) {
+		return models.Session{}, errors.ErrSessionRevoked
+	}
+	return session, nil
+}
+
+// Check refuses the access tokens whose session is no longer active and
+// records the activity of the others from ip. The activity is only written
+// when the recorded one is older than store.SessionTouchInterval or came
+// from another address, so most requests cost a single read.
+func (s *SessionController) Check(ctx context.Context, claims *utils.Claims, ip string) error {
+	session, err := s.Active(ctx, claims.Session, claims.Email)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if session.LastSeenIP == ip && now.Sub(session.LastSeenAt) < store.SessionTouchInterval {
+		return nil
+	}
+	// a failed write of the activity must not fail the request
+	if err := s.sessions.Touch(ctx, session.ID, strings.Clone(ip), now); err != nil {
+		s.logger.ErrorContext(ctx, "recording session activity failed", "session", session.ID.Hex(), "error", err)
+	}
+	return nil
+}
+
+// Extend keeps session alive until expiresAt, its refresh token was
+// rotated by the client of c
+func (s *SessionController) Extend(c *fiber.Ctx, session models.Session, expiresAt time.Time) error {
+	return s.sessions.Extend(c.UserContext(), session.ID, strings.Clone(c.IP()), time.Now(), expiresAt)
+}
+
+// End revokes the session id of email and its refresh tokens
+func (s *SessionController) End(ctx context.Context, email, id string) (models.Session, error) {
+	session, err := s.sessions.Revoke(ctx, email, id)
+	if err != nil {
+		return models.Session{}, err
+	}
+	if err := s.tokens.RevokeFamily(ctx, id); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// EndAll revokes every session of email but except and their refresh
+// tokens, an empty except signs the user out of every device
+func (s *SessionController) EndAll(ctx context.Context, email, except string) (int, error) {
+	var others []models.Session
+	if except != "" {
+		active, err := s.sessions.GetActive(ctx, email, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		others = active
+	}
+	revoked, err := s.sessions.RevokeAll(ctx, email, except)
+	if err != nil {
+		return 0, err
+	}
+	// refresh tokens of revoked sessions are refused anyway, revoking them
+	// keeps the record straight
+	if except == "" {
+		if err := s.tokens.RevokeUser(ctx, email); err != nil {
+			return 0, err
+		}
+	}
+	for _, other := range others {
+		if id := other.ID.Hex(); id != except {
+			if err := s.tokens.RevokeFamily(ctx, id); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return revoked, nil
+}
+
+// Mine lists the active sessions of the caller, marking the one of the call
+func (s *SessionController) Mine(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	sessions, err := s.sessions.GetActive(c.UserContext(), claims.Email, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.Hex() == claims.Session
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"sessions": sessions})
+}
+
+// RevokeMine signs the caller out of one of their sessions
+func (s *SessionController) RevokeMine(c *fiber.Ctx) error {
+	res, err := s.End(c.UserContext(), middleware.GetClaims(c).Email, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "session signed out", "data": res})
+}
+
+// RevokeOthers signs the caller out of every session but the one of the call
+func (s *SessionController) RevokeOthers(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	// an API key has no session to keep
+	if claims.Session == "" {
+		return errors.ErrAPIKeyScope
+	}
+	revoked, err := s.EndAll(c.UserContext(), claims.Email, claims.Session)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "other sessions signed out", "revoked": revoked})
+}
+
+// UserSessions lists the active sessions of a user
+func (s *SessionController) UserSessions(c *fiber.Ctx) error {
+	sessions, err := s.sessions.GetActive(c.UserContext(), c.Params("email"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"sessions": sessions})
+}
+
+// ForceLogout signs a user out of every session
+func (s *SessionController) ForceLogout(c *fiber.Ctx) error {
+	email := c.Params("email")
+	revoked, err := s.EndAll(c.UserContext(), email, "")
+	if err != nil {
+		return err
+	}
+	s.logger.InfoContext(c.UserContext(), "user signed out by an admin", "email", email, "revoked", revoked)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "user signed out of every session", "revoked": revoked})
+}
@@ -8,106 +8,56 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/tabed23/travel-api/models"
 	"github.com/tabed23/travel-api/repository/store"
-	"github.com/tabed23/travel-api/utils"
-	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
 )
 
 type TourController struct {
-	s store.TourStore
+	s      store.TourRepository
 	logger *slog.Logger
 }
 
-func NewTourController(s store.TourStore, l *slog.Logger) *TourController {
+func NewTourController(s store.TourRepository, l *slog.Logger) *TourController {
 	return &TourController{s: s, logger: l}
 }
 
 func (t *TourController) CreateTour(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	var tour models.Tour
-	if err := c.BodyParser(&tour); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-	validateErr := validate.Struct(tour)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
-
+	var input models.TourInput
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	res, err := t.s.CreateTour(tour)
+	res, err := t.s.CreateTour(c.UserContext(), input.Tour())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "tour created successfully", "data": res})
 }
 
 func (t *TourController) UpdateTour(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 
-	var tour models.Tour
+	var input models.TourInput
 	id := c.Params("id")
-	if err := c.BodyParser(&tour); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	validateErr := validate.Struct(tour)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
-
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
-	res, err := t.s.Update(id, tour)
+	res, err := t.s.Update(c.UserContext(), id, input.Tour())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "tour created successfully", "data": res})
 }
 
 func (t *TourController) Get(c *fiber.Ctx) error {
-
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	page := c.Query("page", "1")
-	limit := c.Query("limit", "10")
-
-	pageInt, err := strconv.Atoi(page)
+	pageInt, limitInt, err := pagination(c)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page number"})
-	}
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+		return err
 	}
 
-	tours, total, err := t.s.GetAll(pageInt, limitInt)
+	tours, total, err := t.s.GetAll(c.UserContext(), pageInt, limitInt)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
@@ -118,107 +68,65 @@ func (t *TourController) Get(c *fiber.Ctx) error {
 }
 
 func (t *TourController) Delete(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	id := c.Params("id")
 
-	ok, err := t.s.DeleteTour(id)
+	ok, err := t.s.DeleteTour(c.UserContext(), id)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	if ok {
-		return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 
-	}
-	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 }
 
 func (t *TourController) GetTour(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	id := c.Params("id")
-	res, err := t.s.Get(id)
+	res, err := t.s.Get(c.UserContext(), id)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"tour": res})
 }
 
 func (t *TourController) SearchTour(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	city := c.Query("city")
 	dist := c.Query("distance")
 	group := c.Query("maxGroupSize")
 
 	var distance, maxGroupSize int
+	var err error
 	if dist != "" {
 		distance, err = strconv.Atoi(dist)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid distance"})
+			return errors.Validation(errors.FieldError{Field: "distance", Code: "number", Message: "distance must be a number"})
 		}
 	}
 	if group != "" {
 		maxGroupSize, err = strconv.Atoi(group)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maxGroupSize"})
+			return errors.Validation(errors.FieldError{Field: "maxGroupSize", Code: "number", Message: "maxGroupSize must be a number"})
 		}
 	}
-	tours, err := t.s.SearchTour(city, float32(distance), maxGroupSize)
+	tours, err := t.s.SearchTour(c.UserContext(), city, float32(distance), maxGroupSize)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 	return c.Status(http.StatusOK).JSON(fiber.Map{"tour": tours})
 
 }
 
 func (t *TourController) ShowFeaturedTour(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	feature, err := t.s.FeaturedTour()
+	feature, err := t.s.FeaturedTour(c.UserContext())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 	return c.Status(http.StatusOK).JSON(fiber.Map{"featured": feature})
 }
 
 func (t *TourController) CountTotalTours(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	totalTours, err := t.s.CountTours()
+	totalTours, err := t.s.CountTours(c.UserContext())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 	return c.Status(http.StatusOK).JSON(fiber.Map{"total": totalTours})
 }
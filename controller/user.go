@@ -3,10 +3,12 @@ package controller
 import (
 	"log/slog"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
 	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/policy"
 	"github.com/tabed23/travel-api/repository/store"
 	"github.com/tabed23/travel-api/utils"
 	"github.com/tabed23/travel-api/utils/constant"
@@ -14,99 +16,112 @@ import (
 )
 
 type UserController struct {
-	s      store.UserStore
-	logger *slog.Logger
+	s          store.UserRepository
+	identities store.IdentityRepository
+	sessions   *SessionController
+	logger     *slog.Logger
 }
 
-func NewUserController(s store.UserStore, l *slog.Logger) *UserController {
-	return &UserController{s: s, logger: l}
+func NewUserController(s store.UserRepository, i store.IdentityRepository, sessions *SessionController, l *slog.Logger) *UserController {
+	return &UserController{s: s, identities: i, sessions: sessions, logger: l}
 }
 
 func (u *UserController) CreateUser(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
-	var usr models.User
-	if err := c.BodyParser(&usr); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	var input models.UserCreate
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
-	validateErr := validate.Struct(usr)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
-
+	usr := input.User()
+	usr.Role = input.Role
+	if usr.Role == "" {
+		usr.Role = constant.UserRole
 	}
 
-	res, err := u.s.CreaterUser(usr)
+	res, err := u.s.CreaterUser(c.UserContext(), usr)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "user created successfully", "data": res})
 }
 
 func (u *UserController) UpdateUser(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": errors.ErrUnAuthorized})
-	}
-	var usr models.UserUpdate
-	id := c.Params("email")
-	if err := c.BodyParser(&usr); err != nil {
-
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	email := c.Params("email")
+	if err := policy.CanAccessUser(middleware.GetClaims(c), email); err != nil {
+		return err
 	}
-	validateErr := validate.Struct(usr)
-
-	if validateErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validateErr.Error()})
+	return u.updateUser(c, email)
+}
 
-	}
+// UpdateMe updates the profile of the caller
+func (u *UserController) UpdateMe(c *fiber.Ctx) error {
+	return u.updateUser(c, middleware.GetClaims(c).Email)
+}
 
-	res, err := u.s.UpdateUser(id, usr)
+// updateUser updates the profile of id. Users changing their own password
+// must send the current one and are signed out of their other sessions, a
+// password set by an admin signs the user out of every session.
+func (u *UserController) updateUser(c *fiber.Ctx, id string) error {
+	var usr models.UserUpdate
+	if err := parseBody(c, &usr); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	claims := middleware.GetClaims(c)
+	self := strings.EqualFold(claims.Email, id)
+	if usr.Password != "" && self {
+		current, err := u.s.GetUserByEmail(ctx, id)
+		if err != nil {
+			return err
+		}
+		if utils.VerifyPassword(current.Password, usr.CurrentPassword) != nil {
+			return errors.ErrInvalidPassword
+		}
+	}
+	res, err := u.s.UpdateUser(ctx, id, usr)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-
+		return err
+	}
+	if usr.Password != "" {
+		keep := ""
+		if self {
+			keep = claims.Session
+		}
+		if _, err := u.sessions.EndAll(ctx, res.Email, keep); err != nil {
+			return err
+		}
+		u.logger.InfoContext(ctx, "password changed", "email", res.Email)
 	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{"success": "user updated successfully", "data": res})
 }
 
-func (u *UserController) Get(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
+// UpdateRole changes the role of a user, it is routed for admins only
+func (u *UserController) UpdateRole(c *fiber.Ctx) error {
+	var input models.RoleUpdate
+	email := c.Params("email")
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
-	page := c.Query("page", "1")
-	limit := c.Query("limit", "10")
 
-	pageInt, err := strconv.Atoi(page)
+	res, err := u.s.UpdateRole(c.UserContext(), email, input.Role)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid page number"})
+		return err
 	}
-	limitInt, err := strconv.Atoi(limit)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "user role updated successfully", "data": res})
+}
+
+func (u *UserController) Get(c *fiber.Ctx) error {
+	pageInt, limitInt, err := pagination(c)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit"})
+		return err
 	}
 
-	users, total, err := u.s.GetAll(pageInt, limitInt)
+	users, total, err := u.s.GetAll(c.UserContext(), pageInt, limitInt)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"success": "true",
@@ -118,41 +133,48 @@ func (u *UserController) Get(c *fiber.Ctx) error {
 }
 
 func (t *UserController) Delete(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	email := c.Params("email")
+	if err := policy.CanAccessUser(middleware.GetClaims(c), email); err != nil {
+		return err
+	}
 
-	ok, err := t.s.Delete(email)
+	ok, err := t.s.Delete(c.UserContext(), email)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	if ok {
-		return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 
-	}
-	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+	return c.Status(http.StatusOK).JSON(fiber.Map{"success": ok})
 }
 
 func (u *UserController) GetUser(c *fiber.Ctx) error {
-	bearerToken := utils.ExtractToken(c)
-	claims, err := utils.ParseToken(bearerToken)
-	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-	}
-	if claims.Role != constant.UserRole && claims.Role != constant.AdminRole {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"status": fiber.StatusUnauthorized, "message": "invalid role"})
-	}
 	email := c.Params("email")
-	res, err := u.s.Get(email)
+	if err := policy.CanAccessUser(middleware.GetClaims(c), email); err != nil {
+		return err
+	}
+	return u.getUser(c, email)
+}
+
+// Me returns the profile of the caller
+func (u *UserController) Me(c *fiber.Ctx) error {
+	return u.getUser(c, middleware.GetClaims(c).Email)
+}
+
+func (u *UserController) getUser(c *fiber.Ctx, email string) error {
+	res, err := u.s.Get(c.UserContext(), email)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{"user": res})
 }
+
+// MyIdentities lists the identities at OpenID Connect providers linked to
+// the caller
+func (u *UserController) MyIdentities(c *fiber.Ctx) error {
+	identities, err := u.identities.GetByEmail(c.UserContext(), middleware.GetClaims(c).Email)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"identities": identities})
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// report fields by their JSON name, which is what clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// validateStruct validates v and describes each invalid field
+func validateStruct(v interface{}) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return errors.ErrBadRequest.Wrap(err)
+	}
+	fields := make([]errors.FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		fields = append(fields, errors.FieldError{
+			Field:   fe.Field(),
+			Code:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return errors.Validation(fields...)
+}
+
+// fieldMessage describes a failed validation rule in plain words
+func fieldMessage(fe validator.FieldError) string {
+	field, param := fe.Field(), fe.Param()
+	isNumber, isList := false, false
+	switch fe.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		isNumber = true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		isList = true
+	}
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "url":
+		return field + " must be a valid URL"
+	case "e164":
+		return field + " must be a phone number in E.164 format, like +14155552671"
+	case "alphanum":
+		return field + " may only contain letters and digits"
+	case "numeric":
+		return field + " may only contain digits"
+	case "len":
+		if isList {
+			return field + " must have " + items(param)
+		}
+		return field + " must be " + param + " characters long"
+	case "cidr|ip":
+		return field + " must be an IP address or a CIDR range"
+	case "required_without":
+		return field + " is required without " + snakeCase(param)
+	case "required_with":
+		return field + " is required with " + snakeCase(param)
+	case "oneof":
+		return field + " must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "gtfield":
+		return field + " must be after " + snakeCase(param)
+	case "min", "gte":
+		if isNumber {
+			return field + " must be at least " + param
+		}
+		if isList {
+			return field + " must have at least " + items(param)
+		}
+		return field + " must be at least " + param + " characters long"
+	case "max", "lte":
+		if isNumber {
+			return field + " must be at most " + param
+		}
+		if isList {
+			return field + " must have at most " + items(param)
+		}
+		return field + " must be at most " + param + " characters long"
+	case "gt":
+		if isNumber {
+			return field + " must be greater than " + param
+		}
+	case "lt":
+		if isNumber {
+			return field + " must be less than " + param
+		}
+	}
+	return field + " is invalid"
+}
+
+// items counts n items, n is the parameter of a validation tag
+func items(n string) string {
+	if n == "1" {
+		return n + " item"
+	}
+	return n + " items"
+}
+
+// snakeCase turns a Go field name like StartDate into start_date
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parseBody decodes the request body into v and validates it
+func parseBody(c *fiber.Ctx, v interface{}) error {
+	if err := c.BodyParser(v); err != nil {
+		return errors.ErrBadRequest.Wrap(err)
+	}
+	return validateStruct(v)
+}
+
+// MaxPageSize is the largest limit a listing accepts
+const MaxPageSize = 100
+
+// pagination reads the page and limit query parameters, limit is between 1
+// and MaxPageSize so that no listing returns a whole collection
+func pagination(c *fiber.Ctx) (int, int, error) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, errors.Validation(errors.FieldError{Field: "page", Code: "min", Message: "page must be a positive number"})
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit < 1 {
+		return 0, 0, errors.Validation(errors.FieldError{Field: "limit", Code: "min", Message: "limit must be a positive number"})
+	}
+	if limit > MaxPageSize {
+		return 0, 0, errors.Validation(errors.FieldError{Field: "limit", Code: "max", Message: "limit must be at most " + strconv.Itoa(MaxPageSize)})
+	}
+	return page, limit, nil
+}
@@ -3,31 +3,45 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 
+	"github.com/tabed23/travel-api/config"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type MongoDB struct {
-	db *mongo.Client
+	db   *mongo.Client
+	name string
 }
 
-func NewDatabase(monguri string) (*MongoDB, error) {
-
-	db, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(monguri))
+func NewDatabase(cfg config.Mongo) (*MongoDB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	// every command becomes a child span of the span of its context, the
+	// monitor uses the tracer provider installed when the client is built
+	opts := options.Client().
+		ApplyURI(cfg.URL).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetMonitor(otelmongo.NewMonitor())
+	db, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatal("error connecting to MongoDB")
-		return nil, err
+		return nil, fmt.Errorf("error connecting to MongoDB: %w", err)
 	}
 	fmt.Println("Connecting to MongoDB")
-	return &MongoDB{db}, nil
+	return &MongoDB{db: db, name: cfg.Database}, nil
 }
 
 func (m *MongoDB) Close() {
 	m.db.Disconnect(context.TODO())
 }
 
+// Ping checks that the primary answers
+func (m *MongoDB) Ping(ctx context.Context) error {
+	return m.db.Ping(ctx, readpref.Primary())
+}
+
 func (m *MongoDB) GetDB() *mongo.Database {
-	return m.db.Database("travle")
+	return m.db.Database(m.name)
 }
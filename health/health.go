@@ -0,0 +1,144 @@
+// Package health tracks whether the service is alive and ready to take
+// traffic, and describes the running build.
+package health
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one Check
+type Result struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is the readiness of the service and of each dependency
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+const (
+	StatusOK           = "ok"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Health runs the readiness checks of the service
+type Health struct {
+	timeout  time.Duration
+	mu       sync.RWMutex
+	checks   map[string]Check
+	stopping atomic.Bool
+}
+
+// New returns a Health whose checks each get timeout to answer
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Register adds the check of the dependency name
+func (h *Health) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// ShutDown marks the service as not ready, load balancers stop sending new
+// requests while the running ones finish
+func (h *Health) ShutDown() {
+	h.stopping.Store(true)
+}
+
+// Ready runs every check concurrently and reports whether all passed
+func (h *Health) Ready(ctx context.Context) (Report, bool) {
+	h.mu.RLock()
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			res := Result{Status: StatusOK, Latency: time.Since(start).String()}
+			if err != nil {
+				res.Status, res.Error = StatusUnavailable, err.Error()
+			}
+			mu.Lock()
+			report.Checks[name] = res
+			if err != nil {
+				report.Status = StatusUnavailable
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	// dependencies are still reported while shutting down to help debugging
+	if h.stopping.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report, report.Status == StatusOK
+}
+
+// Commit and BuildTime describe the build, they are set with
+// -ldflags "-X github.com/tabed23/travel-api/health.Commit=..." and fall
+// back to the VCS information the Go toolchain embeds
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Build describes the running binary
+type Build struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+}
+
+// BuildInfo returns the description of the running binary
+func BuildInfo() Build {
+	b := Build{Commit: Commit, BuildTime: BuildTime}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	b.GoVersion = info.GoVersion
+	b.Module = info.Main.Path
+	b.Version = info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value
+			}
+		case "vcs.time":
+			if b.BuildTime == "" {
+				b.BuildTime = s.Value
+			}
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/health"
+)
+
+func ok(context.Context) error { return nil }
+
+func down(context.Context) error { return errors.New("connection refused") }
+
+// hang answers once the check times out
+func hang(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks map[string]health.Check
+		ready  bool
+		failed map[string]string
+	}{
+		{"no checks", nil, true, nil},
+		{"all pass", map[string]health.Check{"mongo": ok, "mail": ok}, true, nil},
+		{"one fails", map[string]health.Check{"mongo": down, "mail": ok}, false, map[string]string{"mongo": "connection refused"}},
+		{"one hangs", map[string]health.Check{"mongo": hang, "mail": ok}, false, map[string]string{"mongo": context.DeadlineExceeded.Error()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := health.New(10 * time.Millisecond)
+			for name, check := range tt.checks {
+				h.Register(name, check)
+			}
+			report, ready := h.Ready(context.Background())
+			want := health.StatusOK
+			if !tt.ready {
+				want = health.StatusUnavailable
+			}
+			if ready != tt.ready || report.Status != want {
+				t.Errorf("ready %v status %q, want %v %q", ready, report.Status, tt.ready, want)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("%d checks reported, want %d", len(report.Checks), len(tt.checks))
+			}
+			for name, res := range report.Checks {
+				if msg, failed := tt.failed[name]; failed {
+					if res.Status != health.StatusUnavailable || res.Error != msg {
+						t.Errorf("%s: %+v, want unavailable with %q", name, res, msg)
+					}
+				} else if res.Status != health.StatusOK || res.Error != "" {
+					t.Errorf("%s: %+v, want ok", name, res)
+				}
+			}
+		})
+	}
+}
+
+func TestShutDown(t *testing.T) {
+	h := health.New(time.Second)
+	h.Register("mongo", ok)
+	if _, ready := h.Ready(context.Background()); !ready {
+		t.Fatalf("not ready before shutting down")
+	}
+
+	h.ShutDown()
+	report, ready := h.Ready(context.Background())
+	if ready || report.Status != health.StatusShuttingDown {
+		t.Errorf("ready %v status %q, want not ready %q", ready, report.Status, health.StatusShuttingDown)
+	}
+	// the dependencies are still reported
+	if res := report.Checks["mongo"]; res.Status != health.StatusOK {
+		t.Errorf("mongo %+v while shutting down, want ok", res)
+	}
+}
@@ -0,0 +1,228 @@
+// Package keyring holds the asymmetric keys signing the access tokens. A
+// new key is created every rotation period and published ahead of signing,
+// retired keys keep verifying until the last token they signed expired.
+// The keys are kept in a store shared by every instance.
+package keyring
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+)
+
+// Algorithms of the signing keys, named like the JWS alg header
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Algorithms lists the algorithms tokens may be signed with
+var Algorithms = []string{RS256, EdDSA}
+
+// ErrNoSigningKey is returned when the keyring has no key that may sign
+var ErrNoSigningKey = errors.New("keyring: no signing key")
+
+// Store persists the keys, a key with the ID of an existing one must be
+// refused with ErrKeyExists
+type Store interface {
+	Create(ctx context.Context, key models.SigningKey) error
+	GetValid(ctx context.Context, now time.Time) ([]models.SigningKey, error)
+}
+
+// ErrKeyExists is returned by Store.Create for a key another instance
+// created first
+var ErrKeyExists = errors.New("keyring: key exists")
+
+// Policy says how keys are made and rotated
+type Policy struct {
+	Algorithm string
+	// RotateEvery is how long each key signs
+	RotateEvery time.Duration
+	// Overlap is how long a key is published before it signs, and how long
+	// it may keep signing when its successor is late
+	Overlap time.Duration
+	// TokenTTL is the lifetime of the longest token signed, retired keys
+	// verify for that long
+	TokenTTL time.Duration
+}
+
+// Key is a signing key with its public half
+type Key struct {
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer
+	ActiveAt    time.Time
+	SignUntil   time.Time
+	VerifyUntil time.Time
+}
+
+// Public returns the public key of k
+func (k Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// Keyring is the set of keys of the store, refreshed by Rotate
+type Keyring struct {
+	store  Store
+	policy Policy
+	secret []byte
+
+	mu   sync.RWMutex
+	keys []Key
+}
+
+// New returns the keyring of s, private keys are sealed with secret
+func New(s Store, p Policy, secret string) *Keyring {
+	return &Keyring{store: s, policy: p, secret: []byte(secret)}
+}
+
+// Rotate loads the keys of the store and creates the next key once the
+// newest one is due for rotation within the overlap. Instances rotating
+// together agree on the ID of the next key, so only one of them stores it.
+func (k *Keyring) Rotate(ctx context.Context, now time.Time) error {
+	keys, err := k.load(ctx, now)
+	if err != nil {
+		return err
+	}
+	next := now
+	if len(keys) > 0 {
+		newest := keys[len(keys)-1]
+		due := newest.ActiveAt.Add(k.policy.RotateEvery)
+		if newest.Algorithm != k.policy.Algorithm {
+			// the algorithm was changed, switch to it right away
+			due = now
+		}
+		if now.Before(due.Add(-k.policy.Overlap)) {
+			k.set(keys)
+			return nil
+		}
+		if due.After(now) {
+			next = due
+		}
+	}
+	key, err := k.generate(next.UTC().Truncate(time.Second))
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(k.secret, key)
+	if err != nil {
+		return err
+	}
+	if err := k.store.Create(ctx, sealed); err != nil && !errors.Is(err, ErrKeyExists) {
+		return err
+	}
+	keys, err = k.load(ctx, now)
+	if err != nil {
+		return err
+	}
+	k.set(keys)
+	return nil
+}
+
+// load returns the valid keys of the store, oldest first
+func (k *Keyring) load(ctx context.Context, now time.Time) ([]Key, error) {
+	stored, err := k.store.GetValid(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(stored))
+	for _, s := range stored {
+		key, err := open(k.secret, s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActiveAt.Before(keys[j].ActiveAt)
+	})
+	return keys, nil
+}
+
+func (k *Keyring) set(keys []Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+}
+
+// generate returns a new key signing from activeAt
+func (k *Keyring) generate(activeAt time.Time) (Key, error) {
+	var private crypto.Signer
+	var err error
+	switch k.policy.Algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("keyring: unknown algorithm %q", k.policy.Algorithm)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	signUntil := activeAt.Add(k.policy.RotateEvery + k.policy.Overlap)
+	return Key{
+		ID:          k.policy.Algorithm + "-" + strconv.FormatInt(activeAt.Unix(), 10),
+		Algorithm:   k.policy.Algorithm,
+		Private:     private,
+		ActiveAt:    activeAt,
+		SignUntil:   signUntil,
+		VerifyUntil: signUntil.Add(k.policy.TokenTTL),
+	}, nil
+}
+
+// Signer returns the key to sign with at now, the newest active one. Before
+// the first key is active, at the first start, it signs right away.
+func (k *Keyring) Signer(now time.Time) (Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var signer *Key
+	for i := range k.keys {
+		key := &k.keys[i]
+		if !now.Before(key.SignUntil) {
+			continue
+		}
+		if !now.Before(key.ActiveAt) || signer == nil {
+			signer = key
+		}
+	}
+	if signer == nil {
+		return Key{}, ErrNoSigningKey
+	}
+	return *signer, nil
+}
+
+// Lookup returns the key with id that still verifies at now
+func (k *Keyring) Lookup(id string, now time.Time) (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == id && now.Before(key.VerifyUntil) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Keys returns the keys verifying at now, oldest first
+func (k *Keyring) Keys(now time.Time) []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		if now.Before(key.VerifyUntil) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
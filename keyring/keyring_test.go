@@ -0,0 +1,248 @@
+package keyring_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/repository/memory"
+	"github.com/tabed23/travel-api/utils"
+)
+
+const day = 24 * time.Hour
+
+// policy rotates monthly and publishes keys a day ahead
+var policy = keyring.Policy{Algorithm: keyring.RS256, RotateEvery: 30 * day, Overlap: day, TokenTTL: 30 * time.Minute}
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newStore returns an empty store of signing keys
+func newStore() keyring.Store {
+	return memory.New(0).Stores().SigningKeys
+}
+
+func rotate(t *testing.T, k *keyring.Keyring, now time.Time) {
+	t.Helper()
+	if err := k.Rotate(context.Background(), now); err != nil {
+		t.Fatalf("rotating at %v: %v", now, err)
+	}
+}
+
+func signer(t *testing.T, k *keyring.Keyring, now time.Time) keyring.Key {
+	t.Helper()
+	key, err := k.Signer(now)
+	if err != nil {
+		t.Fatalf("signer at %v: %v", now, err)
+	}
+	return key
+}
+
+func TestRotationOverlap(t *testing.T) {
+	k := keyring.New(newStore(), policy, "secret")
+	rotate(t, k, start)
+	first := signer(t, k, start)
+
+	// no new key before the overlap of the rotation
+	rotate(t, k, start.Add(28*day))
+	if got := len(k.Keys(start.Add(28 * day))); got != 1 {
+		t.Fatalf("%d keys before the overlap, want 1", got)
+	}
+
+	// the next key is published a day ahead and signs once it is due
+	published := start.Add(29*day + time.Hour)
+	rotate(t, k, published)
+	if got := len(k.Keys(published)); got != 2 {
+		t.Fatalf("%d keys in the overlap, want 2", got)
+	}
+	if got := signer(t, k, published); got.ID != first.ID {
+		t.Errorf("signer %s ahead of the rotation, want the first key %s", got.ID, first.ID)
+	}
+	due := start.Add(30 * day)
+	next := signer(t, k, due)
+	if next.ID == first.ID || !next.ActiveAt.Equal(due) {
+		t.Fatalf("signer %s active at %v once due, want a new key active at %v", next.ID, next.ActiveAt, due)
+	}
+
+	// the first key verifies the tokens it signed until they expired
+	if _, ok := k.Lookup(first.ID, first.SignUntil.Add(policy.TokenTTL-time.Second)); !ok {
+		t.Errorf("the first key stopped verifying before its last token expired")
+	}
+	retired := first.SignUntil.Add(policy.TokenTTL)
+	if _, ok := k.Lookup(first.ID, retired); ok {
+		t.Errorf("the first key still verifies after its last token expired")
+	}
+	if keys := k.Keys(retired); len(keys) != 1 || keys[0].ID != next.ID {
+		t.Errorf("keys once the first retired %v, want only %s", keys, next.ID)
+	}
+}
+
+func TestInstancesRotateToTheSameKey(t *testing.T) {
+	store := newStore()
+	a := keyring.New(store, policy, "secret")
+	b := keyring.New(store, policy, "secret")
+	rotate(t, a, start)
+	rotate(t, b, start)
+	if got, want := signer(t, b, start).ID, signer(t, a, start).ID; got != want {
+		t.Fatalf("instances sign with %s and %s, want the same key", got, want)
+	}
+
+	published := start.Add(29*day + time.Hour)
+	rotate(t, a, published)
+	rotate(t, b, published)
+	if got := len(b.Keys(published)); got != 2 {
+		t.Errorf("%d keys after both rotated, want 2", got)
+	}
+}
+
+func TestSealedKeysNeedTheSecret(t *testing.T) {
+	store := newStore()
+	rotate(t, keyring.New(store, policy, "secret"), start)
+	if err := keyring.New(store, policy, "other secret").Rotate(context.Background(), start); err == nil {
+		t.Errorf("keys sealed with another secret were opened")
+	}
+}
+
+func TestAlgorithmChangeRotatesAtOnce(t *testing.T) {
+	store := newStore()
+	k := keyring.New(store, policy, "secret")
+	rotate(t, k, start)
+	first := signer(t, k, start)
+
+	eddsa := policy
+	eddsa.Algorithm = keyring.EdDSA
+	k = keyring.New(store, eddsa, "secret")
+	now := start.Add(time.Hour)
+	rotate(t, k, now)
+	if got := signer(t, k, now); got.Algorithm != keyring.EdDSA {
+		t.Errorf("signer %s after the change, want an EdDSA key", got.ID)
+	}
+	if _, ok := k.Lookup(first.ID, now); !ok {
+		t.Errorf("the RS256 key stopped verifying")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	store := newStore()
+	rotate(t, keyring.New(store, policy, "secret"), start)
+	eddsa := policy
+	eddsa.Algorithm = keyring.EdDSA
+	k := keyring.New(store, eddsa, "secret")
+	rotate(t, k, start)
+
+	set := k.JWKS(start)
+	if len(set.Keys) != 2 {
+		t.Fatalf("%d keys, want 2", len(set.Keys))
+	}
+	for i, key := range k.Keys(start) {
+		jwk := set.Keys[i]
+		if jwk.ID != key.ID || jwk.Algorithm != key.Algorithm || jwk.Use != "sig" {
+			t.Errorf("jwk %+v, want key %s of %s for signatures", jwk, key.ID, key.Algorithm)
+		}
+		switch key.Algorithm {
+		case keyring.RS256:
+			if jwk.KeyType != "RSA" || jwk.E != "AQAB" || len(jwk.N) != 342 {
+				t.Errorf("RSA jwk %+v, want a 2048 bit modulus and exponent 65537", jwk)
+			}
+		case keyring.EdDSA:
+			if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || len(jwk.X) != 43 {
+				t.Errorf("Ed25519 jwk %+v, want a 32 byte public key", jwk)
+			}
+		}
+		// every key publishes its public half
+		if jwk.N == "" && jwk.X == "" {
+			t.Errorf("jwk %+v has no public key", jwk)
+		}
+	}
+}
+
+// tokenKeys returns the JWT of a keyring signing with an RS256 key and of
+// one that rotated to a newer EdDSA key since, which verifies both, and
+// the two keys
+func tokenKeys(t *testing.T) (rs256, eddsa *utils.JWT, rsKey, edKey keyring.Key) {
+	t.Helper()
+	store := newStore()
+	now := time.Now()
+	rs := keyring.New(store, policy, "secret")
+	rotate(t, rs, now.Add(-time.Hour))
+	edPolicy := policy
+	edPolicy.Algorithm = keyring.EdDSA
+	ed := keyring.New(store, edPolicy, "secret")
+	rotate(t, ed, now)
+	// the RS256 keyring is an instance that did not load the EdDSA key yet,
+	// it still signs with the RS256 key
+	return utils.NewJWT(rs, "travel-api", "travel-api", time.Minute, time.Hour),
+		utils.NewJWT(ed, "travel-api", "travel-api", time.Minute, time.Hour),
+		signer(t, rs, now.Add(-time.Hour)), signer(t, ed, now)
+}
+
+func TestTokensVerifyByKidAfterRotation(t *testing.T) {
+	before, after, rsKey, edKey := tokenKeys(t)
+	old, err := before.GenrateNewToken("user", "ada@example.com", "session")
+	if err != nil {
+		t.Fatalf("signing with the RS256 key: %v", err)
+	}
+	fresh, err := after.GenrateNewToken("user", "ada@example.com", "session")
+	if err != nil {
+		t.Fatalf("signing with the EdDSA key: %v", err)
+	}
+
+	for name, tt := range map[string]struct{ token, kid string }{
+		"before the rotation": {old, rsKey.ID},
+		"after the rotation":  {fresh, edKey.ID},
+	} {
+		token, _, err := new(jwt.Parser).ParseUnverified(tt.token, &utils.Claims{})
+		if err != nil || token.Header["kid"] != tt.kid {
+			t.Errorf("%s: kid %v, %v, want %s", name, token.Header["kid"], err, tt.kid)
+		}
+		claims, err := after.ParseToken(tt.token)
+		if err != nil || claims.Email != "ada@example.com" {
+			t.Errorf("%s: %+v, %v, want the token of ada", name, claims, err)
+		}
+	}
+}
+
+func TestTokensRefuseForeignAlgorithms(t *testing.T) {
+	_, j, rsKey, edKey := tokenKeys(t)
+	claims := utils.Claims{Role: "admin", Email: "mallory@example.com", StandardClaims: jwt.StandardClaims{
+		Issuer: "travel-api", Audience: "travel-api", ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("signing with %v: %v", method.Alg(), err)
+		}
+		return s
+	}
+	// the public key is known to anyone, a verifier trusting the alg header
+	// would check an HMAC with it
+	public, err := x509.MarshalPKIXPublicKey(rsKey.Public())
+	if err != nil {
+		t.Fatalf("encoding the public key: %v", err)
+	}
+
+	tests := map[string]string{
+		"none":                   sign(jwt.SigningMethodNone, rsKey.ID, jwt.UnsafeAllowNoneSignatureType),
+		"HS256 with the pub key": sign(jwt.SigningMethodHS256, rsKey.ID, public),
+		"EdDSA on the RS256 kid": sign(jwt.SigningMethodEdDSA, rsKey.ID, edKey.Private.(ed25519.PrivateKey)),
+		"RS256 on the EdDSA kid": sign(jwt.SigningMethodRS256, edKey.ID, rsKey.Private),
+		"unknown kid":            sign(jwt.SigningMethodEdDSA, "EdDSA-1", edKey.Private.(ed25519.PrivateKey)),
+		"no kid":                 sign(jwt.SigningMethodEdDSA, "", edKey.Private.(ed25519.PrivateKey)),
+	}
+	for name, token := range tests {
+		if claims, err := j.ParseToken(token); err == nil {
+			t.Errorf("%s: accepted as %+v", name, claims)
+		}
+	}
+	// the same claims verify when signed right
+	if _, err := j.ParseToken(sign(jwt.SigningMethodEdDSA, edKey.ID, edKey.Private.(ed25519.PrivateKey))); err != nil {
+		t.Errorf("correctly signed: %v", err)
+	}
+}
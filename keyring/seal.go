@@ -0,0 +1,83 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+)
+
+// aead returns the cipher sealing private keys, keyed by the SHA-256 of
+// secret
+func aead(secret []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256(secret)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns key to store, its PKCS #8 private key encrypted with secret.
+// The key ID is authenticated too, so sealed keys can not be swapped.
+func seal(secret []byte, key Key) (models.SigningKey, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	gcm, err := aead(secret)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return models.SigningKey{}, err
+	}
+	return models.SigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  gcm.Seal(nonce, nonce, der, []byte(key.ID)),
+		ActiveAt:    key.ActiveAt,
+		SignUntil:   key.SignUntil,
+		VerifyUntil: key.VerifyUntil,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// open returns the stored key s, decrypted with secret
+func open(secret []byte, s models.SigningKey) (Key, error) {
+	gcm, err := aead(secret)
+	if err != nil {
+		return Key{}, err
+	}
+	if len(s.PrivateKey) < gcm.NonceSize() {
+		return Key{}, fmt.Errorf("keyring: key %v is truncated", s.ID)
+	}
+	nonce, sealed := s.PrivateKey[:gcm.NonceSize()], s.PrivateKey[gcm.NonceSize():]
+	der, err := gcm.Open(nil, nonce, sealed, []byte(s.ID))
+	if err != nil {
+		return Key{}, fmt.Errorf("keyring: can not open key %v, was the JWT secret changed? %w", s.ID, err)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return Key{}, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("keyring: key %v can not sign", s.ID)
+	}
+	return Key{
+		ID:          s.ID,
+		Algorithm:   s.Algorithm,
+		Private:     signer,
+		ActiveAt:    s.ActiveAt,
+		SignUntil:   s.SignUntil,
+		VerifyUntil: s.VerifyUntil,
+	}, nil
+}
@@ -0,0 +1,95 @@
+// Package logging carries request scoped values, the request ID, the
+// caller and the route, in a context.Context and adds them to every record
+// logged with that context.
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userKey
+	routeKey
+)
+
+// WithRequestID returns a copy of ctx carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// WithUser returns a copy of ctx carrying the email of the caller
+func WithUser(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, userKey, email)
+}
+
+// WithRoute returns a copy of ctx carrying the route template, like
+// /api/v1/tour/:id
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// User returns the caller email carried by ctx, or ""
+func User(ctx context.Context) string {
+	email, _ := ctx.Value(userKey).(string)
+	return email
+}
+
+// Route returns the route template carried by ctx, or ""
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey).(string)
+	return route
+}
+
+// Handler is a slog.Handler adding the request_id, user, route and
+// trace_id attributes of the record context before passing records to the
+// next handler. Values missing from the context are left out.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if email := User(ctx); email != "" {
+			r.AddAttrs(slog.String("user", email))
+		}
+		if route := Route(ctx); route != "" {
+			r.AddAttrs(slog.String("route", route))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+			r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,75 @@
+// Package mail sends the mails of the service, like the links to verify an
+// email address or reset a password.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tabed23/travel-api/config"
+)
+
+// Message is a plain text mail
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is implemented by every way of sending mail
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the mailer of the configured driver
+func New(cfg config.Mail) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTP(cfg), nil
+	case "file":
+		return NewOutbox(cfg.OutboxDir, cfg.From), nil
+	case "memory":
+		return NewOutbox("", cfg.From), nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// format renders m as an RFC 5322 message from from. Line breaks are
+// dropped from the headers, so user input can not add headers.
+func format(from string, m Message, date time.Time) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header.Replace(m.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header.Replace(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// VerificationMessage asks to to confirm their email address at link
+func VerificationMessage(to, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome to travel-api!\n\n"+
+			"Open the link below to verify your email address, it expires in %v:\n\n%s\n\n"+
+			"If you did not create an account, ignore this mail.\n", ttl, link),
+	}
+}
+
+// PasswordResetMessage sends to the link to choose a new password
+func PasswordResetMessage(to, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your travel-api account.\n\n"+
+			"Open the link below to choose a new password, it expires in %v and works once:\n\n%s\n\n"+
+			"If it was not you, ignore this mail, your password stays the same.\n", ttl, link),
+	}
+}
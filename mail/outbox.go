@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox keeps the sent mails instead of delivering them, for local
+// development and tests. With a directory every mail is also written to it
+// as an .eml file that mail clients can open.
+type Outbox struct {
+	mu       sync.Mutex
+	dir      string
+	from     string
+	seq      int
+	messages []Message
+}
+
+func NewOutbox(dir, from string) *Outbox {
+	return &Outbox{dir: dir, from: from}
+}
+
+func (o *Outbox) Send(_ context.Context, m Message) error {
+	if _, err := address(m.To); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	if o.dir != "" {
+		if err := os.MkdirAll(o.dir, 0o755); err != nil {
+			return err
+		}
+		o.seq++
+		name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405.000000000"), o.seq)
+		if err := os.WriteFile(filepath.Join(o.dir, name), format(o.from, m, now), 0o600); err != nil {
+			return err
+		}
+	}
+	o.messages = append(o.messages, m)
+	return nil
+}
+
+// Messages returns the sent mails, oldest first
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+// address returns the bare address of a "Name <address>" or plain address
+func address(s string) (string, error) {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid mail address %q: %w", s, err)
+	}
+	return a.Address, nil
+}
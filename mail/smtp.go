@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/tabed23/travel-api/config"
+)
+
+// SMTP sends mail through an SMTP server, with STARTTLS when the server
+// offers it
+type SMTP struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTP(cfg config.Mail) *SMTP {
+	s := &SMTP{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host: cfg.Host,
+		from: cfg.From,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	from, err := address(s.from)
+	if err != nil {
+		return err
+	}
+	to, err := address(m.To)
+	if err != nil {
+		return err
+	}
+	// net/smtp takes no context, it is at least not started once cancelled
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, from, []string{to}, format(s.from, m, time.Now()))
+}
@@ -1,8 +1,11 @@
 package main
 
-import "github.com/tabed23/travel-api/cmd"
+import (
+	"os"
 
-func main() {
-	cmd.Start()
+	"github.com/tabed23/travel-api/cmd"
+)
 
+func main() {
+	os.Exit(cmd.Execute(os.Args[1:]))
 }
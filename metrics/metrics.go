@@ -0,0 +1,153 @@
+// Package metrics collects the Prometheus metrics of the service: HTTP
+// traffic, store latencies and business events. Metrics live in their own
+// registry so every Metrics value can be scraped on its own.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "travel"
+
+// storeBuckets are finer than the default buckets, most store operations
+// take a few milliseconds
+var storeBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight *prometheus.GaugeVec
+
+	storeDuration *prometheus.HistogramVec
+
+	bookingsCreated   prometheus.Counter
+	bookingsCancelled prometheus.Counter
+	revenue           *prometheus.CounterVec
+	refunds           *prometheus.CounterVec
+	reviewsPosted     prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		httpInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served by method and route template.",
+		}, []string{"method", "route"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Store operation latency by store, operation and outcome.",
+			Buckets:   storeBuckets,
+		}, []string{"store", "operation", "outcome"}),
+		bookingsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bookings_created_total",
+			Help:      "Bookings created.",
+		}),
+		bookingsCancelled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bookings_cancelled_total",
+			Help:      "Bookings cancelled.",
+		}),
+		revenue: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "revenue_total",
+			Help:      "Amount of settled payments by currency.",
+		}, []string{"currency"}),
+		refunds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "refunds_total",
+			Help:      "Amount refunded by currency.",
+		}, []string{"currency"}),
+		reviewsPosted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reviews_posted_total",
+			Help:      "Reviews posted.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.httpInFlight,
+		m.storeDuration,
+		m.bookingsCreated, m.bookingsCancelled, m.revenue, m.refunds, m.reviewsPosted,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// Registry returns the registry holding the metrics
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// TrackRequest counts a request to route as in flight until the returned
+// function is called with its status
+func (m *Metrics) TrackRequest(method, route string) func(status int) {
+	start := time.Now()
+	inFlight := m.httpInFlight.WithLabelValues(method, route)
+	inFlight.Inc()
+	return func(status int) {
+		inFlight.Dec()
+		code := strconv.Itoa(status)
+		m.httpRequests.WithLabelValues(method, route, code).Inc()
+		m.httpDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveStore records an operation of store that started at start
+func (m *Metrics) ObserveStore(store, operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.storeDuration.WithLabelValues(store, operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) BookingCreated() {
+	m.bookingsCreated.Inc()
+}
+
+func (m *Metrics) BookingCancelled() {
+	m.bookingsCancelled.Inc()
+}
+
+// Revenue records a settled payment
+func (m *Metrics) Revenue(currency string, amount float64) {
+	m.revenue.WithLabelValues(currency).Add(amount)
+}
+
+// Refund records money given back to a customer
+func (m *Metrics) Refund(currency string, amount float64) {
+	m.refunds.WithLabelValues(currency).Add(amount)
+}
+
+func (m *Metrics) ReviewPosted() {
+	m.reviewsPosted.Inc()
+}
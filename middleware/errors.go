@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code is the stable error
+// code of the domain error and Errors lists invalid fields.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []errors.FieldError `json:"errors,omitempty"`
+}
+
+var kindStatus = map[errors.Kind]int{
+	errors.KindInternal:        http.StatusInternalServerError,
+	errors.KindBadRequest:      http.StatusBadRequest,
+	errors.KindValidation:      http.StatusUnprocessableEntity,
+	errors.KindUnauthorized:    http.StatusUnauthorized,
+	errors.KindForbidden:       http.StatusForbidden,
+	errors.KindNotFound:        http.StatusNotFound,
+	errors.KindConflict:        http.StatusConflict,
+	errors.KindPaymentRequired: http.StatusPaymentRequired,
+	errors.KindUpstream:        http.StatusBadGateway,
+	errors.KindTooManyRequests: http.StatusTooManyRequests,
+}
+
+// ErrorHandler is the fiber.Config ErrorHandler. It renders every error
+// returned by a handler as a problem, internal errors are logged and their
+// detail is hidden from the client. The causes domain errors wrap, like
+// database or parser errors, are only logged.
+func ErrorHandler(logger *slog.Logger) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		p := NewProblem(c, err)
+		var domain *errors.Error
+		if p.Status >= http.StatusInternalServerError {
+			logger.ErrorContext(c.UserContext(), "request failed", "method", c.Method(), "path", c.Path(), "error", err)
+		} else if errors.As(err, &domain) && domain.Err != nil {
+			logger.InfoContext(c.UserContext(), "request refused", "method", c.Method(), "path", c.Path(), "code", p.Code, "error", err)
+		}
+		body, merr := json.Marshal(p)
+		if merr != nil {
+			return c.Status(http.StatusInternalServerError).SendString(http.StatusText(http.StatusInternalServerError))
+		}
+		c.Set(fiber.HeaderContentType, ProblemContentType)
+		return c.Status(p.Status).Send(body)
+	}
+}
+
+// NewProblem describes err as a problem for the request
+func NewProblem(c *fiber.Ctx, err error) Problem {
+	p := Problem{
+		Type:      "about:blank",
+		Instance:  c.OriginalURL(),
+		RequestID: RequestID(c),
+	}
+	var domain *errors.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &domain):
+		p.Status = kindStatus[domain.Kind]
+		p.Code = domain.Code
+		// Message holds the detail set with Withf, Error() would add the
+		// wrapped cause
+		p.Detail = domain.Message
+		p.Errors = domain.Fields
+	case errors.Is(err, mongo.ErrNoDocuments):
+		p.Status = http.StatusNotFound
+		p.Code = "not_found"
+		p.Detail = "resource not found"
+	case mongo.IsDuplicateKeyError(err):
+		p.Status = http.StatusConflict
+		p.Code = errors.ErrDuplicate.Code
+		p.Detail = errors.ErrDuplicate.Message
+	case errors.As(err, &fiberErr):
+		p.Status = fiberErr.Code
+		p.Code = statusCode(fiberErr.Code)
+		p.Detail = fiberErr.Message
+	default:
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Status >= http.StatusInternalServerError {
+		p.Code = errors.ErrInternalServerError.Code
+		p.Detail = errors.ErrInternalServerError.Message
+		if p.Status == http.StatusBadGateway && domain != nil {
+			p.Code = domain.Code
+			p.Detail = domain.Message
+		}
+	}
+	p.Title = http.StatusText(p.Status)
+	return p
+}
+
+// statusCode derives an error code from an HTTP status, like not_found
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
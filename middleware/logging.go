@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/tabed23/travel-api/logging"
+)
+
+// RequestIDKey is the fiber.Ctx.Locals key AssignRequestID stores the request ID under
+const RequestIDKey = "requestid"
+
+// maxRequestIDLength bounds the X-Request-ID values taken from clients
+const maxRequestIDLength = 128
+
+// AssignRequestID gives every request an ID, the X-Request-ID sent by the
+// client or a new UUID when the header is missing or malformed. The ID is
+// echoed in the response and carried by c.UserContext() so that every log
+// record of the request has it.
+func AssignRequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = utils.UUIDv4()
+		}
+		c.Locals(RequestIDKey, id)
+		c.Set(fiber.HeaderXRequestID, id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// RequestID returns the ID AssignRequestID gave the request
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(RequestIDKey).(string)
+	return id
+}
+
+// validRequestID only accepts short IDs of letters, digits and -_.: so that
+// client supplied IDs can not forge log lines or bloat them
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
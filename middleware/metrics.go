@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/metrics"
+)
+
+// Metrics records the requests of the route registered with method and the
+// path template route. It runs before the error handler, so the status of a
+// failed request is the one its problem response will have.
+func Metrics(m *metrics.Metrics, method, route string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		done := m.TrackRequest(method, route)
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = NewProblem(c, err).Status
+		}
+		done(status)
+		return err
+	}
+}
@@ -1,20 +1,122 @@
 package middleware
 
 import (
+	"context"
+	"slices"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/logging"
 	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
 )
 
-func AuthMiddleware(c *fiber.Ctx) error {
-	//tokenString := c.Get("Authorization")
-	tokenString := utils.ExtractToken(c)
-	token, err := utils.ParseToken(tokenString)
+// ClaimsKey is the fiber.Ctx.Locals key holding the caller's *utils.Claims
+const ClaimsKey = "claims"
+
+// APIKeyAuthenticator returns the claims of the owner of an API key used
+// from ip, limited to the scopes of the key. Identify only checks the key
+// and returns its id, without recording its use.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key, ip string) (*utils.Claims, error)
+	Identify(ctx context.Context, key, ip string) (string, error)
+}
+
+// SessionChecker refuses the access tokens whose session was revoked or
+// expired, used from ip
+type SessionChecker interface {
+	Check(ctx context.Context, claims *utils.Claims, ip string) error
+}
+
+// Auth rejects requests without a valid bearer token issued by j in a
+// session sessions still accept, or an API key of keys in the X-API-Key
+// header. The bearer token wins when both are sent. Checking the session
+// reads the session store on every request with a bearer token, and writes
+// its activity at most about once a minute.
+func Auth(j *utils.JWT, keys APIKeyAuthenticator, sessions SessionChecker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := utils.ExtractToken(c)
+		var token *utils.Claims
+		if key := c.Get(APIKeyHeader); tokenString == "" && key != "" {
+			claims, err := keys.Authenticate(c.UserContext(), key, c.IP())
+			if err != nil {
+				return err
+			}
+			token = claims
+		} else {
+			claims, err := j.ParseToken(tokenString)
+			if err != nil {
+				return errors.ErrInvalidToken.Wrap(err)
+			}
+			if err := sessions.Check(c.UserContext(), claims, c.IP()); err != nil {
+				return err
+			}
+			token = claims
+		}
+
+		c.Locals(ClaimsKey, token)
+		c.Set("role", token.Role)
+		c.SetUserContext(logging.WithUser(c.UserContext(), token.Email))
+
+		return c.Next()
+	}
+}
 
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).SendString("Invalid Token")
+// OptionalAuth behaves like Auth when a bearer token or an API key is sent
+// and treats the caller as a guest otherwise.
+func OptionalAuth(j *utils.JWT, keys APIKeyAuthenticator, sessions SessionChecker) fiber.Handler {
+	auth := Auth(j, keys, sessions)
+	return func(c *fiber.Ctx) error {
+		if utils.ExtractToken(c) == "" && c.Get(APIKeyHeader) == "" {
+			c.Locals(ClaimsKey, &utils.Claims{Role: constant.GuestRole})
+			return c.Next()
+		}
+		return auth(c)
 	}
+}
+
+// GetClaims returns the claims stored by Auth, or nil
+func GetClaims(c *fiber.Ctx) *utils.Claims {
+	claims, _ := c.Locals(ClaimsKey).(*utils.Claims)
+	return claims
+}
 
-	c.Set("role", token.Role)
+// RequireRole only lets callers with one of roles through. API keys are
+// limited to their scopes, so they never pass a role check.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return errors.ErrUnAuthorized
+		}
+		if claims.APIKey != "" {
+			return errors.ErrAPIKeyScope
+		}
+		for _, role := range roles {
+			if claims.Role == role {
+				return c.Next()
+			}
+		}
+		return errors.ErrForbidden
+	}
+}
 
-	return c.Next()
+// RequirePermission only lets callers whose role grants all of perms
+// through, callers with an API key also need them in its scopes
+func RequirePermission(perms ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return errors.ErrUnAuthorized
+		}
+		for _, perm := range perms {
+			if !constant.HasPermission(claims.Role, perm) {
+				return errors.ErrForbidden
+			}
+			if claims.APIKey != "" && !slices.Contains(claims.Scopes, perm) {
+				return errors.ErrAPIKeyScope
+			}
+		}
+		return c.Next()
+	}
 }
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/ratelimit"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// APIKeyHeader is the header clients send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// RateLimit takes a token from the bucket of the client for every request
+// and refuses the request once the bucket is empty. The buckets of name are
+// separate from those of other route groups. Every response tells the
+// client its limit in the RateLimit-* headers, refused ones also have
+// Retry-After. Requests are let through when the backend fails, an outage
+// of the backend must not take the API down.
+func RateLimit(b ratelimit.Backend, name string, limit ratelimit.Limit, key func(*fiber.Ctx) string, l *slog.Logger) fiber.Handler {
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(math.Ceil(limit.Window.Seconds())))
+	return func(c *fiber.Ctx) error {
+		res, err := b.Take(c.UserContext(), name+":"+key(c), limit, time.Now())
+		if err != nil {
+			l.WarnContext(c.UserContext(), "rate limit backend failed, request let through", "error", err)
+			return c.Next()
+		}
+		c.Set("RateLimit-Policy", policy)
+		c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, seconds(res.RetryAfter))
+			return errors.ErrRateLimited
+		}
+		return c.Next()
+	}
+}
+
+// ClientKey returns the function telling clients apart by kind: ip, user
+// for the email of a valid bearer token issued by j, falling back to the
+// API key, or api_key for the X-API-Key header once keys knows the key.
+// Clients without a valid token or key are told apart by ip, so sending
+// made up tokens or keys does not get a client a fresh bucket.
+func ClientKey(kind string, j *utils.JWT, keys APIKeyAuthenticator) func(*fiber.Ctx) string {
+	ip := func(c *fiber.Ctx) string {
+		return "ip:" + c.IP()
+	}
+	apiKey := func(c *fiber.Ctx) string {
+		if key := c.Get(APIKeyHeader); key != "" {
+			if id, err := keys.Identify(c.UserContext(), key, c.IP()); err == nil {
+				return "api_key:" + id
+			}
+		}
+		return ip(c)
+	}
+	switch kind {
+	case "user":
+		return func(c *fiber.Ctx) string {
+			if claims, err := j.ParseToken(utils.ExtractToken(c)); err == nil {
+				return "user:" + claims.Email
+			}
+			return apiKey(c)
+		}
+	case "api_key":
+		return apiKey
+	}
+	return ip
+}
+
+// seconds formats d as whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
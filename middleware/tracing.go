@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/logging"
+	"github.com/tabed23/travel-api/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace starts a server span for every request. The span continues the
+// trace of an incoming traceparent header and is carried by
+// c.UserContext(), so the spans of the stores and MongoDB commands are its
+// children. Routes rename it after their template with Route.
+func Trace() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := tracing.Tracer().Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+				attribute.String("user_agent.original", c.Get(fiber.HeaderUserAgent)),
+				attribute.String("client.address", c.IP()),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = NewProblem(c, err).Status
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		// client errors are expected answers, only server errors fail the span
+		if status >= http.StatusInternalServerError {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
+	}
+}
+
+// Route names the logs and the server span of a request after the path
+// template route it matched, like /api/v1/tour/:id
+func Route(route string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := logging.WithRoute(c.UserContext(), route)
+		span := trace.SpanFromContext(ctx)
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(attribute.String("http.route", route))
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// headerCarrier reads the propagation headers of a request
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+// Set is unused, requests are only extracted from
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := []string{}
+	h.c.Request().Header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
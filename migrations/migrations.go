@@ -0,0 +1,191 @@
+// Package migrations applies versioned changes to the MongoDB schema, like
+// indexes and data backfills. Applied versions are recorded in the
+// migrations collection so every step runs once per database.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection records the applied migrations
+const Collection = "migrations"
+
+// Migration is one versioned step. Down is nil for steps that can not be
+// reverted, like most backfills.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is a document of the migrations collection
+type Record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Status tells whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// ErrPending is returned by Check when migrations have not been applied
+type ErrPending struct {
+	Versions []int
+}
+
+func (e ErrPending) Error() string {
+	return fmt.Sprintf("%d migrations pending: %v, run the migrate command", len(e.Versions), e.Versions)
+}
+
+type Runner struct {
+	db         *mongo.Database
+	coll       *mongo.Collection
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// NewRunner returns a runner applying All to db
+func NewRunner(db *mongo.Database, l *slog.Logger) *Runner {
+	return NewRunnerWith(db, All, l)
+}
+
+// NewRunnerWith returns a runner applying migrations to db
+func NewRunnerWith(db *mongo.Database, migrations []Migration, l *slog.Logger) *Runner {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Runner{db: db, coll: db.Collection(Collection), migrations: sorted, logger: l}
+}
+
+// applied returns the applied migrations by version
+func (r *Runner) applied(ctx context.Context) (map[int]Record, error) {
+	cur, err := r.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var records []Record
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// Status lists every migration in version order
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		rec, ok := applied[m.Version]
+		statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: rec.AppliedAt})
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not applied yet in version order
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check fails with ErrPending when migrations have not been applied
+func (r *Runner) Check(ctx context.Context) error {
+	pending, err := r.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	versions := make([]int, 0, len(pending))
+	for _, m := range pending {
+		versions = append(versions, m.Version)
+	}
+	return ErrPending{Versions: versions}
+}
+
+// Up applies every pending migration in order and returns how many ran. It
+// stops at the first failure, leaving later migrations pending.
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	pending, err := r.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range pending {
+		r.logger.Info("migrations", "Up", fmt.Sprintf("applying %d %v", m.Version, m.Description))
+		if err := m.Up(ctx, r.db); err != nil {
+			return i, fmt.Errorf("migration %d %v: %w", m.Version, m.Description, err)
+		}
+		rec := Record{Version: m.Version, Description: m.Description, AppliedAt: time.Now().UTC()}
+		// another instance may have applied the same step concurrently, the
+		// steps are idempotent so its record is as good as ours
+		if _, err := r.coll.InsertOne(ctx, rec); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return i, fmt.Errorf("record migration %d: %w", m.Version, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// how many were reverted
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(steps))
+	cur, err := r.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+	var records []Record
+	if err := cur.All(ctx, &records); err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		byVersion[m.Version] = m
+	}
+	for i, rec := range records {
+		m, ok := byVersion[rec.Version]
+		if !ok {
+			return i, fmt.Errorf("migration %d is applied but unknown to this build", rec.Version)
+		}
+		if m.Down == nil {
+			return i, fmt.Errorf("migration %d %v can not be reverted", m.Version, m.Description)
+		}
+		r.logger.Info("migrations", "Down", fmt.Sprintf("reverting %d %v", m.Version, m.Description))
+		if err := m.Down(ctx, r.db); err != nil {
+			return i, fmt.Errorf("migration %d %v: %w", m.Version, m.Description, err)
+		}
+		if _, err := r.coll.DeleteOne(ctx, bson.M{"_id": rec.Version}); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
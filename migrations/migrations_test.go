@@ -0,0 +1,93 @@
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/tabed23/travel-api/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// steps are three migrations that touch nothing
+var steps = []migrations.Migration{
+	{Version: 1, Description: "one", Up: noop},
+	{Version: 3, Description: "three", Up: noop},
+	{Version: 2, Description: "two", Up: noop},
+}
+
+func noop(context.Context, *mongo.Database) error { return nil }
+
+// applied answers the next find of the migrations collection with the
+// records of versions
+func applied(mt *mtest.T, versions ...int) {
+	docs := make([]bson.D, 0, len(versions))
+	for _, v := range versions {
+		docs = append(docs, bson.D{{Key: "_id", Value: v}, {Key: "description", Value: "applied"}})
+	}
+	ns := mt.DB.Name() + "." + migrations.Collection
+	mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, docs...))
+}
+
+func TestCheck(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("up to date", func(mt *mtest.T) {
+		applied(mt, 1, 2, 3)
+		if err := migrations.NewRunnerWith(mt.DB, steps, logger).Check(context.Background()); err != nil {
+			mt.Errorf("got %v, want nil", err)
+		}
+	})
+	mt.Run("pending", func(mt *mtest.T) {
+		applied(mt, 2)
+		err := migrations.NewRunnerWith(mt.DB, steps, logger).Check(context.Background())
+		var pending migrations.ErrPending
+		if !errors.As(err, &pending) || !slices.Equal(pending.Versions, []int{1, 3}) {
+			mt.Errorf("got %v, want versions 1 and 3 pending", err)
+		}
+	})
+	mt.Run("unreadable records", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}))
+		err := migrations.NewRunnerWith(mt.DB, steps, logger).Check(context.Background())
+		var pending migrations.ErrPending
+		if err == nil || errors.As(err, &pending) {
+			mt.Errorf("got %v, want the error of the find", err)
+		}
+	})
+}
+
+func TestUpAppliesPendingInOrder(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("in order", func(mt *mtest.T) {
+		var ran []int
+		record := func(v int) func(context.Context, *mongo.Database) error {
+			return func(context.Context, *mongo.Database) error { ran = append(ran, v); return nil }
+		}
+		ms := []migrations.Migration{{Version: 3, Up: record(3)}, {Version: 1, Up: record(1)}, {Version: 2, Up: record(2)}}
+		applied(mt, 2)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		n, err := migrations.NewRunnerWith(mt.DB, ms, logger).Up(context.Background())
+		if err != nil || n != 2 || !slices.Equal(ran, []int{1, 3}) {
+			mt.Errorf("applied %d %v, %v, want 1 and 3", n, ran, err)
+		}
+	})
+	mt.Run("stops at a failure", func(mt *mtest.T) {
+		failed := errors.New("failed")
+		ms := []migrations.Migration{
+			{Version: 1, Up: func(context.Context, *mongo.Database) error { return failed }},
+			{Version: 2, Up: func(context.Context, *mongo.Database) error { mt.Errorf("ran after a failure"); return nil }},
+		}
+		applied(mt)
+		if n, err := migrations.NewRunnerWith(mt.DB, ms, logger).Up(context.Background()); n != 0 || !errors.Is(err, failed) {
+			mt.Errorf("applied %d, %v, want none and the failure", n, err)
+		}
+	})
+}
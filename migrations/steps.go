@@ -0,0 +1,318 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All lists the migrations of the service, new steps are appended with the
+// next version and applied steps are never edited
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "unique user emails",
+		Up: createIndexes(store.UserCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_unique").SetUnique(true),
+		}),
+		Down: dropIndexes(store.UserCollection, "email_unique"),
+	},
+	{
+		Version:     2,
+		Description: "unique tour titles and tour text search",
+		Up: createIndexes(store.TourCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "title", Value: 1}},
+				Options: options.Index().SetName("title_unique").SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "city", Value: "text"}, {Key: "desc", Value: "text"}},
+				Options: options.Index().SetName("tour_text").SetWeights(bson.D{{Key: "title", Value: 10}, {Key: "city", Value: 5}, {Key: "desc", Value: 1}}),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "city", Value: 1}, {Key: "distance", Value: 1}, {Key: "maxGroupSize", Value: 1}},
+				Options: options.Index().SetName("city_distance_group"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "featured", Value: 1}},
+				Options: options.Index().SetName("featured"),
+			},
+		),
+		Down: dropIndexes(store.TourCollection, "title_unique", "tour_text", "city_distance_group", "featured"),
+	},
+	{
+		Version:     3,
+		Description: "booking, departure, payment and review lookups",
+		Up: sequence(
+			createIndexes(store.BookingCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: -1}},
+					Options: options.Index().SetName("email_created"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "status", Value: 1}, {Key: "hold_expires_at", Value: 1}},
+					Options: options.Index().SetName("status_hold_expires"),
+				},
+			),
+			createIndexes(store.DepartureCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tour_id", Value: 1}, {Key: "start_date", Value: 1}},
+				Options: options.Index().SetName("tour_start"),
+			}),
+			createIndexes(store.PaymentCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "intent_id", Value: 1}},
+					Options: options.Index().SetName("intent_unique").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "booking_id", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+					Options: options.Index().SetName("booking_status_created"),
+				},
+			),
+			createIndexes(store.ReviewCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tourtId", Value: 1}},
+				Options: options.Index().SetName("tour"),
+			}),
+		),
+		Down: sequence(
+			dropIndexes(store.BookingCollection, "email_created", "status_hold_expires"),
+			dropIndexes(store.DepartureCollection, "tour_start"),
+			dropIndexes(store.PaymentCollection, "intent_unique", "booking_status_created"),
+			dropIndexes(store.ReviewCollection, "tour"),
+		),
+	},
+	{
+		Version:     4,
+		Description: "refresh token lookups and expiry",
+		Up: createIndexes(store.RefreshTokenCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "token_hash", Value: 1}},
+				Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "family", Value: 1}},
+				Options: options.Index().SetName("family"),
+			},
+			// expired tokens are deleted by MongoDB, reuse of a rotated token
+			// can only be detected until then
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+			},
+		),
+		Down: dropIndexes(store.RefreshTokenCollection, "token_hash_unique", "family", "expires_ttl"),
+	},
+	{
+		Version:     5,
+		Description: "tour locations",
+		Up: createIndexes(store.TourCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "location", Value: "2dsphere"}},
+			Options: options.Index().SetName("location_2dsphere"),
+		}),
+		Down: dropIndexes(store.TourCollection, "location_2dsphere"),
+	},
+	{
+		Version:     6,
+		Description: "backfill legacy users, tours and bookings",
+		Up:          backfillLegacy,
+	},
+	{
+		Version:     7,
+		Description: "rate limit bucket expiry",
+		// buckets are deleted once they have refilled, a full bucket is the
+		// same as a missing one
+		Up: createIndexes(store.RateLimitCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+		}),
+		Down: dropIndexes(store.RateLimitCollection, "expires_ttl"),
+	},
+	{
+		Version:     8,
+		Description: "login history and lockouts",
+		Up: sequence(
+			createIndexes(store.LoginAttemptCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: -1}},
+					Options: options.Index().SetName("email_created"),
+				},
+				// the login history is kept for 90 days
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "createdAt", Value: 1}},
+					Options: options.Index().SetName("created_ttl").SetExpireAfterSeconds(90 * 24 * 60 * 60),
+				},
+			),
+			createIndexes(store.LoginLockCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+			}),
+		),
+		Down: sequence(
+			dropIndexes(store.LoginAttemptCollection, "email_created", "created_ttl"),
+			dropIndexes(store.LoginLockCollection, "expires_ttl"),
+		),
+	},
+	{
+		Version:     9,
+		Description: "email verification and password reset tokens",
+		Up: createIndexes(store.UserTokenCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "token_hash", Value: 1}},
+				Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}, {Key: "purpose", Value: 1}},
+				Options: options.Index().SetName("email_purpose"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+			},
+		),
+		Down: dropIndexes(store.UserTokenCollection, "token_hash_unique", "email_purpose", "expires_ttl"),
+	},
+	{
+		Version:     10,
+		Description: "expire the signing keys of the token keyring",
+		Up: createIndexes(store.SigningKeyCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "verify_until", Value: 1}},
+			Options: options.Index().SetName("verify_until_ttl").SetExpireAfterSeconds(0),
+		}),
+		Down: dropIndexes(store.SigningKeyCollection, "verify_until_ttl"),
+	},
+	{
+		Version:     11,
+		Description: "api keys",
+		Up: createIndexes(store.APIKeyCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "key_hash", Value: 1}},
+				Options: options.Index().SetName("key_hash_unique").SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "prefix", Value: 1}},
+				Options: options.Index().SetName("prefix_unique").SetUnique(true),
+			},
+		),
+		Down: dropIndexes(store.APIKeyCollection, "key_hash_unique", "prefix_unique"),
+	},
+	{
+		Version:     12,
+		Description: "sign-in with OpenID Connect providers",
+		Up: sequence(
+			createIndexes(store.IdentityCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+					Options: options.Index().SetName("provider_subject_unique").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "email", Value: 1}},
+					Options: options.Index().SetName("email"),
+				},
+			),
+			createIndexes(store.OIDCStateCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "state_hash", Value: 1}},
+					Options: options.Index().SetName("state_hash_unique").SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+					Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+				},
+			),
+		),
+		Down: sequence(
+			dropIndexes(store.IdentityCollection, "provider_subject_unique", "email"),
+			dropIndexes(store.OIDCStateCollection, "state_hash_unique", "expires_ttl"),
+		),
+	},
+	{
+		Version:     13,
+		Description: "sessions of logins",
+		Up: createIndexes(store.SessionCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}, {Key: "lastSeenAt", Value: -1}},
+				Options: options.Index().SetName("email_last_seen"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetName("expires_ttl").SetExpireAfterSeconds(0),
+			},
+		),
+		Down: dropIndexes(store.SessionCollection, "email_last_seen", "expires_ttl"),
+	},
+	{
+		Version:     14,
+		Description: "pending refunds retried by the sweeper",
+		Up: createIndexes(store.PaymentCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "refund_pending", Value: 1}},
+				Options: options.Index().SetName("refund_pending").SetSparse(true),
+			},
+		),
+		Down: dropIndexes(store.PaymentCollection, "refund_pending"),
+	},
+}
+
+// backfillLegacy brings documents written by older releases to the current
+// shape: users without a role, tours with a null reviews array, bookings
+// without a status and phone numbers stored as integers
+func backfillLegacy(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection(store.UserCollection)
+	if _, err := users.UpdateMany(ctx,
+		bson.M{"$or": bson.A{bson.M{"role": bson.M{"$exists": false}}, bson.M{"role": ""}}},
+		bson.M{"$set": bson.M{"role": "user"}}); err != nil {
+		return err
+	}
+	tours := db.Collection(store.TourCollection)
+	if _, err := tours.UpdateMany(ctx, bson.M{"reviews": nil}, bson.M{"$set": bson.M{"reviews": bson.A{}}}); err != nil {
+		return err
+	}
+	bookings := db.Collection(store.BookingCollection)
+	if _, err := bookings.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": models.BookingConfirmed, "history": bson.A{}}}); err != nil {
+		return err
+	}
+	phoneToString := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"phone": bson.M{"$concat": bson.A{"+", bson.M{"$toString": "$phone"}}},
+	}}}}
+	if _, err := bookings.UpdateMany(ctx, bson.M{"phone": bson.M{"$type": bson.A{"int", "long"}, "$ne": 0}}, phoneToString); err != nil {
+		return err
+	}
+	_, err := bookings.UpdateMany(ctx, bson.M{"phone": 0}, bson.M{"$set": bson.M{"phone": ""}})
+	return err
+}
+
+func createIndexes(collection string, indexes ...mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+func dropIndexes(collection string, names ...string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range names {
+			if _, err := db.Collection(collection).Indexes().DropOne(ctx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// sequence runs steps in order, stopping at the first failure
+func sequence(steps ...func(context.Context, *mongo.Database) error) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, step := range steps {
+			if err := step(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
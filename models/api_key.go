@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey lets an integration call the API as its owner without logging in.
+// Only the SHA-256 hash of the key is persisted, the prefix is kept in clear
+// to tell keys apart. A key only reaches the routes its scopes grant and the
+// role of its owner allows.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	OwnerEmail string             `bson:"owner_email" json:"owner_email"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	// AllowedIPs are the addresses and CIDR ranges the key may be used
+	// from, any address when empty
+	AllowedIPs []string   `bson:"allowed_ips,omitempty" json:"allowed_ips,omitempty"`
+	ExpiresAt  *time.Time `bson:"expiresAt,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `bson:"lastUsedAt,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string     `bson:"lastUsedIP,omitempty" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `bson:"revokedAt,omitempty" json:"revoked_at,omitempty"`
+	CreatedBy  string     `bson:"createdBy" json:"created_by"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"created_at"`
+}
+
+type APIKeyCreate struct {
+	Name       string     `json:"name" validate:"required,max=100"`
+	OwnerEmail string     `json:"owner_email" validate:"required,email"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	AllowedIPs []string   `json:"allowed_ips" validate:"omitempty,dive,cidr|ip"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
@@ -6,20 +6,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+type BookingStatus string
+
+const (
+	BookingPending   BookingStatus = "pending"
+	BookingHeld      BookingStatus = "held"
+	BookingConfirmed BookingStatus = "confirmed"
+	BookingCancelled BookingStatus = "cancelled"
+	BookingCompleted BookingStatus = "completed"
+	BookingNoShow    BookingStatus = "no_show"
+	BookingExpired   BookingStatus = "expired"
+)
+
+// bookingTransitions lists the states a booking may move to from each state,
+// cancelled, completed, no_show and expired are final
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingPending:   {BookingHeld, BookingConfirmed, BookingCancelled},
+	BookingHeld:      {BookingConfirmed, BookingCancelled, BookingExpired},
+	BookingConfirmed: {BookingCancelled, BookingCompleted, BookingNoShow},
+}
+
+// CanTransitionTo reports whether a booking in status s may move to next
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsSeats reports whether a booking in status s still takes seats on its departure
+func (s BookingStatus) HoldsSeats() bool {
+	return s == BookingPending || s == BookingHeld || s == BookingConfirmed
+}
+
+// BookingEvent records a status change of a booking
+type BookingEvent struct {
+	From  BookingStatus `bson:"from,omitempty"`
+	To    BookingStatus `bson:"to"`
+	Actor string        `bson:"actor"`
+	Note  string        `bson:"note,omitempty"`
+	At    time.Time     `bson:"at"`
+}
+
 type Booking struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserID    primitive.ObjectID `bson:"user_id"`
-	UserEmail string             `bson:"email"`
-	TourName  string             `bson:"tour_name"`
-	GuestSize int64              `bson:"guest_size"`
-	Phone     int64              `bson:"phone"`
-	CreatedAt time.Time          `bson:"createdAt,omitempty"`
-	UpdatedAt time.Time          `bson:"updatedAt,omitempty"`
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	UserID        primitive.ObjectID `bson:"user_id"`
+	UserEmail     string             `bson:"email"`
+	TourName      string             `bson:"tour_name"`
+	TourID        primitive.ObjectID `bson:"tour_id,omitempty"`
+	DepartureID   primitive.ObjectID `bson:"departure_id,omitempty"`
+	GuestSize     int64              `bson:"guest_size"`
+	Phone         Phone              `bson:"phone"`
+	Status        BookingStatus      `bson:"status"`
+	UnitPrice     float64            `bson:"unit_price"`
+	TotalPrice    float64            `bson:"total_price"`
+	RefundAmount  float64            `bson:"refund_amount,omitempty"`
+	HoldExpiresAt *time.Time         `bson:"hold_expires_at,omitempty"`
+	History       []BookingEvent     `bson:"history"`
+	CreatedAt     time.Time          `bson:"createdAt,omitempty"`
+	UpdatedAt     time.Time          `bson:"updatedAt,omitempty"`
+}
+
+// BookingInput is the body bookings are made with
+type BookingInput struct {
+	DepartureID primitive.ObjectID `json:"departure_id" validate:"required"`
+	GuestSize   int64              `json:"guest_size" validate:"required,gt=0"`
+	Phone       Phone              `json:"phone" validate:"required,e164"`
+}
+
+// Booking returns the booking described by the input
+func (in BookingInput) Booking() Booking {
+	return Booking{DepartureID: in.DepartureID, GuestSize: in.GuestSize, Phone: in.Phone}
 }
 
 type UpdateBooking struct {
-	TourName  string    `bson:"tour_name"`
-	GuestSize int64     `bson:"guest_size"`
-	Phone     int64     `bson:"phone"`
-	UpdatedAt time.Time `bson:"updatedAt,omitempty"`
+	GuestSize int64 `json:"guest_size" validate:"required,gt=0"`
+	Phone     Phone `json:"phone" validate:"required,e164"`
+}
+
+type BookingTransition struct {
+	Note string `json:"note" validate:"max=500"`
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Departure is a dated run of a tour with its own seat inventory
+type Departure struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	TourID        primitive.ObjectID `bson:"tour_id"`
+	StartDate     time.Time          `bson:"start_date"`
+	EndDate       time.Time          `bson:"end_date"`
+	Capacity      int64              `bson:"capacity"`
+	SeatsHeld     int64              `bson:"seats_held"`
+	SeatsSold     int64              `bson:"seats_sold"`
+	PriceOverride *float64           `bson:"price_override,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt,omitempty"`
+	UpdatedAt     time.Time          `bson:"updatedAt,omitempty"`
+}
+
+// SeatsAvailable returns the number of seats that can still be booked
+func (d Departure) SeatsAvailable() int64 {
+	return d.Capacity - d.SeatsHeld - d.SeatsSold
+}
+
+// UpdateDeparture is the body departures are created and updated with
+type UpdateDeparture struct {
+	StartDate     time.Time `json:"start_date" validate:"required"`
+	EndDate       time.Time `json:"end_date" validate:"required,gtfield=StartDate"`
+	Capacity      int64     `json:"capacity" validate:"gt=0"`
+	PriceOverride *float64  `json:"price_override" validate:"omitempty,gt=0"`
+}
+
+// Departure returns the departure described by the input
+func (u UpdateDeparture) Departure() Departure {
+	return Departure{StartDate: u.StartDate, EndDate: u.EndDate, Capacity: u.Capacity, PriceOverride: u.PriceOverride}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity links the account of a user at an OpenID Connect provider to the
+// user, by the subject the provider knows the account as
+type Identity struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Provider    string             `bson:"provider" json:"provider"`
+	Subject     string             `bson:"subject" json:"subject"`
+	Email       string             `bson:"email" json:"email"`
+	LastLoginAt time.Time          `bson:"lastLoginAt" json:"last_login_at"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"created_at"`
+}
+
+// OIDCState is a sign-in sent to a provider, waiting for the provider to
+// send the user back. Only the SHA-256 hash of the state is persisted, the
+// nonce and PKCE verifier never leave the service.
+type OIDCState struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash string             `bson:"state_hash"`
+	Provider  string             `bson:"provider"`
+	Nonce     string             `bson:"nonce"`
+	Verifier  string             `bson:"verifier"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a login attempt failed
+const (
+	LoginUnknownEmail  = "unknown_email"
+	LoginWrongPassword = "wrong_password"
+	LoginLocked        = "locked"
+	LoginWrongMFACode  = "wrong_mfa_code"
+)
+
+// LoginAttempt is an entry of the login history. Attempts are recorded for
+// unknown emails too, so guessing shows up in the history of the IP.
+type LoginAttempt struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email     string             `bson:"email" json:"email"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	Success   bool               `bson:"success" json:"success"`
+	// Reason is why the attempt failed, one of the Login constants
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+	// NewIP marks successful logins from an IP the account never logged in
+	// from before
+	NewIP     bool      `bson:"new_ip,omitempty" json:"new_ip,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"created_at"`
+}
+
+// LoginLock counts the consecutive failed logins of a key, an email or an
+// IP. Logins of the key are refused until LockedUntil, failures are
+// forgotten at ExpiresAt.
+type LoginLock struct {
+	Key         string    `bson:"_id" json:"key"`
+	Failures    int       `bson:"failures" json:"failures"`
+	LastFailure time.Time `bson:"last_failure" json:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until" json:"locked_until"`
+	ExpiresAt   time.Time `bson:"expires_at" json:"-"`
+}
+
+// Locked reports whether the key is locked at now
+func (l LoginLock) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
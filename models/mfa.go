@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// MFA is the TOTP second factor of a user, keyed by their email. It is
+// pending from enrollment until the user confirms a first code. The secret
+// never leaves the service after enrollment, recovery codes are only kept
+// as SHA-256 hashes.
+type MFA struct {
+	Email   string `bson:"_id" json:"-"`
+	Secret  string `bson:"secret" json:"-"`
+	Enabled bool   `bson:"enabled" json:"enabled"`
+	// LastStep is the TOTP step of the last accepted code, codes of it and
+	// earlier steps are refused so a code works once
+	LastStep      int64     `bson:"last_step" json:"-"`
+	RecoveryCodes []string  `bson:"recovery_codes" json:"-"`
+	EnabledAt     time.Time `bson:"enabledAt,omitempty" json:"enabled_at,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt" json:"-"`
+}
+
+// MFACode is a code of the authenticator app
+type MFACode struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// MFAToken is the body of the forced enrollment, its token is the mfa
+// pending token returned by the login
+type MFAToken struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// MFAEnrollConfirm confirms a forced enrollment with a first code
+type MFAEnrollConfirm struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// MFAVerify is the second step of a login, with a code of the
+// authenticator app or a recovery code
+type MFAVerify struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
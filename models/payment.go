@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+	PaymentRefunded  PaymentStatus = "refunded"
+)
+
+// Payment links a provider payment intent to a booking
+type Payment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	BookingID      primitive.ObjectID `bson:"booking_id"`
+	UserEmail      string             `bson:"email"`
+	Provider       string             `bson:"provider"`
+	IntentID       string             `bson:"intent_id"`
+	Amount         float64            `bson:"amount"`
+	RefundedAmount float64            `bson:"refunded_amount,omitempty"`
+	RefundPending  float64            `bson:"refund_pending,omitempty"`
+	RefundError    string             `bson:"refund_error,omitempty"`
+	RefundClaim    string             `bson:"refund_claim,omitempty"`
+	RefundLease    *time.Time         `bson:"refund_lease_until,omitempty"`
+	RefundAttempts int                `bson:"refund_attempts,omitempty"`
+	Currency       string             `bson:"currency"`
+	Status         PaymentStatus      `bson:"status"`
+	FailureReason  string             `bson:"failure_reason,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt,omitempty"`
+	UpdatedAt      time.Time          `bson:"updatedAt,omitempty"`
+}
+
+type StartPayment struct {
+	PaymentMethod string `json:"payment_method" validate:"required"`
+}
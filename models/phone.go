@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Phone is a phone number in E.164 format, like +14155552671
+type Phone string
+
+// UnmarshalBSONValue also reads the numbers older documents stored as integers
+func (p *Phone) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.String:
+		*p = Phone(raw.StringValue())
+	case bsontype.Int64:
+		*p = phoneFromInt(raw.Int64())
+	case bsontype.Int32:
+		*p = phoneFromInt(int64(raw.Int32()))
+	case bsontype.Null, bsontype.Undefined:
+		*p = ""
+	default:
+		return fmt.Errorf("cannot decode %v into a phone number", t)
+	}
+	return nil
+}
+
+func phoneFromInt(n int64) Phone {
+	if n == 0 {
+		return ""
+	}
+	return Phone("+" + strconv.FormatInt(n, 10))
+}
@@ -7,11 +7,25 @@ import (
 )
 
 type Review struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	TourID     primitive.ObjectID `bson:"tourtId,ref:tourId"`
-	Username   string             `bson:"username,required"`
-	ReviewText string             `bson:"reviewText,required"`
-	Rating     int                `bson:"rating,required"`
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+	// TourID is stored as tourtId, the name existing reviews have
+	TourID     primitive.ObjectID `bson:"tourtId"`
+	Username   string             `bson:"username"`
+	UserEmail  string             `bson:"email"`
+	ReviewText string             `bson:"reviewText"`
+	Rating     int                `bson:"rating"`
 	CreatedAt  time.Time          `bson:"createdAt,omitempty"`
 	UpdatedAt  time.Time          `bson:"updatedAt,omitempty"`
 }
+
+// ReviewInput is the body reviews are written with
+type ReviewInput struct {
+	Username   string `json:"username" validate:"required,max=50"`
+	ReviewText string `json:"reviewText" validate:"required,max=2000"`
+	Rating     int    `json:"rating" validate:"required,min=1,max=5"`
+}
+
+// Review returns the review described by the input
+func (in ReviewInput) Review() Review {
+	return Review{Username: in.Username, ReviewText: in.ReviewText, Rating: in.Rating}
+}
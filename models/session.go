@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a login of a user on a device. Its ID is the family of the
+// refresh tokens of the login and the sid claim of its access tokens, so
+// revoking it ends both. A session lasts as long as its refresh token.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserEmail  string             `bson:"email" json:"email"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	IP         string             `bson:"ip" json:"ip"`
+	LastSeenIP string             `bson:"lastSeenIP" json:"last_seen_ip"`
+	LastSeenAt time.Time          `bson:"lastSeenAt" json:"last_seen_at"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expires_at"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"created_at"`
+	// Current marks the session of the caller in listings
+	Current bool `bson:"-" json:"current,omitempty"`
+}
+
+// Active reports whether the session is neither revoked nor expired at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
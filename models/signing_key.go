@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SigningKey is a key of the keyring signing the access tokens. Tokens name
+// it by its ID, their kid. The private key is sealed with the JWT secret.
+type SigningKey struct {
+	ID         string `bson:"_id"`
+	Algorithm  string `bson:"algorithm"`
+	PrivateKey []byte `bson:"private_key"`
+	// ActiveAt is when the key starts signing, keys are published before so
+	// verifiers caching the key set know them by then
+	ActiveAt time.Time `bson:"active_at"`
+	// SignUntil is when the key stops signing, VerifyUntil when the last
+	// token it signed expired
+	SignUntil   time.Time `bson:"sign_until"`
+	VerifyUntil time.Time `bson:"verify_until"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a single-use token that can be exchanged for a new access
+// token. Only the SHA-256 hash of the token is persisted. Every token issued
+// from the same login shares a Family so that a reused token can revoke the
+// whole chain.
+type RefreshToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Family     string             `bson:"family"`
+	TokenHash  string             `bson:"token_hash"`
+	UserEmail  string             `bson:"email"`
+	ReplacedBy primitive.ObjectID `bson:"replacedBy,omitempty"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty"`
+	ExpiresAt  time.Time          `bson:"expiresAt"`
+	CreatedAt  time.Time          `bson:"createdAt,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
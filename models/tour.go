@@ -8,17 +8,92 @@ import (
 
 type Tour struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	Title        string             `bson:"title,required,unique"`
-	City         string             `bson:"city,required"`
-	Address      string             `bson:"address,required"`
-	Distance     int                `bson:"distance,required"`
-	Photo        string             `bson:"photo,required"`
-	Description  string             `bson:"desc,required"`
-	Price        float64            `bson:"price,required"`
-	MaxGroupSize int                `bson:"maxGroupSize,required"`
-
-	Reviews   []primitive.ObjectID `bson:"reviews,ref:Reviews"`
-	Featured  bool                 `bson:"featured,default:false"`
+	Title        string             `bson:"title"`
+	City         string             `bson:"city"`
+	Address      string             `bson:"address"`
+	Distance     int                `bson:"distance"`
+	Photo        string             `bson:"photo"`
+	Description  string             `bson:"desc"`
+	Price        float64            `bson:"price"`
+	MaxGroupSize int                `bson:"maxGroupSize"`
+
+	CancellationPolicy []CancellationRule `bson:"cancellation_policy,omitempty"`
+	Location           *GeoPoint          `bson:"location,omitempty"`
+
+	Reviews   []primitive.ObjectID `bson:"reviews"`
+	Featured  bool                 `bson:"featured"`
 	CreatedAt time.Time            `bson:"createdAt,omitempty"`
 	UpdatedAt time.Time            `bson:"updatedAt,omitempty"`
 }
+
+// TourInput is the body tours are created and updated with
+type TourInput struct {
+	Title              string             `json:"title" validate:"required,max=200"`
+	City               string             `json:"city" validate:"required,max=100"`
+	Address            string             `json:"address" validate:"required,max=300"`
+	Distance           int                `json:"distance" validate:"gte=0"`
+	Photo              string             `json:"photo" validate:"required,url"`
+	Description        string             `json:"desc" validate:"required,max=5000"`
+	Price              float64            `json:"price" validate:"gt=0"`
+	MaxGroupSize       int                `json:"maxGroupSize" validate:"gt=0"`
+	Featured           bool               `json:"featured"`
+	CancellationPolicy []CancellationRule `json:"cancellation_policy" validate:"dive"`
+	Location           *GeoPoint          `json:"location" validate:"omitempty"`
+}
+
+// Tour returns the tour described by the input
+func (in TourInput) Tour() Tour {
+	return Tour{
+		Title:              in.Title,
+		City:               in.City,
+		Address:            in.Address,
+		Distance:           in.Distance,
+		Photo:              in.Photo,
+		Description:        in.Description,
+		Price:              in.Price,
+		MaxGroupSize:       in.MaxGroupSize,
+		Featured:           in.Featured,
+		CancellationPolicy: in.CancellationPolicy,
+		Location:           in.Location,
+	}
+}
+
+// AllowsGroup reports whether n guests fit in the tour maximum group size
+func (t Tour) AllowsGroup(n int64) bool {
+	return t.MaxGroupSize <= 0 || n <= int64(t.MaxGroupSize)
+}
+
+// GeoPoint is a GeoJSON point, coordinates are longitude then latitude
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type" validate:"required,eq=Point"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates" validate:"len=2"`
+}
+
+// CancellationRule refunds RefundPercent of the paid price when a booking is
+// cancelled at least DaysBefore days before the departure starts
+type CancellationRule struct {
+	DaysBefore    int     `bson:"days_before" json:"days_before" validate:"gte=0"`
+	RefundPercent float64 `bson:"refund_percent" json:"refund_percent" validate:"gte=0,lte=100"`
+}
+
+// DefaultCancellationPolicy applies to tours without their own policy
+var DefaultCancellationPolicy = []CancellationRule{
+	{DaysBefore: 30, RefundPercent: 100},
+	{DaysBefore: 7, RefundPercent: 50},
+}
+
+// RefundPercent returns the refund percentage for a cancellation daysBefore
+// days before departure, using the most generous rule that applies
+func (t Tour) RefundPercent(daysBefore int) float64 {
+	policy := t.CancellationPolicy
+	if len(policy) == 0 {
+		policy = DefaultCancellationPolicy
+	}
+	percent := 0.0
+	for _, rule := range policy {
+		if daysBefore >= rule.DaysBefore && rule.RefundPercent > percent {
+			percent = rule.RefundPercent
+		}
+	}
+	return percent
+}
@@ -8,29 +8,64 @@ import (
 
 type User struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	FirstName string             `bson:"first_name,required"`
-	Lastname  string             `bson:"last_name,required"`
-	UserName  string             `bson:"user_name,required"`
-	Email     string             `bson:"email,required,unique"`
-	Password  string             `bson:"password,required"`
+	FirstName string             `bson:"first_name"`
+	Lastname  string             `bson:"last_name"`
+	UserName  string             `bson:"user_name"`
+	Email     string             `bson:"email"`
+	Password  string             `bson:"password" json:"-"`
 
 	Photo     string    `bson:"photo,omitempty"`
-	Role      string    `bson:"role,default:user"`
+	Role      string    `bson:"role"`
 	CreatedAt time.Time `bson:"createdAt,omitempty"`
 	UpdatedAt time.Time `bson:"updatedAt,omitempty"`
+
+	// Unverified is set on self registered users until they prove they own
+	// their email, they may not book until then
+	Unverified bool `bson:"unverified,omitempty" json:"unverified,omitempty"`
+}
+
+// UserRegister is the body of a self registration
+type UserRegister struct {
+	FirstName string `json:"first_name" validate:"required,max=50"`
+	Lastname  string `json:"last_name" validate:"required,max=50"`
+	UserName  string `json:"user_name" validate:"required,min=3,max=30,alphanum"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required,min=8,max=72"`
+	Photo     string `json:"photo" validate:"omitempty,url"`
+}
+
+// User returns the user to store for the registration
+func (r UserRegister) User() User {
+	return User{
+		FirstName: r.FirstName,
+		Lastname:  r.Lastname,
+		UserName:  r.UserName,
+		Email:     r.Email,
+		Password:  r.Password,
+		Photo:     r.Photo,
+	}
+}
+
+// UserCreate is the body admins create users with, it may set the role
+type UserCreate struct {
+	UserRegister
+	Role string `json:"role" validate:"omitempty,oneof=admin user guest"`
 }
 
 type UserLogin struct {
-	Email    string `bson:"email,required,unique"`
-	Password string `bson:"password,required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserUpdate struct {
-	FirstName string `bson:"first_name,required"`
-	Lastname  string `bson:"last_name,required"`
-	Email     string `bson:"email,required,unique"`
-	Password  string `bson:"password,required"`
+	FirstName string `json:"first_name" validate:"required,max=50"`
+	Lastname  string `json:"last_name" validate:"required,max=50"`
+	Password  string `json:"password" validate:"omitempty,min=8,max=72"`
+	// CurrentPassword proves users changing their own password know it
+	CurrentPassword string `json:"current_password" validate:"required_with=Password,omitempty,max=72"`
+	Photo           string `json:"photo" validate:"omitempty,url"`
+}
 
-	Photo string `bson:"photo,omitempty"`
-	Role  string `bson:"role,default:user"`
+type RoleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=admin user guest"`
 }
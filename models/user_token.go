@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purposes of a UserToken
+const (
+	TokenEmailVerification = "email_verification"
+	TokenPasswordReset     = "password_reset"
+)
+
+// UserToken is a single-use token mailed to a user to verify their email or
+// reset their password. Like refresh tokens only the SHA-256 hash of the
+// token is persisted.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Purpose   string             `bson:"purpose"`
+	TokenHash string             `bson:"token_hash"`
+	Email     string             `bson:"email"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	UsedAt    *time.Time         `bson:"usedAt,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt,omitempty"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// FakeUser is an account of the fake issuer
+type FakeUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// FakeIssuer is an in-process OpenID Connect provider for local development
+// and tests. It signs in the user whose email is the login_hint of the
+// authorization request without asking, or the first of Users, and denies
+// the sign-in when there is none. Its issuer is the URL it is served at.
+type FakeIssuer struct {
+	mu           sync.Mutex
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	codes        map[string]fakeCode
+
+	Users []FakeUser
+}
+
+type fakeCode struct {
+	user        FakeUser
+	redirectURI string
+	challenge   string
+	nonce       string
+	expiresAt   time.Time
+}
+
+const fakeKeyID = "fake"
+
+func NewFakeIssuer(clientID, clientSecret string) (*FakeIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &FakeIssuer{clientID: clientID, clientSecret: clientSecret, key: key, codes: map[string]fakeCode{}}, nil
+}
+
+func (f *FakeIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	issuer := "http://" + r.Host
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                issuer,
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"jwks_uri":                              issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/authorize":
+		f.authorize(w, r)
+	case "/token":
+		f.token(w, r, issuer)
+	case "/jwks":
+		b64 := base64.RawURLEncoding
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": fakeKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   b64.EncodeToString(f.key.N.Bytes()),
+			"e":   b64.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *FakeIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() || q.Get("client_id") != f.clientID {
+		http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := redirect.Query()
+	back.Set("state", q.Get("state"))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.user(q.Get("login_hint"))
+	switch {
+	case q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		back.Set("error", "invalid_request")
+	case !ok:
+		back.Set("error", "access_denied")
+	default:
+		code, err := randomString()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.codes[code] = fakeCode{
+			user:        user,
+			redirectURI: q.Get("redirect_uri"),
+			challenge:   q.Get("code_challenge"),
+			nonce:       q.Get("nonce"),
+			expiresAt:   time.Now().Add(time.Minute),
+		}
+		back.Set("code", code)
+	}
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (f *FakeIssuer) user(hint string) (FakeUser, bool) {
+	for _, u := range f.Users {
+		if hint == "" || u.Email == hint {
+			return u, true
+		}
+	}
+	return FakeUser{}, false
+}
+
+func (f *FakeIssuer) token(w http.ResponseWriter, r *http.Request, issuer string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != f.clientID || secret != f.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	code, ok := f.codes[r.PostFormValue("code")]
+	// codes are single use, a failed redemption burns them too
+	delete(f.codes, r.PostFormValue("code"))
+	f.mu.Unlock()
+	if r.PostFormValue("grant_type") != "authorization_code" || !ok || time.Now().After(code.expiresAt) ||
+		code.redirectURI != r.PostFormValue("redirect_uri") || challengeOf(r.PostFormValue("code_verifier")) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            code.user.Subject,
+		"aud":            f.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          code.nonce,
+		"email":          code.user.Email,
+		"email_verified": code.user.EmailVerified,
+		"name":           strings.TrimSpace(code.user.GivenName + " " + code.user.FamilyName),
+		"given_name":     code.user.GivenName,
+		"family_name":    code.user.FamilyName,
+	})
+	token.Header["kid"] = fakeKeyID
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "fake-" + code.user.Subject,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
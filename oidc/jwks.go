@@ -0,0 +1,91 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// jwk is a public key in the JSON Web Key format of RFC 7517
+type jwk struct {
+	KeyType string `json:"kty"`
+	ID      string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKeys returns the signing keys of the set by their kid, keys of
+// unknown types are skipped
+func (s jwks) publicKeys() map[string]any {
+	keys := map[string]any{}
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key := k.publicKey(); key != nil {
+			keys[k.ID] = key
+		}
+	}
+	return keys
+}
+
+func (k jwk) publicKey() any {
+	b64 := base64.RawURLEncoding
+	switch k.KeyType {
+	case "RSA":
+		n, errN := b64.DecodeString(k.N)
+		e, errE := b64.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			return nil
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil
+		}
+		x, errX := b64.DecodeString(k.X)
+		y, errY := b64.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil
+		}
+		return key
+	case "OKP":
+		x, err := b64.DecodeString(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil
+		}
+		return ed25519.PublicKey(x)
+	}
+	return nil
+}
+
+// randomString returns 32 random bytes, URL safe
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,333 @@
+// Package oidc signs users in at OpenID Connect providers with the
+// authorization code flow and PKCE. It discovers the endpoints and keys of
+// a provider from its issuer and verifies the ID tokens it returns.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/tabed23/travel-api/config"
+)
+
+// ErrRejected is returned when the provider refused a sign-in or returned a
+// token that does not verify, other errors are failures to reach it
+var ErrRejected = errors.New("oidc: sign-in rejected")
+
+// algorithms are the ID token signing algorithms accepted, never none or
+// the HMAC ones keyed by the client secret
+var algorithms = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// clockSkew is how far the clocks of the provider and the service may drift
+const clockSkew = time.Minute
+
+// Claims are the claims of a verified ID token
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	GivenName         string
+	FamilyName        string
+	PreferredUsername string
+}
+
+// Provider is an OpenID Connect provider. Its discovery document is fetched
+// on first use, its keys again whenever a token names an unknown one.
+type Provider struct {
+	cfg    config.OIDCProvider
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]any
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// New returns the provider of cfg, its requests are sent with client
+func New(cfg config.OIDCProvider, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"email", "profile"}
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+// Name returns the name of the provider in the login routes
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// NewVerifier returns a random PKCE code verifier and its S256 challenge
+func NewVerifier() (verifier, challenge string, err error) {
+	verifier, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, challengeOf(verifier), nil
+}
+
+func challengeOf(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns where to send the user to sign in, the provider sends
+// them back to the redirect URL with state and a code
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems the code of a sign-in for its ID token and returns the
+// claims of the token, it must carry nonce
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: token endpoint: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: token endpoint: %w", err)
+	}
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return Claims{}, fmt.Errorf("oidc: token endpoint answered %v: %w", res.Status, err)
+	}
+	if token.Error != "" {
+		return Claims{}, fmt.Errorf("%w: %v", ErrRejected, strings.TrimSpace(token.Error+" "+token.ErrorDescription))
+	}
+	if res.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("oidc: token endpoint answered %v", res.Status)
+	}
+	if token.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: no id_token", ErrRejected)
+	}
+	return p.verify(ctx, d, token.IDToken, nonce)
+}
+
+// idToken are the claims of an ID token the service reads
+type idToken struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flag     `json:"email_verified"`
+	Name              string   `json:"name"`
+	GivenName         string   `json:"given_name"`
+	FamilyName        string   `json:"family_name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// Valid checks the lifetime of the token, the other claims are checked by
+// verify
+func (t *idToken) Valid() error {
+	now := time.Now()
+	if t.ExpiresAt == 0 || now.After(time.Unix(t.ExpiresAt, 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if t.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(t.IssuedAt, 0)) {
+		return fmt.Errorf("token issued in the future")
+	}
+	return nil
+}
+
+// audience is the aud claim, a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// flag is a boolean claim, some providers send them as strings
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	switch strings.Trim(string(b), `"`) {
+	case "true":
+		*f = true
+	default:
+		*f = false
+	}
+	return nil
+}
+
+func (p *Provider) verify(ctx context.Context, d *discovery, raw, nonce string) (Claims, error) {
+	parser := jwt.Parser{ValidMethods: algorithms}
+	var claims idToken
+	_, err := parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	switch {
+	case claims.Issuer != d.Issuer:
+		return Claims{}, fmt.Errorf("%w: token issued by %q", ErrRejected, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.cfg.ClientID):
+		return Claims{}, fmt.Errorf("%w: token is for %v", ErrRejected, claims.Audience)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return Claims{}, fmt.Errorf("%w: token authorized %q", ErrRejected, claims.AuthorizedParty)
+	case claims.Nonce != nonce:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrRejected)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: token has no subject", ErrRejected)
+	}
+	return Claims{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		GivenName:         claims.GivenName,
+		FamilyName:        claims.FamilyName,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// discover returns the discovery document of the provider, it must be the
+// one of the configured issuer
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d discovery
+	if err := p.get(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: %v announces the issuer %q", p.cfg.Issuer, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: %v misses endpoints in its discovery document", p.cfg.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key returns the public key kid of the provider, a token without kid
+// verifies with the only key of the provider
+func (p *Provider) key(ctx context.Context, d *discovery, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	var set jwks
+	if err := p.get(ctx, d.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = set.publicKeys()
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) lookup(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) get(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %v answered %v", target, res.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: %v: %w", target, err)
+	}
+	return nil
+}
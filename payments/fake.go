@@ -0,0 +1,149 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Payment methods understood by the fake gateway
+const (
+	FakeMethodSuccess = "pm_success"
+	FakeMethodDecline = "pm_decline"
+	FakeMethodDelayed = "pm_delayed"
+)
+
+// FakeGateway is an in-process provider for local development and tests.
+// The outcome of a capture depends on the payment method of the intent:
+// pm_decline is declined, pm_delayed stays processing and succeeds later
+// through a webhook, anything else succeeds right away.
+type FakeGateway struct {
+	mu      sync.Mutex
+	secret  []byte
+	seq     int
+	intents map[string]*fakeIntent
+
+	// Delay before the webhook of a delayed capture is delivered
+	Delay time.Duration
+	// Sink receives signed webhook payloads, like the provider would POST them
+	Sink func(payload []byte, signature string)
+}
+
+type fakeIntent struct {
+	Intent
+	method string
+}
+
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{
+		secret:  []byte(secret),
+		intents: map[string]*fakeIntent{},
+		Delay:   2 * time.Second,
+	}
+}
+
+func (f *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (f *FakeGateway) CreateIntent(_ context.Context, req IntentRequest) (Intent, error) {
+	if req.Amount <= 0 {
+		return Intent{}, ErrInvalidAmount
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	intent := &fakeIntent{
+		Intent: Intent{
+			ID:        fmt.Sprintf("pi_fake_%d_%d", time.Now().UnixNano(), f.seq),
+			Amount:    req.Amount,
+			Currency:  req.Currency,
+			Reference: req.Reference,
+			Status:    IntentRequiresCapture,
+		},
+		method: req.PaymentMethod,
+	}
+	f.intents[intent.ID] = intent
+	return intent.Intent, nil
+}
+
+func (f *FakeGateway) Capture(_ context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	if intent.Status != IntentRequiresCapture {
+		return intent.Intent, nil
+	}
+	switch intent.method {
+	case FakeMethodDecline:
+		intent.Status = IntentDeclined
+		return intent.Intent, ErrDeclined
+	case FakeMethodDelayed:
+		intent.Status = IntentProcessing
+		time.AfterFunc(f.Delay, func() { f.settle(intentID) })
+	default:
+		intent.Status = IntentSucceeded
+	}
+	return intent.Intent, nil
+}
+
+// settle completes a delayed capture and delivers its webhook
+func (f *FakeGateway) settle(intentID string) {
+	f.mu.Lock()
+	intent := f.intents[intentID]
+	intent.Status = IntentSucceeded
+	event := Event{Type: "payment_intent.succeeded", IntentID: intent.ID, Status: intent.Status, Amount: intent.Amount}
+	sink := f.Sink
+	f.mu.Unlock()
+
+	if sink == nil {
+		return
+	}
+	payload, _ := json.Marshal(event)
+	sink(payload, f.Sign(payload))
+}
+
+func (f *FakeGateway) Refund(_ context.Context, intentID string, amount float64) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	if amount <= 0 || intent.Refunded+amount > intent.Amount || intent.Status != IntentSucceeded {
+		return intent.Intent, ErrInvalidAmount
+	}
+	intent.Refunded += amount
+	if intent.Refunded == intent.Amount {
+		intent.Status = IntentRefunded
+	}
+	return intent.Intent, nil
+}
+
+// VerifyWebhook refuses every payload when the gateway has no secret, as
+// anyone could sign them with the empty key
+func (f *FakeGateway) VerifyWebhook(payload []byte, signature string) (Event, error) {
+	if len(f.secret) == 0 || !hmac.Equal([]byte(f.Sign(payload)), []byte(signature)) {
+		return Event{}, ErrInvalidSignature
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature of payload
+func (f *FakeGateway) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package payments_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/tabed23/travel-api/payments"
+)
+
+// sign returns the signature of payload under key, like a caller knowing the
+// key would compute it
+func sign(key string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var succeeded = []byte(`{"type":"payment_intent.succeeded","intent_id":"pi_forged","status":"succeeded","amount":100}`)
+
+func TestVerifyWebhook(t *testing.T) {
+	g := payments.NewFakeGateway("webhook-secret")
+
+	event, err := g.VerifyWebhook(succeeded, sign("webhook-secret", succeeded))
+	if err != nil {
+		t.Fatalf("signed with the secret: %v", err)
+	}
+	if event.IntentID != "pi_forged" || event.Status != payments.IntentSucceeded {
+		t.Errorf("event %+v, want the succeeded pi_forged", event)
+	}
+	for name, signature := range map[string]string{
+		"other key": sign("other-secret", succeeded),
+		"empty key": sign("", succeeded),
+		"none":      "",
+	} {
+		if _, err := g.VerifyWebhook(succeeded, signature); !errors.Is(err, payments.ErrInvalidSignature) {
+			t.Errorf("%s: %v, want %v", name, err, payments.ErrInvalidSignature)
+		}
+	}
+}
+
+func TestVerifyWebhookWithoutSecret(t *testing.T) {
+	g := payments.NewFakeGateway("")
+
+	// anyone can compute the signature under the empty key
+	if _, err := g.VerifyWebhook(succeeded, sign("", succeeded)); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Errorf("signed with the empty key: %v, want %v", err, payments.ErrInvalidSignature)
+	}
+	if _, err := g.VerifyWebhook(succeeded, g.Sign(succeeded)); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Errorf("signed by the gateway: %v, want %v", err, payments.ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,78 @@
+// Package payments abstracts the payment provider used to charge bookings.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type IntentStatus string
+
+const (
+	IntentRequiresCapture IntentStatus = "requires_capture"
+	IntentProcessing      IntentStatus = "processing"
+	IntentSucceeded       IntentStatus = "succeeded"
+	IntentDeclined        IntentStatus = "declined"
+	IntentRefunded        IntentStatus = "refunded"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidAmount    = errors.New("invalid payment amount")
+)
+
+// SignatureHeader carries the webhook signature sent by the provider
+const SignatureHeader = "X-Payment-Signature"
+
+// IntentRequest describes the charge to create. PaymentMethod is the opaque
+// token the client obtained from the provider.
+type IntentRequest struct {
+	Amount        float64
+	Currency      string
+	Reference     string
+	PaymentMethod string
+}
+
+// Intent is the provider side state of a charge
+type Intent struct {
+	ID        string
+	Amount    float64
+	Refunded  float64
+	Currency  string
+	Reference string
+	Status    IntentStatus
+}
+
+// Event is a verified webhook notification
+type Event struct {
+	Type     string       `json:"type"`
+	IntentID string       `json:"intent_id"`
+	Status   IntentStatus `json:"status"`
+	Amount   float64      `json:"amount"`
+}
+
+// Gateway is implemented by every payment provider
+type Gateway interface {
+	// Name identifies the provider on stored payments
+	Name() string
+	// CreateIntent registers a charge that still has to be captured
+	CreateIntent(ctx context.Context, req IntentRequest) (Intent, error)
+	// Capture charges an intent, a declined charge returns ErrDeclined
+	Capture(ctx context.Context, intentID string) (Intent, error)
+	// Refund gives amount of a captured intent back
+	Refund(ctx context.Context, intentID string, amount float64) (Intent, error)
+	// VerifyWebhook checks the signature of a webhook payload and decodes it
+	VerifyWebhook(payload []byte, signature string) (Event, error)
+}
+
+// NewGateway returns the gateway for provider
+func NewGateway(provider, webhookSecret string) (Gateway, error) {
+	switch provider {
+	case "", "fake":
+		return NewFakeGateway(webhookSecret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", provider)
+}
@@ -0,0 +1,45 @@
+// Package policy decides whether a caller may act on a resource it does not
+// necessarily own. Admins bypass every ownership check.
+package policy
+
+import (
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// IsAdmin reports whether the caller has the admin role
+func IsAdmin(claims *utils.Claims) bool {
+	return claims != nil && claims.Role == constant.AdminRole
+}
+
+// CanAccessUser allows callers to manage their own account only
+func CanAccessUser(claims *utils.Claims, email string) error {
+	if claims == nil {
+		return errors.ErrUnAuthorized
+	}
+	if IsAdmin(claims) || (claims.Email != "" && claims.Email == email) {
+		return nil
+	}
+	return errors.ErrForbidden
+}
+
+// CanAccessBooking allows callers to manage the bookings they made
+func CanAccessBooking(claims *utils.Claims, booking models.Booking) error {
+	return CanAccessUser(claims, booking.UserEmail)
+}
+
+// CanModifyReview allows callers to modify the reviews they wrote
+func CanModifyReview(claims *utils.Claims, review models.Review) error {
+	return CanAccessUser(claims, review.UserEmail)
+}
+
+// CanBook allows users to book once they verified their email, admins may
+// book for unverified users
+func CanBook(claims *utils.Claims, user models.User) error {
+	if user.Unverified && !IsAdmin(claims) {
+		return errors.ErrEmailUnverified
+	}
+	return nil
+}
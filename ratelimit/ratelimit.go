@@ -0,0 +1,132 @@
+// Package ratelimit implements token bucket rate limiting. A bucket holds up
+// to Limit.Requests tokens and refills at Limit.Requests per Limit.Window,
+// every request takes one token and is refused when the bucket is empty.
+// Buckets live in a Backend, in memory for a single instance or in MongoDB
+// to share them between instances.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the rate of a bucket
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Rate returns how many tokens the bucket regains per second
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// Result describes a bucket after a request took a token from it
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of requests the bucket still allows now
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, it is zero
+	// when Allowed is true
+	RetryAfter time.Duration
+}
+
+// Backend stores the buckets, Take must be atomic for a given key
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a token bucket
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills b for the time elapsed since its last update and takes a
+// token from it when one is left. A zero b is a full bucket.
+func (b Bucket) Take(limit Limit, now time.Time) (Bucket, Result) {
+	capacity := float64(limit.Requests)
+	tokens := capacity
+	if !b.UpdatedAt.IsZero() {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, b.Tokens+elapsed*limit.Rate())
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return Bucket{Tokens: tokens, UpdatedAt: now}, NewResult(limit, tokens, allowed)
+}
+
+// NewResult describes a bucket of limit holding tokens
+func NewResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.Rate()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return r
+}
+
+// FullAt returns when a bucket of limit holding tokens at now is full again,
+// a full bucket is the same as no bucket so it can be forgotten from then on
+func FullAt(limit Limit, tokens float64, now time.Time) time.Time {
+	return now.Add(seconds((float64(limit.Requests) - tokens) / limit.Rate()))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// Memory is a Backend keeping the buckets of one instance in memory
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	takes   int
+}
+
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// sweepEvery is how many takes pass between two sweeps of the full buckets
+const sweepEvery = 1024
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]memoryBucket{}}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.takes++
+	if m.takes%sweepEvery == 0 {
+		m.sweep(now)
+	}
+	b, res := m.buckets[key].Take(limit, now)
+	m.buckets[key] = memoryBucket{Bucket: b, fullAt: FullAt(limit, b.Tokens, now)}
+	return res, nil
+}
+
+// sweep forgets the buckets that have refilled, the caller holds the lock
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.fullAt) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/ratelimit"
+)
+
+// perMinute allows three requests a minute, one every 20 seconds
+var perMinute = ratelimit.Limit{Requests: 3, Window: time.Minute}
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestBucketBurst(t *testing.T) {
+	var b ratelimit.Bucket
+	var res ratelimit.Result
+	for want := 2; want >= 0; want-- {
+		b, res = b.Take(perMinute, start)
+		if !res.Allowed || res.Remaining != want {
+			t.Fatalf("take %d: %+v, want allowed with %d remaining", 3-want, res, want)
+		}
+	}
+	if res.Reset != time.Minute {
+		t.Errorf("reset %v once empty, want %v", res.Reset, time.Minute)
+	}
+	_, res = b.Take(perMinute, start)
+	if res.Allowed || res.Remaining != 0 {
+		t.Errorf("take beyond the burst: %+v, want refused", res)
+	}
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("retry after %v, want 20s", res.RetryAfter)
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	var b ratelimit.Bucket
+	for i := 0; i < perMinute.Requests; i++ {
+		b, _ = b.Take(perMinute, start)
+	}
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{"before a token is back", 19 * time.Second, false, 0},
+		{"one token back", 20 * time.Second, true, 0},
+		{"two tokens back", 40 * time.Second, true, 1},
+		{"full again", time.Minute, true, 2},
+		// a bucket does not fill beyond its capacity
+		{"long idle", time.Hour, true, 2},
+		// a clock going backwards refills nothing
+		{"clock skew", -time.Minute, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, res := b.Take(perMinute, start.Add(tt.elapsed))
+			if res.Allowed != tt.allowed || res.Remaining != tt.remaining {
+				t.Errorf("got %+v, want allowed %v with %d remaining", res, tt.allowed, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestMemoryKeepsKeysApart(t *testing.T) {
+	m := ratelimit.NewMemory()
+	ctx := context.Background()
+	one := ratelimit.Limit{Requests: 1, Window: time.Minute}
+
+	if res, _ := m.Take(ctx, "ip:1.2.3.4", one, start); !res.Allowed {
+		t.Fatalf("first take refused")
+	}
+	if res, _ := m.Take(ctx, "ip:1.2.3.4", one, start); res.Allowed {
+		t.Errorf("second take of the same key allowed")
+	}
+	if res, _ := m.Take(ctx, "ip:5.6.7.8", one, start); !res.Allowed {
+		t.Errorf("take of another key refused")
+	}
+	if res, _ := m.Take(ctx, "ip:1.2.3.4", one, start.Add(time.Minute)); !res.Allowed {
+		t.Errorf("take after the window refused")
+	}
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKeyStore struct {
+	db *DB
+}
+
+func (s *APIKeyStore) Create(_ context.Context, key models.APIKey) (models.APIKey, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for _, k := range s.db.apiKeys {
+		if k.KeyHash == key.KeyHash || k.Prefix == key.Prefix {
+			return models.APIKey{}, errors.ErrDuplicate
+		}
+	}
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = time.Now().UTC()
+	s.db.apiKeys[key.ID] = cloneAPIKey(key)
+	return key, nil
+}
+
+func (s *APIKeyStore) Get(_ context.Context, id string) (models.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	key, ok := s.db.apiKeys[objectID(id)]
+	if !ok {
+		return models.APIKey{}, errors.ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(key), nil
+}
+
+func (s *APIKeyStore) GetByHash(_ context.Context, hash string) (models.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	keys := sorted(s.db.apiKeys, func(k models.APIKey) bool { return k.KeyHash == hash })
+	if len(keys) == 0 {
+		return models.APIKey{}, errors.ErrInvalidAPIKey
+	}
+	return cloneAPIKey(keys[0]), nil
+}
+
+func (s *APIKeyStore) GetAll(_ context.Context, page, limit int) ([]models.APIKey, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	all := sorted(s.db.apiKeys, nil)
+	// newest first, like the MongoDB store
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	keys, err := paginate(all, page, limit)
+	if err != nil {
+		return []models.APIKey{}, 0, err
+	}
+	for i := range keys {
+		keys[i] = cloneAPIKey(keys[i])
+	}
+	return keys, len(all), nil
+}
+
+func (s *APIKeyStore) Revoke(_ context.Context, id string) (models.APIKey, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	key, ok := s.db.apiKeys[objectID(id)]
+	if !ok {
+		return models.APIKey{}, errors.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		revokedAt := time.Now().UTC()
+		key.RevokedAt = &revokedAt
+		s.db.apiKeys[key.ID] = key
+	}
+	return cloneAPIKey(key), nil
+}
+
+func (s *APIKeyStore) Touch(_ context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	key, ok := s.db.apiKeys[id]
+	if !ok {
+		return nil
+	}
+	if key.LastUsedAt != nil && key.LastUsedIP == ip && key.LastUsedAt.After(now.Add(-store.APIKeyTouchInterval)) {
+		return nil
+	}
+	usedAt := now.UTC()
+	key.LastUsedAt = &usedAt
+	key.LastUsedIP = ip
+	s.db.apiKeys[id] = key
+	return nil
+}
+
+func cloneAPIKey(k models.APIKey) models.APIKey {
+	k.Scopes = append([]string(nil), k.Scopes...)
+	k.AllowedIPs = append([]string(nil), k.AllowedIPs...)
+	for _, at := range []**time.Time{&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt} {
+		if *at != nil {
+			t := **at
+			*at = &t
+		}
+	}
+	return k
+}
@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BookingStore struct {
+	db *DB
+}
+
+func (b *BookingStore) GetBooking(_ context.Context, id string) (models.Booking, error) {
+	b.db.mu.RLock()
+	defer b.db.mu.RUnlock()
+	booking, ok := b.db.bookings[objectID(id)]
+	if !ok {
+		return models.Booking{}, errors.ErrBookingNotFound
+	}
+	return cloneBooking(booking), nil
+}
+
+func (b *BookingStore) CreateBooking(_ context.Context, userId string, book models.Booking) (models.Booking, error) {
+	objId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return models.Booking{}, errors.ErrUserNotFound
+	}
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	usr, ok := b.db.users[objId]
+	if !ok {
+		return models.Booking{}, errors.ErrUserNotFound
+	}
+	if book.DepartureID.IsZero() || book.GuestSize <= 0 {
+		return models.Booking{}, errors.ErrBadRequest
+	}
+	dep, ok := b.db.departures[book.DepartureID]
+	if !ok {
+		return models.Booking{}, errors.ErrDepartureNotFound
+	}
+	tour, ok := b.db.tours[dep.TourID]
+	if !ok {
+		return models.Booking{}, errors.ErrTourNotFound
+	}
+	if !tour.AllowsGroup(book.GuestSize) {
+		return models.Booking{}, errors.ErrGroupTooLarge
+	}
+	if err := b.db.reserveSeats(dep.ID, true, book.GuestSize); err != nil {
+		return models.Booking{}, err
+	}
+	book.ID = primitive.NewObjectID()
+	book.TourID = dep.TourID
+	book.TourName = tour.Title
+	book.UserID = usr.ID
+	book.UserEmail = usr.Email
+	book.UnitPrice = tour.Price
+	if dep.PriceOverride != nil {
+		book.UnitPrice = *dep.PriceOverride
+	}
+	book.TotalPrice = book.UnitPrice * float64(book.GuestSize)
+	book.RefundAmount = 0
+	book.Status = models.BookingHeld
+	book.CreatedAt = time.Now().UTC()
+	book.UpdatedAt = time.Now().UTC()
+	expires := book.CreatedAt.Add(b.db.holdFor)
+	book.HoldExpiresAt = &expires
+	book.History = []models.BookingEvent{{To: models.BookingHeld, Actor: usr.Email, At: book.CreatedAt}}
+	b.db.bookings[book.ID] = cloneBooking(book)
+	return book, nil
+}
+
+func (b *BookingStore) Transition(_ context.Context, id string, to models.BookingStatus, actor, note string) (models.Booking, error) {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	current, ok := b.db.bookings[objectID(id)]
+	if !ok {
+		return models.Booking{}, errors.ErrBookingNotFound
+	}
+	booking, err := b.transition(current, to, actor, note)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	return cloneBooking(booking), nil
+}
+
+// transition applies a status change to a booking and moves its seats
+// between the departure counters, the caller holds the lock
+func (b *BookingStore) transition(current models.Booking, to models.BookingStatus, actor, note string) (models.Booking, error) {
+	if !current.Status.CanTransitionTo(to) {
+		return models.Booking{}, errors.ErrInvalidTransition
+	}
+	now := time.Now().UTC()
+	if current.Status == models.BookingHeld && to == models.BookingConfirmed &&
+		current.HoldExpiresAt != nil && current.HoldExpiresAt.Before(now) {
+		return models.Booking{}, errors.ErrHoldExpired
+	}
+	booking := cloneBooking(current)
+	if to == models.BookingCancelled && current.Status == models.BookingConfirmed {
+		booking.RefundAmount = b.refundFor(current, now)
+	}
+	booking.Status = to
+	booking.UpdatedAt = now
+	booking.History = append(booking.History, models.BookingEvent{From: current.Status, To: to, Actor: actor, Note: note, At: now})
+	if to != models.BookingHeld {
+		booking.HoldExpiresAt = nil
+	}
+	b.db.bookings[booking.ID] = booking
+	if !current.DepartureID.IsZero() {
+		switch {
+		case to == models.BookingCancelled || to == models.BookingExpired:
+			b.db.releaseSeats(current.DepartureID, current.Status == models.BookingHeld, current.GuestSize)
+		case current.Status == models.BookingHeld && to == models.BookingConfirmed:
+			b.db.sellHeldSeats(current.DepartureID, current.GuestSize)
+		}
+	}
+	return booking, nil
+}
+
+func (b *BookingStore) ExpireHolds(_ context.Context, now time.Time) (int, error) {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	held := sorted(b.db.bookings, func(booking models.Booking) bool {
+		return booking.Status == models.BookingHeld && booking.HoldExpiresAt != nil && !booking.HoldExpiresAt.After(now)
+	})
+	for _, booking := range held {
+		if _, err := b.transition(booking, models.BookingExpired, "system", "hold expired"); err != nil {
+			return 0, err
+		}
+	}
+	return len(held), nil
+}
+
+// refundFor returns the amount refunded when the booking is cancelled at the
+// given time, the caller holds the lock
+func (b *BookingStore) refundFor(booking models.Booking, at time.Time) float64 {
+	dep, ok := b.db.departures[booking.DepartureID]
+	if !ok {
+		return 0
+	}
+	tour := b.db.tours[dep.TourID]
+	daysBefore := int(dep.StartDate.Sub(at).Hours() / 24)
+	return booking.TotalPrice * tour.RefundPercent(daysBefore) / 100
+}
+
+func (b *BookingStore) Update(_ context.Context, id string, bookUpdate models.UpdateBooking) (models.Booking, error) {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	booking, ok := b.db.bookings[objectID(id)]
+	if !ok {
+		return models.Booking{}, errors.ErrBookingNotFound
+	}
+	if bookUpdate.GuestSize <= 0 {
+		return models.Booking{}, errors.ErrBadRequest
+	}
+	if !booking.Status.HoldsSeats() {
+		return models.Booking{}, errors.ErrInvalidTransition
+	}
+	if tour := b.db.tours[booking.TourID]; !tour.AllowsGroup(bookUpdate.GuestSize) {
+		return models.Booking{}, errors.ErrGroupTooLarge
+	}
+	delta := bookUpdate.GuestSize - booking.GuestSize
+	held := booking.Status == models.BookingHeld
+	switch {
+	case booking.DepartureID.IsZero() || delta == 0:
+	case delta > 0:
+		if err := b.db.reserveSeats(booking.DepartureID, held, delta); err != nil {
+			return models.Booking{}, err
+		}
+	default:
+		b.db.releaseSeats(booking.DepartureID, held, -delta)
+	}
+	booking = cloneBooking(booking)
+	booking.GuestSize = bookUpdate.GuestSize
+	booking.TotalPrice = booking.UnitPrice * float64(bookUpdate.GuestSize)
+	booking.Phone = bookUpdate.Phone
+	booking.UpdatedAt = time.Now().UTC()
+	b.db.bookings[booking.ID] = booking
+	return cloneBooking(booking), nil
+}
+
+func (b *BookingStore) GetAll(_ context.Context, page, limit int) ([]models.Booking, int, error) {
+	return b.find(nil, page, limit)
+}
+
+func (b *BookingStore) GetByEmail(_ context.Context, email string, page, limit int) ([]models.Booking, int, error) {
+	return b.find(func(booking models.Booking) bool { return booking.UserEmail == email }, page, limit)
+}
+
+func (b *BookingStore) find(keep func(models.Booking) bool, page, limit int) ([]models.Booking, int, error) {
+	b.db.mu.RLock()
+	defer b.db.mu.RUnlock()
+	all := sorted(b.db.bookings, keep)
+	bookings, err := paginate(all, page, limit)
+	if err != nil {
+		return []models.Booking{}, 0, err
+	}
+	for i := range bookings {
+		bookings[i] = cloneBooking(bookings[i])
+	}
+	return bookings, len(all), nil
+}
+
+func (b *BookingStore) Count(_ context.Context) (int, error) {
+	b.db.mu.RLock()
+	defer b.db.mu.RUnlock()
+	return len(b.db.bookings), nil
+}
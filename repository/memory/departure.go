@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DepartureStore struct {
+	db *DB
+}
+
+func (d *DepartureStore) Create(_ context.Context, tourId string, dep models.Departure) (models.Departure, error) {
+	tourObjId, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		return models.Departure{}, errors.ErrTourNotFound
+	}
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	if _, ok := d.db.tours[tourObjId]; !ok {
+		return models.Departure{}, errors.ErrTourNotFound
+	}
+	dep.ID = primitive.NewObjectID()
+	dep.TourID = tourObjId
+	dep.SeatsHeld = 0
+	dep.SeatsSold = 0
+	dep.CreatedAt = time.Now().UTC()
+	dep.UpdatedAt = time.Now().UTC()
+	d.db.departures[dep.ID] = cloneDeparture(dep)
+	return dep, nil
+}
+
+func (d *DepartureStore) Get(_ context.Context, id string) (models.Departure, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+	d.db.mu.RLock()
+	defer d.db.mu.RUnlock()
+	dep, ok := d.db.departures[objId]
+	if !ok {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+	return cloneDeparture(dep), nil
+}
+
+func (d *DepartureStore) GetByTour(_ context.Context, tourId string) ([]models.Departure, error) {
+	tourObjId, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		return []models.Departure{}, errors.ErrTourNotFound
+	}
+	d.db.mu.RLock()
+	defer d.db.mu.RUnlock()
+	departures := sorted(d.db.departures, func(dep models.Departure) bool { return dep.TourID == tourObjId })
+	sort.SliceStable(departures, func(i, j int) bool { return departures[i].StartDate.Before(departures[j].StartDate) })
+	for i := range departures {
+		departures[i] = cloneDeparture(departures[i])
+	}
+	return departures, nil
+}
+
+func (d *DepartureStore) Update(_ context.Context, id string, upd models.UpdateDeparture) (models.Departure, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	dep, ok := d.db.departures[objId]
+	if !ok {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+	if dep.SeatsHeld+dep.SeatsSold > upd.Capacity {
+		return models.Departure{}, errors.ErrDepartureHasSeats
+	}
+	dep.StartDate = upd.StartDate
+	dep.EndDate = upd.EndDate
+	dep.Capacity = upd.Capacity
+	dep.PriceOverride = upd.PriceOverride
+	dep.UpdatedAt = time.Now().UTC()
+	dep = cloneDeparture(dep)
+	d.db.departures[objId] = dep
+	return cloneDeparture(dep), nil
+}
+
+func (d *DepartureStore) Delete(_ context.Context, id string) (bool, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.ErrDepartureNotFound
+	}
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	dep, ok := d.db.departures[objId]
+	if !ok {
+		return false, errors.ErrDepartureNotFound
+	}
+	if dep.SeatsHeld != 0 || dep.SeatsSold != 0 {
+		return false, errors.ErrDepartureHasSeats
+	}
+	delete(d.db.departures, objId)
+	return true, nil
+}
+
+// reserveSeats takes n seats of a departure into the held or sold counter,
+// the caller holds the lock
+func (db *DB) reserveSeats(id primitive.ObjectID, held bool, n int64) error {
+	dep, ok := db.departures[id]
+	if !ok {
+		return errors.ErrDepartureNotFound
+	}
+	if dep.SeatsHeld+dep.SeatsSold+n > dep.Capacity {
+		return errors.ErrNoSeatsAvailable
+	}
+	if held {
+		dep.SeatsHeld += n
+	} else {
+		dep.SeatsSold += n
+	}
+	dep.UpdatedAt = time.Now().UTC()
+	db.departures[id] = dep
+	return nil
+}
+
+// releaseSeats gives n seats of the held or sold counter back to a
+// departure, the caller holds the lock
+func (db *DB) releaseSeats(id primitive.ObjectID, held bool, n int64) {
+	dep, ok := db.departures[id]
+	if !ok {
+		return
+	}
+	switch {
+	case held && dep.SeatsHeld >= n:
+		dep.SeatsHeld -= n
+	case !held && dep.SeatsSold >= n:
+		dep.SeatsSold -= n
+	default:
+		return
+	}
+	dep.UpdatedAt = time.Now().UTC()
+	db.departures[id] = dep
+}
+
+// sellHeldSeats turns n held seats of a departure into sold seats, the
+// caller holds the lock
+func (db *DB) sellHeldSeats(id primitive.ObjectID, n int64) {
+	dep, ok := db.departures[id]
+	if !ok || dep.SeatsHeld < n {
+		return
+	}
+	dep.SeatsHeld -= n
+	dep.SeatsSold += n
+	dep.UpdatedAt = time.Now().UTC()
+	db.departures[id] = dep
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type IdentityStore struct {
+	db *DB
+}
+
+func (s *IdentityStore) Get(_ context.Context, provider, subject string) (models.Identity, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	for _, identity := range s.db.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return models.Identity{}, errors.ErrIdentityNotFound
+}
+
+func (s *IdentityStore) Link(_ context.Context, identity models.Identity, now time.Time) (models.Identity, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for id, linked := range s.db.identities {
+		if linked.Provider == identity.Provider && linked.Subject == identity.Subject {
+			linked.Email = identity.Email
+			linked.LastLoginAt = now.UTC()
+			s.db.identities[id] = linked
+			return linked, nil
+		}
+	}
+	identity.ID = primitive.NewObjectID()
+	identity.CreatedAt = now.UTC()
+	identity.LastLoginAt = now.UTC()
+	s.db.identities[identity.ID] = identity
+	return identity, nil
+}
+
+func (s *IdentityStore) GetByEmail(_ context.Context, email string) ([]models.Identity, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return sorted(s.db.identities, func(identity models.Identity) bool { return identity.Email == email }), nil
+}
+
+type OIDCStateStore struct {
+	db *DB
+}
+
+func (s *OIDCStateStore) Create(_ context.Context, state models.OIDCState) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	state.ID = primitive.NewObjectID()
+	state.CreatedAt = time.Now().UTC()
+	s.db.oidcStates[state.ID] = state
+	return nil
+}
+
+func (s *OIDCStateStore) Consume(_ context.Context, hash string, now time.Time) (models.OIDCState, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for id, state := range s.db.oidcStates {
+		if state.StateHash == hash && now.Before(state.ExpiresAt) {
+			delete(s.db.oidcStates, id)
+			return state, nil
+		}
+	}
+	return models.OIDCState{}, errors.ErrInvalidOIDCState
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoginAttemptStore struct {
+	db *DB
+}
+
+func (s *LoginAttemptStore) Record(_ context.Context, attempt models.LoginAttempt) (models.LoginAttempt, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	attempt.ID = primitive.NewObjectID()
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now().UTC()
+	}
+	s.db.loginAttempts[attempt.ID] = attempt
+	return attempt, nil
+}
+
+func (s *LoginAttemptStore) GetByEmail(_ context.Context, email string, page, limit int) ([]models.LoginAttempt, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	all := sorted(s.db.loginAttempts, func(a models.LoginAttempt) bool { return a.Email == email })
+	// newest first, like the MongoDB store
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	attempts, err := paginate(all, page, limit)
+	if err != nil {
+		return []models.LoginAttempt{}, 0, err
+	}
+	return attempts, len(all), nil
+}
+
+func (s *LoginAttemptStore) SuccessfulIPs(_ context.Context, email string) ([]string, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	seen := map[string]bool{}
+	ips := []string{}
+	for _, a := range sorted(s.db.loginAttempts, func(a models.LoginAttempt) bool { return a.Email == email && a.Success }) {
+		if !seen[a.IP] {
+			seen[a.IP] = true
+			ips = append(ips, a.IP)
+		}
+	}
+	return ips, nil
+}
+
+type LoginLockStore struct {
+	db *DB
+}
+
+// current returns the lock of key at now, the caller holds the lock
+func (s *LoginLockStore) current(key string, now time.Time) models.LoginLock {
+	lock, ok := s.db.loginLocks[key]
+	if !ok || !now.Before(lock.ExpiresAt) {
+		return models.LoginLock{Key: key}
+	}
+	return lock
+}
+
+func (s *LoginLockStore) Get(_ context.Context, key string, now time.Time) (models.LoginLock, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return s.current(key, now), nil
+}
+
+func (s *LoginLockStore) Fail(_ context.Context, key string, now time.Time, forgetAfter time.Duration) (models.LoginLock, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	lock := s.current(key, now)
+	lock.Failures++
+	lock.LastFailure = now
+	lock.ExpiresAt = now.Add(forgetAfter)
+	s.db.loginLocks[key] = lock
+	return lock, nil
+}
+
+func (s *LoginLockStore) Lock(_ context.Context, key string, until time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	lock, ok := s.db.loginLocks[key]
+	if !ok {
+		return nil
+	}
+	if until.After(lock.LockedUntil) {
+		lock.LockedUntil = until
+	}
+	if until.After(lock.ExpiresAt) {
+		lock.ExpiresAt = until
+	}
+	s.db.loginLocks[key] = lock
+	return nil
+}
+
+func (s *LoginLockStore) Reset(_ context.Context, key string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	delete(s.db.loginLocks, key)
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package memory implements every repository of the store package in memory.
+// It mirrors the behaviour of the MongoDB stores, including the errors they
+// return, so the HTTP layer can be exercised without a database.
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/ratelimit"
+	"github.com/tabed23/travel-api/repository/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DB holds the collections shared by the in-memory stores. A single lock
+// guards every collection so operations spanning several of them, like
+// booking seats on a departure, are atomic.
+type DB struct {
+	mu            sync.RWMutex
+	holdFor       time.Duration
+	tours         map[primitive.ObjectID]models.Tour
+	users         map[primitive.ObjectID]models.User
+	bookings      map[primitive.ObjectID]models.Booking
+	reviews       map[primitive.ObjectID]models.Review
+	departures    map[primitive.ObjectID]models.Departure
+	payments      map[primitive.ObjectID]models.Payment
+	refreshTokens map[primitive.ObjectID]models.RefreshToken
+	loginAttempts map[primitive.ObjectID]models.LoginAttempt
+	loginLocks    map[string]models.LoginLock
+	userTokens    map[primitive.ObjectID]models.UserToken
+	mfa           map[string]models.MFA
+	signingKeys   map[string]models.SigningKey
+	apiKeys       map[primitive.ObjectID]models.APIKey
+	identities    map[primitive.ObjectID]models.Identity
+	oidcStates    map[primitive.ObjectID]models.OIDCState
+	sessions      map[primitive.ObjectID]models.Session
+}
+
+func New(holdFor time.Duration) *DB {
+	if holdFor <= 0 {
+		holdFor = store.DefaultHoldWindow
+	}
+	return &DB{
+		holdFor:       holdFor,
+		tours:         map[primitive.ObjectID]models.Tour{},
+		users:         map[primitive.ObjectID]models.User{},
+		bookings:      map[primitive.ObjectID]models.Booking{},
+		reviews:       map[primitive.ObjectID]models.Review{},
+		departures:    map[primitive.ObjectID]models.Departure{},
+		payments:      map[primitive.ObjectID]models.Payment{},
+		refreshTokens: map[primitive.ObjectID]models.RefreshToken{},
+		loginAttempts: map[primitive.ObjectID]models.LoginAttempt{},
+		loginLocks:    map[string]models.LoginLock{},
+		userTokens:    map[primitive.ObjectID]models.UserToken{},
+		mfa:           map[string]models.MFA{},
+		signingKeys:   map[string]models.SigningKey{},
+		apiKeys:       map[primitive.ObjectID]models.APIKey{},
+		identities:    map[primitive.ObjectID]models.Identity{},
+		oidcStates:    map[primitive.ObjectID]models.OIDCState{},
+		sessions:      map[primitive.ObjectID]models.Session{},
+	}
+}
+
+// Stores returns the repositories backed by db
+func (db *DB) Stores() store.Stores {
+	return store.Stores{
+		Tours:         &TourStore{db: db},
+		Users:         &UserStore{db: db},
+		Bookings:      &BookingStore{db: db},
+		Reviews:       &ReviewStore{db: db},
+		Departures:    &DepartureStore{db: db},
+		Payments:      &PaymentStore{db: db},
+		RefreshTokens: &RefreshTokenStore{db: db},
+		RateLimits:    ratelimit.NewMemory(),
+		LoginAttempts: &LoginAttemptStore{db: db},
+		LoginLocks:    &LoginLockStore{db: db},
+		UserTokens:    &UserTokenStore{db: db},
+		MFA:           &MFAStore{db: db},
+		SigningKeys:   &SigningKeyStore{db: db},
+		APIKeys:       &APIKeyStore{db: db},
+		Identities:    &IdentityStore{db: db},
+		OIDCStates:    &OIDCStateStore{db: db},
+		Sessions:      &SessionStore{db: db},
+	}
+}
+
+// sorted returns the values of m in insertion order, which is the natural
+// order MongoDB returns documents in. Object IDs created by one process grow
+// monotonically, so ordering by ID restores insertion order.
+func sorted[T any](m map[primitive.ObjectID]T, keep func(T) bool) []T {
+	ids := make([]primitive.ObjectID, 0, len(m))
+	for id, v := range m {
+		if keep == nil || keep(v) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i][:], ids[j][:]) < 0 })
+	out := make([]T, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, m[id])
+	}
+	return out
+}
+
+// paginate applies the skip and limit the MongoDB stores compute from page
+// and limit. A limit of zero means no limit, like in MongoDB.
+func paginate[T any](items []T, page, limit int) ([]T, error) {
+	skip := (page - 1) * limit
+	if skip < 0 {
+		return nil, fmt.Errorf("skip value must be non-negative, but received: %d", skip)
+	}
+	if skip >= len(items) {
+		return []T{}, nil
+	}
+	items = items[skip:]
+	if limit < 0 {
+		limit = -limit
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func cloneTour(t models.Tour) models.Tour {
+	t.Reviews = append([]primitive.ObjectID(nil), t.Reviews...)
+	t.CancellationPolicy = append([]models.CancellationRule(nil), t.CancellationPolicy...)
+	if t.Location != nil {
+		loc := *t.Location
+		loc.Coordinates = append([]float64(nil), loc.Coordinates...)
+		t.Location = &loc
+	}
+	return t
+}
+
+func cloneBooking(b models.Booking) models.Booking {
+	b.History = append([]models.BookingEvent(nil), b.History...)
+	if b.HoldExpiresAt != nil {
+		at := *b.HoldExpiresAt
+		b.HoldExpiresAt = &at
+	}
+	return b
+}
+
+func cloneDeparture(d models.Departure) models.Departure {
+	if d.PriceOverride != nil {
+		price := *d.PriceOverride
+		d.PriceOverride = &price
+	}
+	return d
+}
+
+func cloneRefreshToken(t models.RefreshToken) models.RefreshToken {
+	if t.RevokedAt != nil {
+		at := *t.RevokedAt
+		t.RevokedAt = &at
+	}
+	return t
+}
+
+// objectID parses id like the MongoDB stores that ignore the parse error
+func objectID(id string) primitive.ObjectID {
+	objId, _ := primitive.ObjectIDFromHex(id)
+	return objId
+}
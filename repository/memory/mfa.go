@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+type MFAStore struct {
+	db *DB
+}
+
+func (s *MFAStore) Get(_ context.Context, email string) (models.MFA, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	mfa, ok := s.db.mfa[email]
+	if !ok {
+		return models.MFA{}, errors.ErrMFANotEnrolled
+	}
+	return copyMFA(mfa), nil
+}
+
+func (s *MFAStore) Enroll(_ context.Context, mfa models.MFA) (models.MFA, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if s.db.mfa[mfa.Email].Enabled {
+		return models.MFA{}, errors.ErrMFAEnabled
+	}
+	mfa.Enabled = false
+	mfa.CreatedAt = time.Now().UTC()
+	s.db.mfa[mfa.Email] = copyMFA(mfa)
+	return mfa, nil
+}
+
+func (s *MFAStore) Enable(_ context.Context, email string, step int64, recoveryCodes []string) (models.MFA, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	mfa, ok := s.db.mfa[email]
+	if !ok || mfa.Enabled {
+		return models.MFA{}, errors.ErrMFANotEnrolled
+	}
+	mfa.Enabled = true
+	mfa.LastStep = step
+	mfa.RecoveryCodes = append([]string(nil), recoveryCodes...)
+	mfa.EnabledAt = time.Now().UTC()
+	s.db.mfa[email] = mfa
+	return copyMFA(mfa), nil
+}
+
+func (s *MFAStore) UseStep(_ context.Context, email string, step int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	mfa, ok := s.db.mfa[email]
+	if !ok || !mfa.Enabled || mfa.LastStep >= step {
+		return errors.ErrInvalidMFACode
+	}
+	mfa.LastStep = step
+	s.db.mfa[email] = mfa
+	return nil
+}
+
+func (s *MFAStore) UseRecoveryCode(_ context.Context, email, hash string) (models.MFA, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	mfa, ok := s.db.mfa[email]
+	if !ok || !mfa.Enabled {
+		return models.MFA{}, errors.ErrInvalidMFACode
+	}
+	for i, code := range mfa.RecoveryCodes {
+		if code == hash {
+			codes := append([]string(nil), mfa.RecoveryCodes[:i]...)
+			mfa.RecoveryCodes = append(codes, mfa.RecoveryCodes[i+1:]...)
+			s.db.mfa[email] = mfa
+			return copyMFA(mfa), nil
+		}
+	}
+	return models.MFA{}, errors.ErrInvalidMFACode
+}
+
+func (s *MFAStore) SetRecoveryCodes(_ context.Context, email string, recoveryCodes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	mfa, ok := s.db.mfa[email]
+	if !ok || !mfa.Enabled {
+		return errors.ErrMFANotEnrolled
+	}
+	mfa.RecoveryCodes = append([]string(nil), recoveryCodes...)
+	s.db.mfa[email] = mfa
+	return nil
+}
+
+func (s *MFAStore) Delete(_ context.Context, email string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.mfa[email]; !ok {
+		return errors.ErrMFANotEnrolled
+	}
+	delete(s.db.mfa, email)
+	return nil
+}
+
+// copyMFA keeps callers from sharing the recovery codes slice of the map
+func copyMFA(mfa models.MFA) models.MFA {
+	mfa.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	return mfa
+}
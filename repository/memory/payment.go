@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentStore struct {
+	db *DB
+}
+
+func (p *PaymentStore) Create(_ context.Context, payment models.Payment) (models.Payment, error) {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment.ID = primitive.NewObjectID()
+	payment.CreatedAt = time.Now().UTC()
+	payment.UpdatedAt = time.Now().UTC()
+	p.db.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (p *PaymentStore) Get(_ context.Context, id string) (models.Payment, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Payment{}, errors.ErrPaymentNotFound
+	}
+	p.db.mu.RLock()
+	defer p.db.mu.RUnlock()
+	payment, ok := p.db.payments[objId]
+	if !ok {
+		return models.Payment{}, errors.ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+func (p *PaymentStore) GetByIntent(_ context.Context, intentId string) (models.Payment, error) {
+	p.db.mu.RLock()
+	defer p.db.mu.RUnlock()
+	payments := sorted(p.db.payments, func(payment models.Payment) bool { return payment.IntentID == intentId })
+	if len(payments) == 0 {
+		return models.Payment{}, errors.ErrPaymentNotFound
+	}
+	return payments[0], nil
+}
+
+// GetSucceededByBooking returns the latest successful payment of a booking
+func (p *PaymentStore) GetSucceededByBooking(_ context.Context, bookingId primitive.ObjectID) (models.Payment, error) {
+	p.db.mu.RLock()
+	defer p.db.mu.RUnlock()
+	payments := sorted(p.db.payments, func(payment models.Payment) bool {
+		return payment.BookingID == bookingId && payment.Status == models.PaymentSucceeded
+	})
+	if len(payments) == 0 {
+		return models.Payment{}, errors.ErrPaymentNotFound
+	}
+	return payments[len(payments)-1], nil
+}
+
+// UpdateStatus moves a payment from one status to another, reporting false
+// when the payment was no longer in status from
+func (p *PaymentStore) UpdateStatus(_ context.Context, id primitive.ObjectID, from, to models.PaymentStatus, reason string) (bool, error) {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment, ok := p.db.payments[id]
+	if !ok || payment.Status != from || from == to {
+		return false, nil
+	}
+	payment.Status = to
+	payment.UpdatedAt = time.Now().UTC()
+	if reason != "" {
+		payment.FailureReason = reason
+	}
+	p.db.payments[id] = payment
+	return true, nil
+}
+
+// SetRefundPending records amount as owed back on a payment until AddRefund
+// records it as refunded
+func (p *PaymentStore) SetRefundPending(_ context.Context, id primitive.ObjectID, amount float64) error {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment, ok := p.db.payments[id]
+	if !ok {
+		return nil
+	}
+	payment.RefundPending = amount
+	payment.UpdatedAt = time.Now().UTC()
+	p.db.payments[id] = payment
+	return nil
+}
+
+// ClaimRefund leases the pending refund of a payment to the caller, reporting
+// false when nothing is pending or another caller holds an unexpired lease
+func (p *PaymentStore) ClaimRefund(_ context.Context, id primitive.ObjectID, now time.Time, lease time.Duration) (models.Payment, bool, error) {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment, ok := p.db.payments[id]
+	if !ok || payment.RefundPending <= 0 || (payment.RefundLease != nil && payment.RefundLease.After(now)) {
+		return models.Payment{}, false, nil
+	}
+	until := now.Add(lease)
+	payment.RefundClaim = primitive.NewObjectID().Hex()
+	payment.RefundLease = &until
+	payment.RefundAttempts++
+	payment.UpdatedAt = now
+	p.db.payments[id] = payment
+	return payment, true, nil
+}
+
+// AddRefund records a refunded amount on a payment if the caller still holds
+// claim, reporting false when the claim was lost
+func (p *PaymentStore) AddRefund(_ context.Context, id primitive.ObjectID, claim string, amount float64, fully bool) (bool, error) {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment, ok := p.db.payments[id]
+	if !ok || payment.RefundClaim != claim {
+		return false, nil
+	}
+	payment.RefundedAmount += amount
+	payment.RefundPending = 0
+	payment.RefundError = ""
+	payment.RefundClaim = ""
+	payment.RefundLease = nil
+	payment.RefundAttempts = 0
+	payment.UpdatedAt = time.Now().UTC()
+	if fully {
+		payment.Status = models.PaymentRefunded
+	}
+	p.db.payments[id] = payment
+	return true, nil
+}
+
+// ReleaseRefund gives up claim on the pending refund of a payment, recording
+// why the provider failed it
+func (p *PaymentStore) ReleaseRefund(_ context.Context, id primitive.ObjectID, claim, reason string) error {
+	p.db.mu.Lock()
+	defer p.db.mu.Unlock()
+	payment, ok := p.db.payments[id]
+	if !ok || payment.RefundClaim != claim {
+		return nil
+	}
+	payment.RefundError = reason
+	payment.RefundClaim = ""
+	payment.RefundLease = nil
+	payment.UpdatedAt = time.Now().UTC()
+	p.db.payments[id] = payment
+	return nil
+}
+
+// GetRefundPending returns the payments with a refund still pending
+func (p *PaymentStore) GetRefundPending(_ context.Context) ([]models.Payment, error) {
+	p.db.mu.RLock()
+	defer p.db.mu.RUnlock()
+	return sorted(p.db.payments, func(payment models.Payment) bool { return payment.RefundPending > 0 }), nil
+}
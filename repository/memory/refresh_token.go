@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RefreshTokenStore struct {
+	db *DB
+}
+
+func (r *RefreshTokenStore) Create(_ context.Context, token models.RefreshToken) (models.RefreshToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now().UTC()
+	r.db.refreshTokens[token.ID] = cloneRefreshToken(token)
+	return token, nil
+}
+
+func (r *RefreshTokenStore) GetByHash(_ context.Context, hash string) (models.RefreshToken, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	tokens := sorted(r.db.refreshTokens, func(token models.RefreshToken) bool { return token.TokenHash == hash })
+	if len(tokens) == 0 {
+		return models.RefreshToken{}, errors.ErrInvalidRefreshToken
+	}
+	return cloneRefreshToken(tokens[0]), nil
+}
+
+// Rotate marks the old token as used and stores its replacement, failing
+// with ErrRefreshTokenReused when the old token was already revoked
+func (r *RefreshTokenStore) Rotate(_ context.Context, oldID primitive.ObjectID, next models.RefreshToken) (models.RefreshToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	old, ok := r.db.refreshTokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return models.RefreshToken{}, errors.ErrRefreshTokenReused
+	}
+	next.ID = primitive.NewObjectID()
+	next.CreatedAt = time.Now().UTC()
+	revokedAt := next.CreatedAt
+	old.RevokedAt = &revokedAt
+	old.ReplacedBy = next.ID
+	r.db.refreshTokens[oldID] = old
+	r.db.refreshTokens[next.ID] = cloneRefreshToken(next)
+	return next, nil
+}
+
+func (r *RefreshTokenStore) RevokeFamily(_ context.Context, family string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	now := time.Now().UTC()
+	for id, token := range r.db.refreshTokens {
+		if token.Family == family && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+			r.db.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *RefreshTokenStore) RevokeUser(_ context.Context, email string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	now := time.Now().UTC()
+	for id, token := range r.db.refreshTokens {
+		if token.UserEmail == email && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+			r.db.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
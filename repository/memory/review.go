@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewStore struct {
+	db *DB
+}
+
+func (s *ReviewStore) CreateReviw(_ context.Context, id string, review models.Review) (models.Review, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Review{}, errors.ErrTourNotFound
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	tour, ok := s.db.tours[objId]
+	if !ok {
+		return models.Review{}, errors.ErrTourNotFound
+	}
+	review.ID = primitive.NewObjectID()
+	review.TourID = tour.ID
+	review.CreatedAt = time.Now().UTC()
+	review.UpdatedAt = time.Now().UTC()
+	tour = cloneTour(tour)
+	tour.Reviews = append(tour.Reviews, review.ID)
+	s.db.tours[objId] = tour
+	s.db.reviews[review.ID] = review
+	return review, nil
+}
+
+func (s *ReviewStore) GetAll(_ context.Context, page, limit int) ([]models.Review, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	all := sorted(s.db.reviews, nil)
+	reviews, err := paginate(all, page, limit)
+	if err != nil {
+		return []models.Review{}, 0, err
+	}
+	return reviews, len(all), nil
+}
+
+func (s *ReviewStore) CountReviews(_ context.Context) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return len(s.db.reviews), nil
+}
+
+func (s *ReviewStore) GetOne(_ context.Context, id string) (models.Review, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Review{}, errors.ErrReviewNotFound
+	}
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	review, ok := s.db.reviews[objId]
+	if !ok {
+		return models.Review{}, errors.ErrReviewNotFound
+	}
+	return review, nil
+}
+
+func (s *ReviewStore) Delete(_ context.Context, tourId, reviewId string) (bool, error) {
+	tourObjId, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		return false, errors.ErrTourNotFound
+	}
+	reviewObjId, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return false, errors.ErrReviewNotFound
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if tour, ok := s.db.tours[tourObjId]; ok {
+		reviews := []primitive.ObjectID{}
+		for _, id := range tour.Reviews {
+			if id != reviewObjId {
+				reviews = append(reviews, id)
+			}
+		}
+		tour.Reviews = reviews
+		s.db.tours[tourObjId] = tour
+	}
+	delete(s.db.reviews, reviewObjId)
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionStore struct {
+	db *DB
+}
+
+func (s *SessionStore) Create(_ context.Context, session models.Session) (models.Session, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now().UTC()
+	session.LastSeenAt = session.CreatedAt
+	session.LastSeenIP = session.IP
+	s.db.sessions[session.ID] = cloneSession(session)
+	return session, nil
+}
+
+func (s *SessionStore) Get(_ context.Context, id string) (models.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	session, ok := s.db.sessions[objectID(id)]
+	if !ok {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	return cloneSession(session), nil
+}
+
+func (s *SessionStore) GetActive(_ context.Context, email string, now time.Time) ([]models.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	sessions := sorted(s.db.sessions, func(session models.Session) bool {
+		return session.UserEmail == email && session.Active(now)
+	})
+	// most recently seen first, like the MongoDB store
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	for i := range sessions {
+		sessions[i] = cloneSession(sessions[i])
+	}
+	return sessions, nil
+}
+
+func (s *SessionStore) Touch(_ context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[id]
+	if !ok {
+		return nil
+	}
+	if session.LastSeenIP == ip && session.LastSeenAt.After(now.Add(-store.SessionTouchInterval)) {
+		return nil
+	}
+	session.LastSeenAt = now.UTC()
+	session.LastSeenIP = ip
+	s.db.sessions[id] = session
+	return nil
+}
+
+func (s *SessionStore) Extend(_ context.Context, id primitive.ObjectID, ip string, now, expiresAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[id]
+	if !ok || session.RevokedAt != nil {
+		return errors.ErrSessionRevoked
+	}
+	session.LastSeenAt = now.UTC()
+	session.LastSeenIP = ip
+	session.ExpiresAt = expiresAt.UTC()
+	s.db.sessions[id] = session
+	return nil
+}
+
+func (s *SessionStore) Revoke(_ context.Context, email, id string) (models.Session, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[objectID(id)]
+	if !ok || session.UserEmail != email {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	if session.RevokedAt == nil {
+		revokedAt := time.Now().UTC()
+		session.RevokedAt = &revokedAt
+		s.db.sessions[session.ID] = session
+	}
+	return cloneSession(session), nil
+}
+
+func (s *SessionStore) RevokeAll(_ context.Context, email, except string) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	now := time.Now().UTC()
+	revoked := 0
+	for id, session := range s.db.sessions {
+		if session.UserEmail != email || session.RevokedAt != nil || (except != "" && id.Hex() == except) {
+			continue
+		}
+		revokedAt := now
+		session.RevokedAt = &revokedAt
+		s.db.sessions[id] = session
+		revoked++
+	}
+	return revoked, nil
+}
+
+func cloneSession(s models.Session) models.Session {
+	if s.RevokedAt != nil {
+		at := *s.RevokedAt
+		s.RevokedAt = &at
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/models"
+)
+
+type SigningKeyStore struct {
+	db *DB
+}
+
+func (s *SigningKeyStore) Create(_ context.Context, key models.SigningKey) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.signingKeys[key.ID]; ok {
+		return keyring.ErrKeyExists
+	}
+	s.db.signingKeys[key.ID] = key
+	return nil
+}
+
+func (s *SigningKeyStore) GetValid(_ context.Context, now time.Time) ([]models.SigningKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	keys := []models.SigningKey{}
+	for _, key := range s.db.signingKeys {
+		if now.Before(key.VerifyUntil) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TourStore struct {
+	db *DB
+}
+
+func (t *TourStore) CreateTour(_ context.Context, tour models.Tour) (models.Tour, error) {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	if t.titleTaken(tour.Title, primitive.NilObjectID) {
+		return models.Tour{}, errors.ErrTourExists
+	}
+	tour.ID = primitive.NewObjectID()
+	tour.CreatedAt = time.Now().UTC()
+	tour.UpdatedAt = time.Now().UTC()
+	t.db.tours[tour.ID] = cloneTour(tour)
+	return tour, nil
+}
+
+func (t *TourStore) DeleteTour(_ context.Context, id string) (bool, error) {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	objId := objectID(id)
+	if _, ok := t.db.tours[objId]; !ok {
+		return false, errors.ErrTourNotFound
+	}
+	delete(t.db.tours, objId)
+	return true, nil
+}
+
+func (t *TourStore) Get(_ context.Context, id string) (models.Tour, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	tour, ok := t.db.tours[objectID(id)]
+	if !ok {
+		return models.Tour{}, errors.ErrTourNotFound
+	}
+	return cloneTour(tour), nil
+}
+
+func (t *TourStore) GetAll(_ context.Context, page, limit int) ([]models.Tour, int, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	all := sorted(t.db.tours, nil)
+	tours, err := paginate(all, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range tours {
+		tours[i] = cloneTour(tours[i])
+	}
+	return tours, len(all), nil
+}
+
+func (t *TourStore) Update(_ context.Context, id string, updated models.Tour) (models.Tour, error) {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	objId := objectID(id)
+	tour, ok := t.db.tours[objId]
+	if !ok {
+		return models.Tour{}, errors.ErrTourNotFound
+	}
+	if t.titleTaken(updated.Title, objId) {
+		return models.Tour{}, errors.ErrTourExists
+	}
+	tour.Title = updated.Title
+	tour.City = updated.City
+	tour.Address = updated.Address
+	tour.Distance = updated.Distance
+	tour.Featured = updated.Featured
+	tour.Photo = updated.Photo
+	tour.Description = updated.Description
+	tour.Price = updated.Price
+	tour.MaxGroupSize = updated.MaxGroupSize
+	tour.CancellationPolicy = updated.CancellationPolicy
+	tour.Location = updated.Location
+	tour.UpdatedAt = time.Now().UTC()
+	t.db.tours[objId] = cloneTour(tour)
+	return cloneTour(tour), nil
+}
+
+func (t *TourStore) SearchTour(_ context.Context, city string, distance float32, maxgroupsize int) ([]models.Tour, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	tours := sorted(t.db.tours, func(tour models.Tour) bool {
+		return tour.City == city && float32(tour.Distance) >= distance && tour.MaxGroupSize >= maxgroupsize
+	})
+	for i := range tours {
+		tours[i] = cloneTour(tours[i])
+	}
+	return tours, nil
+}
+
+func (t *TourStore) FeaturedTour(_ context.Context) ([]models.Tour, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	tours := sorted(t.db.tours, func(tour models.Tour) bool { return tour.Featured })
+	for i := range tours {
+		tours[i] = cloneTour(tours[i])
+	}
+	return tours, nil
+}
+
+func (t *TourStore) CountTours(_ context.Context) (int, error) {
+	t.db.mu.RLock()
+	defer t.db.mu.RUnlock()
+	return len(t.db.tours), nil
+}
+
+// titleTaken mirrors the unique title index of the MongoDB store
+func (t *TourStore) titleTaken(title string, except primitive.ObjectID) bool {
+	for id, tour := range t.db.tours {
+		if id != except && tour.Title == title {
+			return true
+		}
+	}
+	return false
+}
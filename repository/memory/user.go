@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserStore struct {
+	db *DB
+}
+
+func (u *UserStore) CreaterUser(_ context.Context, usr models.User) (models.User, error) {
+	hash, err := utils.EnscryptPassword(usr.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	// mirrors the unique email index of the MongoDB store
+	for _, existing := range u.db.users {
+		if existing.Email == usr.Email {
+			return models.User{}, errors.ErrUserExists
+		}
+	}
+	usr.ID = primitive.NewObjectID()
+	usr.CreatedAt = time.Now().UTC()
+	usr.UpdatedAt = time.Now().UTC()
+	usr.Password = hash
+	u.db.users[usr.ID] = usr
+	return usr, nil
+}
+
+func (u *UserStore) GetAll(_ context.Context, page, limit int) ([]models.User, int, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	all := sorted(u.db.users, nil)
+	users, err := paginate(all, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, len(all), nil
+}
+
+// byEmail returns the first user with email, the caller holds the lock
+func (u *UserStore) byEmail(email string) (models.User, bool) {
+	users := sorted(u.db.users, func(usr models.User) bool { return usr.Email == email })
+	if len(users) == 0 {
+		return models.User{}, false
+	}
+	return users[0], true
+}
+
+func (u *UserStore) Get(_ context.Context, email string) (models.User, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	usr, ok := u.byEmail(email)
+	if !ok {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	return usr, nil
+}
+
+func (u *UserStore) Delete(_ context.Context, email string) (bool, error) {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	if usr, ok := u.byEmail(email); ok {
+		delete(u.db.users, usr.ID)
+	}
+	return true, nil
+}
+
+func (u *UserStore) UpdateUser(_ context.Context, email string, update models.UserUpdate) (models.User, error) {
+	var hash string
+	if update.Password != "" {
+		var err error
+		if hash, err = utils.EnscryptPassword(update.Password); err != nil {
+			return models.User{}, err
+		}
+	}
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	usr, ok := u.byEmail(email)
+	if !ok {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	usr.FirstName = update.FirstName
+	usr.Lastname = update.Lastname
+	usr.Photo = update.Photo
+	if hash != "" {
+		usr.Password = hash
+	}
+	usr.UpdatedAt = time.Now().UTC()
+	u.db.users[usr.ID] = usr
+	return usr, nil
+}
+
+func (u *UserStore) UpdateRole(_ context.Context, email, role string) (models.User, error) {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	usr, ok := u.byEmail(email)
+	if !ok {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	usr.Role = role
+	usr.UpdatedAt = time.Now().UTC()
+	u.db.users[usr.ID] = usr
+	return usr, nil
+}
+
+func (u *UserStore) SetPassword(_ context.Context, email, password string) error {
+	hash, err := utils.EnscryptPassword(password)
+	if err != nil {
+		return err
+	}
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	usr, ok := u.byEmail(email)
+	if !ok {
+		return errors.ErrUserNotFound
+	}
+	usr.Password = hash
+	usr.UpdatedAt = time.Now().UTC()
+	u.db.users[usr.ID] = usr
+	return nil
+}
+
+func (u *UserStore) MarkVerified(_ context.Context, email string) (models.User, error) {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+	usr, ok := u.byEmail(email)
+	if !ok {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	usr.Unverified = false
+	usr.UpdatedAt = time.Now().UTC()
+	u.db.users[usr.ID] = usr
+	return usr, nil
+}
+
+func (u *UserStore) CountUser(_ context.Context) (int, error) {
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	return len(u.db.users), nil
+}
+
+func (u *UserStore) GetByID(_ context.Context, id string) (models.User, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	u.db.mu.RLock()
+	defer u.db.mu.RUnlock()
+	usr, ok := u.db.users[objId]
+	if !ok {
+		return models.User{}, errors.ErrUserNotFound
+	}
+	return usr, nil
+}
+
+func (u *UserStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return u.Get(ctx, email)
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserTokenStore struct {
+	db *DB
+}
+
+func (s *UserTokenStore) Create(_ context.Context, token models.UserToken) (models.UserToken, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now().UTC()
+	for id, t := range s.db.userTokens {
+		if t.Email == token.Email && t.Purpose == token.Purpose && t.UsedAt == nil {
+			usedAt := token.CreatedAt
+			t.UsedAt = &usedAt
+			s.db.userTokens[id] = t
+		}
+	}
+	s.db.userTokens[token.ID] = token
+	return token, nil
+}
+
+func (s *UserTokenStore) Consume(_ context.Context, purpose, hash string, now time.Time) (models.UserToken, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for id, t := range s.db.userTokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && now.Before(t.ExpiresAt) {
+			usedAt := now
+			t.UsedAt = &usedAt
+			s.db.userTokens[id] = t
+			return t, nil
+		}
+	}
+	return models.UserToken{}, errors.ErrInvalidAccountToken
+}
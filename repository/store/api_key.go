@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyTouchInterval is how stale the recorded last use of an API key may
+// get
+const APIKeyTouchInterval = time.Minute
+
+type APIKeyStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewAPIKeyStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *APIKeyStore {
+	return &APIKeyStore{coll: c, timeout: timeout, logger: l.With("store", "api_key")}
+}
+
+// Create a new API key Document
+func (s *APIKeyStore) Create(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	s.logger.DebugContext(ctx, "Create")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = time.Now().UTC()
+	_, err := s.coll.InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.APIKey{}, errors.ErrDuplicate
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.APIKey{}, err
+	}
+	s.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("APIKey %v created for %v", key.Prefix, key.OwnerEmail))
+
+	return key, nil
+}
+
+// Get API key Document by its id
+func (s *APIKeyStore) Get(ctx context.Context, id string) (models.APIKey, error) {
+	s.logger.DebugContext(ctx, "Get")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	objId, _ := primitive.ObjectIDFromHex(id)
+	var key models.APIKey
+	err := s.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return models.APIKey{}, errors.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// GetByHash returns the API key whose hash is hash, revoked and expired
+// keys included
+func (s *APIKeyStore) GetByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	s.logger.DebugContext(ctx, "GetByHash")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var key models.APIKey
+	err := s.coll.FindOne(ctx, bson.M{"key_hash": hash}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return models.APIKey{}, errors.ErrInvalidAPIKey
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetByHash", "error", err)
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// GetAll lists the API keys, newest first
+func (s *APIKeyStore) GetAll(ctx context.Context, page, limit int) ([]models.APIKey, int, error) {
+	s.logger.DebugContext(ctx, "GetAll")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	skip := (page - 1) * limit
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(skip))
+	keys := []models.APIKey{}
+
+	cur, err := s.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetAll", "error", err)
+		return []models.APIKey{}, 0, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &keys); err != nil {
+		s.logger.ErrorContext(ctx, "GetAll", "error", err)
+		return []models.APIKey{}, 0, err
+	}
+	count, err := s.coll.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetAll", "error", err)
+		return []models.APIKey{}, 0, err
+	}
+	return keys, int(count), nil
+}
+
+// Revoke revokes the API key id, revoking a revoked key keeps its first
+// revocation
+func (s *APIKeyStore) Revoke(ctx context.Context, id string) (models.APIKey, error) {
+	s.logger.DebugContext(ctx, "Revoke")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	objId, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objId, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}}
+	if _, err := s.coll.UpdateOne(ctx, filter, update); err != nil {
+		s.logger.ErrorContext(ctx, "Revoke", "error", err)
+		return models.APIKey{}, err
+	}
+	var key models.APIKey
+	err := s.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return models.APIKey{}, errors.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Revoke", "error", err)
+		return models.APIKey{}, err
+	}
+	s.logger.InfoContext(ctx, "Revoke", "detail", fmt.Sprintf("APIKey %v revoked", key.Prefix))
+
+	return key, nil
+}
+
+// Touch records that the API key id was used from ip at now. A key used
+// again from the same address within APIKeyTouchInterval is not written, so
+// busy keys do not write on every request.
+func (s *APIKeyStore) Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	s.logger.DebugContext(ctx, "Touch")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"lastUsedAt": bson.M{"$exists": false}},
+		bson.M{"lastUsedAt": bson.M{"$lte": now.Add(-APIKeyTouchInterval)}},
+		bson.M{"lastUsedIP": bson.M{"$ne": ip}},
+	}}
+	update := bson.M{"$set": bson.M{"lastUsedAt": now.UTC(), "lastUsedIP": ip}}
+	if _, err := s.coll.UpdateOne(ctx, filter, update); err != nil {
+		s.logger.ErrorContext(ctx, "Touch", "error", err)
+		return err
+	}
+	return nil
+}
@@ -14,146 +14,389 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// DefaultHoldWindow is how long new bookings keep their seats before payment
+const DefaultHoldWindow = 15 * time.Minute
+
 type BookingStore struct {
-	b      mongo.Collection
-	u      mongo.Collection
-	logger *slog.Logger
+	b       mongo.Collection
+	u       mongo.Collection
+	d       mongo.Collection
+	t       mongo.Collection
+	holdFor time.Duration
+	timeout time.Duration
+	logger  *slog.Logger
 }
 
-func NewBookStore(c mongo.Collection, l *slog.Logger) *BookingStore {
-	return &BookingStore{b: c, logger: l}
+func NewBookStore(c mongo.Collection, u mongo.Collection, d mongo.Collection, t mongo.Collection, holdFor, timeout time.Duration, l *slog.Logger) *BookingStore {
+	if holdFor <= 0 {
+		holdFor = DefaultHoldWindow
+	}
+	return &BookingStore{b: c, u: u, d: d, t: t, holdFor: holdFor, timeout: timeout, logger: l.With("store", "booking")}
 }
 
 // GetBooking By ID
-func (b *BookingStore) GetBooking(id string) (models.Booking, error) {
-	b.logger.Info("repository", "Get", "Booking")
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+func (b *BookingStore) GetBooking(ctx context.Context, id string) (models.Booking, error) {
+	b.logger.DebugContext(ctx, "Get")
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
 	defer cancle()
 	objId, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objId}
 	ok, err := b.IsExist(ctx, filter)
 	if err != nil {
-		b.logger.Error("repository", "IsExist", err.Error())
+		b.logger.ErrorContext(ctx, "IsExist", "error", err)
 		return models.Booking{}, err
 	}
 	if !ok {
-		b.logger.Error("repository", "Get", "Booking not found")
-		return models.Booking{}, errors.ErrUserNotFound
+		b.logger.ErrorContext(ctx, "Get", "detail", "Booking not found")
+		return models.Booking{}, errors.ErrBookingNotFound
 	}
 
 	var booking models.Booking
-	if err := b.b.FindOne(ctx, objId).Decode(&booking); err != nil {
-		b.logger.Error("repository", "Get", err.Error())
+	if err := b.b.FindOne(ctx, filter).Decode(&booking); err != nil {
+		b.logger.ErrorContext(ctx, "Get", "error", err)
 		return models.Booking{}, err
 	}
-	b.logger.Info("repository", "Get", fmt.Sprintf("Booking found: %v", booking))
+	b.logger.InfoContext(ctx, "Get", "detail", fmt.Sprintf("Booking found: %v", booking))
 
 	return booking, nil
 }
 
-// CreateBooking Create a new booking
-func (b *BookingStore) CreateBooking(userId string, book models.Booking) (models.Booking, error) {
-	b.logger.Info("repository", "Create", "Booking")
+// CreateBooking Create a new booking. Creating a booking starts the checkout:
+// the guests seats are held on the booked departure until the hold expires
+// or the booking is paid, and given back if the booking can not be stored
+func (b *BookingStore) CreateBooking(ctx context.Context, userId string, book models.Booking) (models.Booking, error) {
+	b.logger.DebugContext(ctx, "Create")
 
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
 	defer cancle()
 	objId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		b.logger.Error("repository", "CreateReviw", err.Error())
-		return models.Booking{}, err
+		b.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Booking{}, errors.ErrUserNotFound
 	}
 	usr := models.User{}
 	if err := b.u.FindOne(ctx, bson.M{"_id": objId}).Decode(&usr); err != nil {
-		b.logger.Error("repository", "Create", err.Error())
+		if err == mongo.ErrNoDocuments {
+			return models.Booking{}, errors.ErrUserNotFound
+		}
+		b.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Booking{}, err
+	}
+	if book.DepartureID.IsZero() || book.GuestSize <= 0 {
+		b.logger.WarnContext(ctx, "Create", "detail", "Booking without departure or guests")
+		return models.Booking{}, errors.ErrBadRequest
+	}
+	var dep models.Departure
+	if err := b.d.FindOne(ctx, bson.M{"_id": book.DepartureID}).Decode(&dep); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Booking{}, errors.ErrDepartureNotFound
+		}
+		b.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Booking{}, err
+	}
+	var tour models.Tour
+	if err := b.t.FindOne(ctx, bson.M{"_id": dep.TourID}).Decode(&tour); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Booking{}, errors.ErrTourNotFound
+		}
+		b.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Booking{}, err
+	}
+	if !tour.AllowsGroup(book.GuestSize) {
+		return models.Booking{}, errors.ErrGroupTooLarge
+	}
+	if err := reserveSeats(ctx, &b.d, dep.ID, seatsHeld, book.GuestSize); err != nil {
+		b.logger.WarnContext(ctx, "Create", "detail", fmt.Sprintf("could not reserve %d seats on %v: %v", book.GuestSize, dep.ID.Hex(), err))
 		return models.Booking{}, err
 	}
 	book.ID = primitive.NewObjectID()
+	book.TourID = dep.TourID
+	book.TourName = tour.Title
 	book.UserID = usr.ID
 	book.UserEmail = usr.Email
+	book.UnitPrice = tour.Price
+	if dep.PriceOverride != nil {
+		book.UnitPrice = *dep.PriceOverride
+	}
+	book.TotalPrice = book.UnitPrice * float64(book.GuestSize)
+	book.RefundAmount = 0
+	book.Status = models.BookingHeld
 	book.CreatedAt = time.Now().UTC()
 	book.UpdatedAt = time.Now().UTC()
+	expires := book.CreatedAt.Add(b.holdFor)
+	book.HoldExpiresAt = &expires
+	book.History = []models.BookingEvent{{To: models.BookingHeld, Actor: usr.Email, At: book.CreatedAt}}
 	if _, err := b.b.InsertOne(ctx, &book); err != nil {
-		b.logger.Error("repository", "Create", err.Error())
+		b.logger.ErrorContext(ctx, "Create", "error", err)
+		if err := releaseSeats(ctx, &b.d, dep.ID, seatsHeld, book.GuestSize); err != nil {
+			b.logger.ErrorContext(ctx, "Create", "error", err)
+		}
 
 		return models.Booking{}, err
 	}
-	b.logger.Info("repository", "Create", fmt.Sprintf("Booking created: %v", book))
+	b.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("Booking created: %v", book))
 	return book, nil
 }
 
-// Delete Booking Document
-func (b *BookingStore) DeleteBook(id string) (bool, error) {
-	b.logger.Info("repository", "Delete", "Booking")
+// Transition moves a booking to a new status and records the change in its
+// history. The update only applies if the status did not change in between.
+// Cancelling gives the seats back to the departure and computes the refund
+// from the tour cancellation policy.
+func (b *BookingStore) Transition(ctx context.Context, id string, to models.BookingStatus, actor, note string) (models.Booking, error) {
+	b.logger.DebugContext(ctx, "Transition")
 
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
 	defer cancle()
 	objId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.M{"_id": objId}
-	ok, err := b.IsExist(ctx, filter)
+	var current models.Booking
+	if err := b.b.FindOne(ctx, bson.M{"_id": objId}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			b.logger.WarnContext(ctx, "Transition", "detail", fmt.Sprintf("Booking not found: %v", id))
+			return models.Booking{}, errors.ErrBookingNotFound
+		}
+		b.logger.ErrorContext(ctx, "Transition", "error", err)
+		return models.Booking{}, err
+	}
+	if err := b.transition(ctx, current, to, actor, note); err != nil {
+		return models.Booking{}, err
+	}
+
+	return b.GetBooking(ctx, id)
+}
+
+// transition applies a status change to a loaded booking and moves its seats
+// between the departure counters
+func (b *BookingStore) transition(ctx context.Context, current models.Booking, to models.BookingStatus, actor, note string) error {
+	id := current.ID.Hex()
+	if !current.Status.CanTransitionTo(to) {
+		b.logger.WarnContext(ctx, "Transition", "detail", fmt.Sprintf("Booking %v can not go from %v to %v", id, current.Status, to))
+		return errors.ErrInvalidTransition
+	}
+
+	now := time.Now().UTC()
+	if current.Status == models.BookingHeld && to == models.BookingConfirmed &&
+		current.HoldExpiresAt != nil && current.HoldExpiresAt.Before(now) {
+		b.logger.WarnContext(ctx, "Transition", "detail", fmt.Sprintf("Booking %v hold expired", id))
+		return errors.ErrHoldExpired
+	}
+	set := bson.M{"status": to, "updatedAt": now}
+	if to == models.BookingCancelled && current.Status == models.BookingConfirmed {
+		refund, err := b.refundFor(ctx, current, now)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Transition", "error", err)
+			return err
+		}
+		set["refund_amount"] = refund
+	}
+	event := models.BookingEvent{From: current.Status, To: to, Actor: actor, Note: note, At: now}
+	update := bson.M{"$set": set, "$push": bson.M{"history": event}}
+	if to != models.BookingHeld {
+		update["$unset"] = bson.M{"hold_expires_at": ""}
+	}
+	res, err := b.b.UpdateOne(ctx, bson.M{"_id": current.ID, "status": current.Status}, update)
 	if err != nil {
-		b.logger.Error("repository", "IsExist", err.Error())
-		return false, err
+		b.logger.ErrorContext(ctx, "Transition", "error", err)
+		return err
 	}
-	if !ok {
-		b.logger.Warn("repository", "IsExist", "not found")
+	if res.MatchedCount == 0 {
+		b.logger.WarnContext(ctx, "Transition", "detail", fmt.Sprintf("Booking %v changed concurrently", id))
+		return errors.ErrInvalidTransition
+	}
+	if !current.DepartureID.IsZero() {
+		var err error
+		switch {
+		case to == models.BookingCancelled || to == models.BookingExpired:
+			err = releaseSeats(ctx, &b.d, current.DepartureID, seatField(current.Status), current.GuestSize)
+		case current.Status == models.BookingHeld && to == models.BookingConfirmed:
+			err = sellHeldSeats(ctx, &b.d, current.DepartureID, current.GuestSize)
+		}
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Transition", "error", err)
+			b.revertTransition(ctx, current, to, now)
+			return err
+		}
+	}
+	b.logger.InfoContext(ctx, "Transition", "detail", fmt.Sprintf("Booking %v moved from %v to %v by %v", id, current.Status, to, actor))
+
+	return nil
+}
 
-		return false, errors.ErrUserNotFound
+// revertTransition puts a booking moved to status to at now back the way it
+// was, when its seats could not follow it. The filter only matches while the
+// booking is still as the transition left it.
+func (b *BookingStore) revertTransition(ctx context.Context, current models.Booking, to models.BookingStatus, now time.Time) {
+	set := bson.M{"status": current.Status, "updatedAt": current.UpdatedAt}
+	unset := bson.M{}
+	if current.HoldExpiresAt != nil {
+		set["hold_expires_at"] = *current.HoldExpiresAt
 	}
+	if current.RefundAmount != 0 {
+		set["refund_amount"] = current.RefundAmount
+	} else {
+		unset["refund_amount"] = ""
+	}
+	update := bson.M{"$set": set, "$pop": bson.M{"history": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	// MongoDB keeps times to the millisecond
+	filter := bson.M{"_id": current.ID, "status": to, "updatedAt": now.Truncate(time.Millisecond)}
+	res, err := b.b.UpdateOne(ctx, filter, update)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Transition", "error", err)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		b.logger.ErrorContext(ctx, "Transition", "detail", fmt.Sprintf("Booking %v could not be moved back to %v", current.ID.Hex(), current.Status))
+	}
+}
 
-	if _, err := b.b.DeleteOne(ctx, bson.M{"_id": objId}); err != nil {
-		b.logger.Error("repository", "Delete", err.Error())
-		return false, err
+// ExpireHolds moves every booking whose hold expired before now to the
+// expired status, giving its seats back to the departure
+func (b *BookingStore) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	b.logger.DebugContext(ctx, "ExpireHolds")
+
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
+	defer cancle()
+	filter := bson.M{"status": models.BookingHeld, "hold_expires_at": bson.M{"$lte": now}}
+	cur, err := b.b.Find(ctx, filter)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "ExpireHolds", "error", err)
+		return 0, err
 	}
-	b.logger.Info("repository", "Delete", fmt.Sprintf("Booking deleted: %v", objId))
-	return true, nil
+	defer cur.Close(ctx)
+	expired := 0
+	for cur.Next(ctx) {
+		var booking models.Booking
+		if err := cur.Decode(&booking); err != nil {
+			b.logger.ErrorContext(ctx, "ExpireHolds", "error", err)
+			continue
+		}
+		if err := b.transition(ctx, booking, models.BookingExpired, "system", "hold expired"); err != nil {
+			// a payment confirmed the booking meanwhile
+			if errors.Is(err, errors.ErrInvalidTransition) {
+				continue
+			}
+			return expired, err
+		}
+		expired++
+	}
+	if err := cur.Err(); err != nil {
+		b.logger.ErrorContext(ctx, "ExpireHolds", "error", err)
+		return expired, err
+	}
+	if expired > 0 {
+		b.logger.InfoContext(ctx, "ExpireHolds", "detail", fmt.Sprintf("%d holds expired", expired))
+	}
+
+	return expired, nil
 }
 
-func (b *BookingStore) Update(id string, bookUpdate models.UpdateBooking) (models.Booking, error) {
-	b.logger.Info("repository", "Update", "Booking")
+// refundFor returns the amount refunded when the booking is cancelled at the given time
+func (b *BookingStore) refundFor(ctx context.Context, booking models.Booking, at time.Time) (float64, error) {
+	var dep models.Departure
+	if err := b.d.FindOne(ctx, bson.M{"_id": booking.DepartureID}).Decode(&dep); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var tour models.Tour
+	if err := b.t.FindOne(ctx, bson.M{"_id": dep.TourID}).Decode(&tour); err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	daysBefore := int(dep.StartDate.Sub(at).Hours() / 24)
+	return booking.TotalPrice * tour.RefundPercent(daysBefore) / 100, nil
+}
 
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+func (b *BookingStore) Update(ctx context.Context, id string, bookUpdate models.UpdateBooking) (models.Booking, error) {
+	b.logger.DebugContext(ctx, "Update")
+
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
 	defer cancle()
 	objId, _ := primitive.ObjectIDFromHex(id)
-	filter := bson.M{"_id": objId}
-	ok, err := b.IsExist(ctx, filter)
-	if err != nil {
-		b.logger.Error("repository", "Update", err.Error())
+	var current models.Booking
+	if err := b.b.FindOne(ctx, bson.M{"_id": objId}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			b.logger.WarnContext(ctx, "Update", "detail", fmt.Sprintf("Booking not found: %v", id))
+			return models.Booking{}, errors.ErrBookingNotFound
+		}
+		b.logger.ErrorContext(ctx, "Update", "error", err)
 
 		return models.Booking{}, err
 	}
-	if !ok {
-		b.logger.Warn("repository", "Update", fmt.Sprintf("Booking not found: %v", id))
-
-		return models.Booking{}, errors.ErrUserNotFound
+	if bookUpdate.GuestSize <= 0 {
+		return models.Booking{}, errors.ErrBadRequest
+	}
+	if !current.Status.HoldsSeats() {
+		b.logger.WarnContext(ctx, "Update", "detail", fmt.Sprintf("Booking %v is %v", id, current.Status))
+		return models.Booking{}, errors.ErrInvalidTransition
+	}
+	var tour models.Tour
+	if err := b.t.FindOne(ctx, bson.M{"_id": current.TourID}).Decode(&tour); err != nil && err != mongo.ErrNoDocuments {
+		b.logger.ErrorContext(ctx, "Update", "error", err)
+		return models.Booking{}, err
+	}
+	if !tour.AllowsGroup(bookUpdate.GuestSize) {
+		return models.Booking{}, errors.ErrGroupTooLarge
+	}
+	delta := bookUpdate.GuestSize - current.GuestSize
+	if err := b.adjustSeats(ctx, current, delta); err != nil {
+		b.logger.WarnContext(ctx, "Update", "detail", fmt.Sprintf("could not change guests of %v: %v", id, err))
+		return models.Booking{}, err
 	}
 
 	update := bson.M{
-		"tour_name":  bookUpdate.TourName,
-		"guest_size": bookUpdate.GuestSize,
-		"phone":      bookUpdate.Phone,
-		"updatedAt":  time.Now().UTC(),
+		"guest_size":  bookUpdate.GuestSize,
+		"total_price": current.UnitPrice * float64(bookUpdate.GuestSize),
+		"phone":       bookUpdate.Phone,
+		"updatedAt":   time.Now().UTC(),
+	}
+	// the seats were adjusted for the booking as it was read, a concurrent
+	// transition or guest change in between gets them back
+	filter := bson.M{"_id": objId, "status": current.Status, "guest_size": current.GuestSize}
+	res, err := b.b.UpdateOne(ctx, filter, bson.M{"$set": update})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Update", "error", err)
+	} else if res.MatchedCount == 0 {
+		b.logger.WarnContext(ctx, "Update", "detail", fmt.Sprintf("Booking %v changed concurrently", id))
+		err = errors.ErrInvalidTransition
 	}
-	_, err = b.b.UpdateOne(ctx, bson.M{"_id": objId}, bson.M{"$set": update})
 	if err != nil {
-		b.logger.Error("repository", "Update", err.Error())
+		if err := b.adjustSeats(ctx, current, -delta); err != nil {
+			b.logger.ErrorContext(ctx, "Update", "error", err)
+		}
 
 		return models.Booking{}, err
 	}
 	var booking models.Booking
 	if err = b.b.FindOne(ctx, bson.M{"_id": objId}).Decode(&booking); err != nil {
-		b.logger.Error("repository", "Update", err.Error())
+		b.logger.ErrorContext(ctx, "Update", "error", err)
 
 		return models.Booking{}, err
 	}
 
-	b.logger.Info("repository", "Update", fmt.Sprintf("Booking update: %v", booking))
+	b.logger.InfoContext(ctx, "Update", "detail", fmt.Sprintf("Booking update: %v", booking))
 
 	return booking, nil
 }
 
+// adjustSeats reserves (delta > 0) or releases (delta < 0) seats of a booking on its departure
+func (b *BookingStore) adjustSeats(ctx context.Context, booking models.Booking, delta int64) error {
+	field := seatField(booking.Status)
+	switch {
+	case booking.DepartureID.IsZero() || delta == 0:
+		return nil
+	case delta > 0:
+		return reserveSeats(ctx, &b.d, booking.DepartureID, field, delta)
+	default:
+		return releaseSeats(ctx, &b.d, booking.DepartureID, field, -delta)
+	}
+}
+
 // Get All Bookings Documemt0s
-func (b *BookingStore) GetAll(page, limit int) ([]models.Booking, int, error) {
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+func (b *BookingStore) GetAll(ctx context.Context, page, limit int) ([]models.Booking, int, error) {
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
 	defer cancle()
 	skip := (page - 1) * limit
 
@@ -162,38 +405,71 @@ func (b *BookingStore) GetAll(page, limit int) ([]models.Booking, int, error) {
 
 	cur, err := b.b.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		b.logger.Error("repository", "GetAll", err.Error())
+		b.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return []models.Booking{}, 0, err
 	}
 	for cur.Next(ctx) {
 		var booking models.Booking
 		if err := cur.Decode(&booking); err != nil {
-			b.logger.Error("repository", "GetAll", err.Error())
+			b.logger.ErrorContext(ctx, "GetAll", "error", err)
 			return []models.Booking{}, 0, err
 		}
 		bookings = append(bookings, booking)
 	}
 	if err := cur.Err(); err != nil {
-		b.logger.Error("repository", "GetAll", err.Error())
+		b.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return []models.Booking{}, 0, err
 	}
 	count, err := b.b.CountDocuments(ctx, bson.M{})
 	if err != nil {
-		b.logger.Error("repository", "GetAll", err.Error())
+		b.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return []models.Booking{}, 0, err
 	}
 	return bookings, int(count), err
 }
 
+// Get the Bookings Documents of one user
+func (b *BookingStore) GetByEmail(ctx context.Context, email string, page, limit int) ([]models.Booking, int, error) {
+	b.logger.DebugContext(ctx, "GetByEmail")
+
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
+	defer cancle()
+	skip := (page - 1) * limit
+
+	filter := bson.M{"email": email}
+	opts := options.Find().SetLimit(int64(limit)).SetSkip(int64(skip))
+	bookings := []models.Booking{}
+
+	cur, err := b.b.Find(ctx, filter, opts)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.Booking{}, 0, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &bookings); err != nil {
+		b.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.Booking{}, 0, err
+	}
+	count, err := b.b.CountDocuments(ctx, filter)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.Booking{}, 0, err
+	}
+	return bookings, int(count), nil
+}
+
 // Count Documents
-func (b *BookingStore) Count() (int, error) {
+func (b *BookingStore) Count(ctx context.Context) (int, error) {
+	b.logger.DebugContext(ctx, "Count")
+
+	ctx, cancle := context.WithTimeout(ctx, b.timeout)
+	defer cancle()
 	opts := options.Count().SetHint("_id_")
-	count, err := b.b.CountDocuments(context.Background(), opts)
+	count, err := b.b.CountDocuments(ctx, bson.D{}, opts)
 	if err != nil {
-		b.logger.Error("respsitory", "count", err.Error())
+		b.logger.ErrorContext(ctx, "Count", "error", err)
 		return 0, err
 	}
-	b.logger.Info("respsitory", "count", fmt.Sprintf("%v", count))
 	return int(count), nil
 }
 
@@ -201,15 +477,15 @@ func (b *BookingStore) Count() (int, error) {
 func (b *BookingStore) IsExist(ctx context.Context, filter primitive.M) (bool, error) {
 	count, err := b.b.CountDocuments(ctx, filter)
 	if err != nil {
-		b.logger.Error("repository", "ISExist", err.Error())
+		b.logger.ErrorContext(ctx, "ISExist", "error", err)
 		return false, err
 	}
 
 	if count > 0 {
-		b.logger.Info("repository", "ISExist", "Found")
+		b.logger.DebugContext(ctx, "ISExist")
 		return true, nil
 	}
-	b.logger.Warn("repository", "ISExits", "Not Found")
+	b.logger.WarnContext(ctx, "ISExits", "detail", "Not Found")
 	return false, nil
 
 }
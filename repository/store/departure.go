@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DepartureStore struct {
+	coll    mongo.Collection
+	tour    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewDepartureStore(d mongo.Collection, t mongo.Collection, timeout time.Duration, l *slog.Logger) *DepartureStore {
+	return &DepartureStore{coll: d, tour: t, timeout: timeout, logger: l.With("store", "departure")}
+}
+
+// Create a new departure Document for a tour
+func (d *DepartureStore) Create(ctx context.Context, tourId string, dep models.Departure) (models.Departure, error) {
+	d.logger.DebugContext(ctx, "Create")
+
+	ctx, cancle := context.WithTimeout(ctx, d.timeout)
+	defer cancle()
+	tourObjId, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Departure{}, errors.ErrTourNotFound
+	}
+	count, err := d.tour.CountDocuments(ctx, bson.M{"_id": tourObjId})
+	if err != nil {
+		d.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Departure{}, err
+	}
+	if count == 0 {
+		d.logger.WarnContext(ctx, "Create", "detail", fmt.Sprintf("Tour not found: %v", tourId))
+		return models.Departure{}, errors.ErrTourNotFound
+	}
+
+	dep.ID = primitive.NewObjectID()
+	dep.TourID = tourObjId
+	dep.SeatsHeld = 0
+	dep.SeatsSold = 0
+	dep.CreatedAt = time.Now().UTC()
+	dep.UpdatedAt = time.Now().UTC()
+	if _, err := d.coll.InsertOne(ctx, dep); err != nil {
+		d.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Departure{}, err
+	}
+	d.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("Departure created: %v", dep.ID.Hex()))
+
+	return dep, nil
+}
+
+// Get Departure Document by ID
+func (d *DepartureStore) Get(ctx context.Context, id string) (models.Departure, error) {
+	d.logger.DebugContext(ctx, "Get")
+
+	ctx, cancle := context.WithTimeout(ctx, d.timeout)
+	defer cancle()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+	var dep models.Departure
+	if err := d.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&dep); err != nil {
+		if err == mongo.ErrNoDocuments {
+			d.logger.WarnContext(ctx, "Get", "detail", fmt.Sprintf("Departure not found: %v", id))
+			return models.Departure{}, errors.ErrDepartureNotFound
+		}
+		d.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.Departure{}, err
+	}
+
+	return dep, nil
+}
+
+// Get the Departure Documents of a tour ordered by start date
+func (d *DepartureStore) GetByTour(ctx context.Context, tourId string) ([]models.Departure, error) {
+	d.logger.DebugContext(ctx, "GetByTour")
+
+	ctx, cancle := context.WithTimeout(ctx, d.timeout)
+	defer cancle()
+	tourObjId, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		return []models.Departure{}, errors.ErrTourNotFound
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start_date", Value: 1}})
+	cur, err := d.coll.Find(ctx, bson.M{"tour_id": tourObjId}, opts)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "GetByTour", "error", err)
+		return []models.Departure{}, err
+	}
+	defer cur.Close(ctx)
+	departures := []models.Departure{}
+	if err := cur.All(ctx, &departures); err != nil {
+		d.logger.ErrorContext(ctx, "GetByTour", "error", err)
+		return []models.Departure{}, err
+	}
+
+	return departures, nil
+}
+
+// Update the Departure Document, the capacity can not drop below the seats already held or sold
+func (d *DepartureStore) Update(ctx context.Context, id string, upd models.UpdateDeparture) (models.Departure, error) {
+	d.logger.DebugContext(ctx, "Update")
+
+	ctx, cancle := context.WithTimeout(ctx, d.timeout)
+	defer cancle()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Departure{}, errors.ErrDepartureNotFound
+	}
+
+	filter := bson.M{
+		"_id":   objId,
+		"$expr": bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$seats_held", "$seats_sold"}}, upd.Capacity}},
+	}
+	update := bson.M{
+		"start_date": upd.StartDate,
+		"end_date":   upd.EndDate,
+		"capacity":   upd.Capacity,
+		"updatedAt":  time.Now().UTC(),
+	}
+	ops := bson.M{"$set": update}
+	// MongoDB before 5.0 rejects empty update operators, $unset is only sent
+	// when the price override is cleared
+	if upd.PriceOverride != nil {
+		update["price_override"] = *upd.PriceOverride
+	} else {
+		ops["$unset"] = bson.M{"price_override": ""}
+	}
+	res, err := d.coll.UpdateOne(ctx, filter, ops)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "Update", "error", err)
+		return models.Departure{}, err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := d.Get(ctx, id); err != nil {
+			return models.Departure{}, err
+		}
+		d.logger.WarnContext(ctx, "Update", "detail", fmt.Sprintf("Departure %v capacity below seats taken", id))
+		return models.Departure{}, errors.ErrDepartureHasSeats
+	}
+
+	return d.Get(ctx, id)
+}
+
+// Delete the Departure Document if no seat has been held or sold
+func (d *DepartureStore) Delete(ctx context.Context, id string) (bool, error) {
+	d.logger.DebugContext(ctx, "Delete")
+
+	ctx, cancle := context.WithTimeout(ctx, d.timeout)
+	defer cancle()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.ErrDepartureNotFound
+	}
+	res, err := d.coll.DeleteOne(ctx, bson.M{"_id": objId, "seats_held": 0, "seats_sold": 0})
+	if err != nil {
+		d.logger.ErrorContext(ctx, "Delete", "error", err)
+		return false, err
+	}
+	if res.DeletedCount == 0 {
+		if _, err := d.Get(ctx, id); err != nil {
+			return false, err
+		}
+		return false, errors.ErrDepartureHasSeats
+	}
+	d.logger.InfoContext(ctx, "Delete", "detail", fmt.Sprintf("Departure deleted: %v", id))
+
+	return true, nil
+}
+
+// Seat counters of a departure
+const (
+	seatsHeld = "seats_held"
+	seatsSold = "seats_sold"
+)
+
+// seatField returns the departure counter holding the seats of a booking in status
+func seatField(status models.BookingStatus) string {
+	if status == models.BookingHeld {
+		return seatsHeld
+	}
+	return seatsSold
+}
+
+// reserveSeats atomically takes n seats of a departure into the field
+// counter. The conditional filter only matches while enough seats are left,
+// so concurrent bookings can never oversell the departure.
+func reserveSeats(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, field string, n int64) error {
+	filter := bson.M{
+		"_id":   id,
+		"$expr": bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$seats_held", "$seats_sold", n}}, "$capacity"}},
+	}
+	update := bson.M{"$inc": bson.M{field: n}, "$set": bson.M{"updatedAt": time.Now().UTC()}}
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		count, err := coll.CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.ErrDepartureNotFound
+		}
+		return errors.ErrNoSeatsAvailable
+	}
+	return nil
+}
+
+// releaseSeats gives n seats of the field counter back to a departure
+func releaseSeats(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, field string, n int64) error {
+	filter := bson.M{"_id": id, field: bson.M{"$gte": n}}
+	update := bson.M{"$inc": bson.M{field: -n}, "$set": bson.M{"updatedAt": time.Now().UTC()}}
+	_, err := coll.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// sellHeldSeats turns n held seats of a departure into sold seats
+func sellHeldSeats(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, n int64) error {
+	filter := bson.M{"_id": id, seatsHeld: bson.M{"$gte": n}}
+	update := bson.M{"$inc": bson.M{seatsHeld: -n, seatsSold: n}, "$set": bson.M{"updatedAt": time.Now().UTC()}}
+	_, err := coll.UpdateOne(ctx, filter, update)
+	return err
+}
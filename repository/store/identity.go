@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IdentityStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewIdentityStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *IdentityStore {
+	return &IdentityStore{coll: c, timeout: timeout, logger: l.With("store", "identity")}
+}
+
+// Get returns the identity subject of provider
+func (s *IdentityStore) Get(ctx context.Context, provider, subject string) (models.Identity, error) {
+	s.logger.DebugContext(ctx, "Get")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var identity models.Identity
+	err := s.coll.FindOne(ctx, bson.M{"provider": provider, "subject": subject}).Decode(&identity)
+	if err == mongo.ErrNoDocuments {
+		return models.Identity{}, errors.ErrIdentityNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.Identity{}, err
+	}
+	return identity, nil
+}
+
+// Link links the identity to the user of its email and records the login
+// at now, an identity linked before is linked again
+func (s *IdentityStore) Link(ctx context.Context, identity models.Identity, now time.Time) (models.Identity, error) {
+	s.logger.DebugContext(ctx, "Link")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"provider": identity.Provider, "subject": identity.Subject}
+	update := bson.M{
+		"$set":         bson.M{"email": identity.Email, "lastLoginAt": now.UTC()},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "createdAt": now.UTC()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var linked models.Identity
+	if err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&linked); err != nil {
+		s.logger.ErrorContext(ctx, "Link", "error", err)
+		return models.Identity{}, err
+	}
+	s.logger.InfoContext(ctx, "Link", "detail", fmt.Sprintf("Identity of %v linked to %v", identity.Provider, identity.Email))
+
+	return linked, nil
+}
+
+// GetByEmail lists the identities linked to the user of email
+func (s *IdentityStore) GetByEmail(ctx context.Context, email string) ([]models.Identity, error) {
+	s.logger.DebugContext(ctx, "GetByEmail")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	identities := []models.Identity{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cur, err := s.coll.Find(ctx, bson.M{"email": email}, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.Identity{}, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &identities); err != nil {
+		s.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.Identity{}, err
+	}
+	return identities, nil
+}
+
+type OIDCStateStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewOIDCStateStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *OIDCStateStore {
+	return &OIDCStateStore{coll: c, timeout: timeout, logger: l.With("store", "oidc_state")}
+}
+
+// Create a new sign-in state Document
+func (s *OIDCStateStore) Create(ctx context.Context, state models.OIDCState) error {
+	s.logger.DebugContext(ctx, "Create")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	state.ID = primitive.NewObjectID()
+	state.CreatedAt = time.Now().UTC()
+	if _, err := s.coll.InsertOne(ctx, state); err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Consume removes and returns the unexpired state whose hash is hash, so a
+// state completes a single sign-in
+func (s *OIDCStateStore) Consume(ctx context.Context, hash string, now time.Time) (models.OIDCState, error) {
+	s.logger.DebugContext(ctx, "Consume")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var state models.OIDCState
+	err := s.coll.FindOneAndDelete(ctx, bson.M{"state_hash": hash, "expiresAt": bson.M{"$gt": now}}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return models.OIDCState{}, errors.ErrInvalidOIDCState
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Consume", "error", err)
+		return models.OIDCState{}, err
+	}
+	return state, nil
+}
@@ -0,0 +1,764 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/ratelimit"
+	"github.com/tabed23/travel-api/tracing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrument returns s with every operation timed in m and traced as a
+// child span of the span of its context, it works for any implementation of
+// the repositories
+func Instrument(s Stores, m *metrics.Metrics) Stores {
+	return Stores{
+		Tours:         &instrumentedTour{next: s.Tours, m: m},
+		Users:         &instrumentedUser{next: s.Users, m: m},
+		Bookings:      &instrumentedBooking{next: s.Bookings, m: m},
+		Reviews:       &instrumentedReview{next: s.Reviews, m: m},
+		Departures:    &instrumentedDeparture{next: s.Departures, m: m},
+		Payments:      &instrumentedPayment{next: s.Payments, m: m},
+		RefreshTokens: &instrumentedRefreshToken{next: s.RefreshTokens, m: m},
+		RateLimits:    &instrumentedRateLimit{next: s.RateLimits, m: m},
+		LoginAttempts: &instrumentedLoginAttempt{next: s.LoginAttempts, m: m},
+		LoginLocks:    &instrumentedLoginLock{next: s.LoginLocks, m: m},
+		UserTokens:    &instrumentedUserToken{next: s.UserTokens, m: m},
+		MFA:           &instrumentedMFA{next: s.MFA, m: m},
+		SigningKeys:   &instrumentedSigningKey{next: s.SigningKeys, m: m},
+		APIKeys:       &instrumentedAPIKey{next: s.APIKeys, m: m},
+		Identities:    &instrumentedIdentity{next: s.Identities, m: m},
+		OIDCStates:    &instrumentedOIDCState{next: s.OIDCStates, m: m},
+		Sessions:      &instrumentedSession{next: s.Sessions, m: m},
+	}
+}
+
+// observe starts the span of operation op of store and returns the function
+// ending it and recording its duration
+func observe(ctx context.Context, m *metrics.Metrics, store, op string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, store+"."+op,
+		trace.WithAttributes(attribute.String("store", store), attribute.String("operation", op)))
+	return ctx, func(err error) {
+		m.ObserveStore(store, op, start, err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+type instrumentedTour struct {
+	next TourRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedTour) CreateTour(ctx context.Context, tour models.Tour) (models.Tour, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "CreateTour")
+	res, err := i.next.CreateTour(ctx, tour)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) DeleteTour(ctx context.Context, id string) (bool, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "DeleteTour")
+	res, err := i.next.DeleteTour(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) Get(ctx context.Context, id string) (models.Tour, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "Get")
+	res, err := i.next.Get(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) GetAll(ctx context.Context, page, limit int) ([]models.Tour, int, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "GetAll")
+	res, total, err := i.next.GetAll(ctx, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedTour) Update(ctx context.Context, id string, updated models.Tour) (models.Tour, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "Update")
+	res, err := i.next.Update(ctx, id, updated)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) SearchTour(ctx context.Context, city string, distance float32, maxgroupsize int) ([]models.Tour, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "SearchTour")
+	res, err := i.next.SearchTour(ctx, city, distance, maxgroupsize)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) FeaturedTour(ctx context.Context) ([]models.Tour, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "FeaturedTour")
+	res, err := i.next.FeaturedTour(ctx)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedTour) CountTours(ctx context.Context) (int, error) {
+	ctx, done := observe(ctx, i.m, "TourStore", "CountTours")
+	res, err := i.next.CountTours(ctx)
+	done(err)
+	return res, err
+}
+
+type instrumentedUser struct {
+	next UserRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedUser) CreaterUser(ctx context.Context, usr models.User) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "CreaterUser")
+	res, err := i.next.CreaterUser(ctx, usr)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) GetAll(ctx context.Context, page, limit int) ([]models.User, int, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "GetAll")
+	res, total, err := i.next.GetAll(ctx, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedUser) Get(ctx context.Context, email string) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "Get")
+	res, err := i.next.Get(ctx, email)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) Delete(ctx context.Context, email string) (bool, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "Delete")
+	res, err := i.next.Delete(ctx, email)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) UpdateUser(ctx context.Context, email string, usr models.UserUpdate) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "UpdateUser")
+	res, err := i.next.UpdateUser(ctx, email, usr)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) UpdateRole(ctx context.Context, email, role string) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "UpdateRole")
+	res, err := i.next.UpdateRole(ctx, email, role)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) CountUser(ctx context.Context) (int, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "CountUser")
+	res, err := i.next.CountUser(ctx)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) GetByID(ctx context.Context, id string) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "GetByID")
+	res, err := i.next.GetByID(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "GetUserByEmail")
+	res, err := i.next.GetUserByEmail(ctx, email)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUser) SetPassword(ctx context.Context, email, password string) error {
+	ctx, done := observe(ctx, i.m, "UserStore", "SetPassword")
+	err := i.next.SetPassword(ctx, email, password)
+	done(err)
+	return err
+}
+
+func (i *instrumentedUser) MarkVerified(ctx context.Context, email string) (models.User, error) {
+	ctx, done := observe(ctx, i.m, "UserStore", "MarkVerified")
+	res, err := i.next.MarkVerified(ctx, email)
+	done(err)
+	return res, err
+}
+
+type instrumentedBooking struct {
+	next BookingRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedBooking) GetBooking(ctx context.Context, id string) (models.Booking, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "GetBooking")
+	res, err := i.next.GetBooking(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedBooking) CreateBooking(ctx context.Context, userId string, book models.Booking) (models.Booking, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "CreateBooking")
+	res, err := i.next.CreateBooking(ctx, userId, book)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedBooking) Transition(ctx context.Context, id string, to models.BookingStatus, actor, note string) (models.Booking, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "Transition")
+	res, err := i.next.Transition(ctx, id, to, actor, note)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedBooking) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "ExpireHolds")
+	res, err := i.next.ExpireHolds(ctx, now)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedBooking) Update(ctx context.Context, id string, bookUpdate models.UpdateBooking) (models.Booking, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "Update")
+	res, err := i.next.Update(ctx, id, bookUpdate)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedBooking) GetAll(ctx context.Context, page, limit int) ([]models.Booking, int, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "GetAll")
+	res, total, err := i.next.GetAll(ctx, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedBooking) GetByEmail(ctx context.Context, email string, page, limit int) ([]models.Booking, int, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "GetByEmail")
+	res, total, err := i.next.GetByEmail(ctx, email, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedBooking) Count(ctx context.Context) (int, error) {
+	ctx, done := observe(ctx, i.m, "BookingStore", "Count")
+	res, err := i.next.Count(ctx)
+	done(err)
+	return res, err
+}
+
+type instrumentedReview struct {
+	next ReviewRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedReview) CreateReviw(ctx context.Context, tourId string, review models.Review) (models.Review, error) {
+	ctx, done := observe(ctx, i.m, "ReviewStore", "CreateReviw")
+	res, err := i.next.CreateReviw(ctx, tourId, review)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedReview) GetAll(ctx context.Context, page, limit int) ([]models.Review, int, error) {
+	ctx, done := observe(ctx, i.m, "ReviewStore", "GetAll")
+	res, total, err := i.next.GetAll(ctx, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedReview) CountReviews(ctx context.Context) (int, error) {
+	ctx, done := observe(ctx, i.m, "ReviewStore", "CountReviews")
+	res, err := i.next.CountReviews(ctx)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedReview) GetOne(ctx context.Context, id string) (models.Review, error) {
+	ctx, done := observe(ctx, i.m, "ReviewStore", "GetOne")
+	res, err := i.next.GetOne(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedReview) Delete(ctx context.Context, tourId, reviewId string) (bool, error) {
+	ctx, done := observe(ctx, i.m, "ReviewStore", "Delete")
+	res, err := i.next.Delete(ctx, tourId, reviewId)
+	done(err)
+	return res, err
+}
+
+type instrumentedDeparture struct {
+	next DepartureRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedDeparture) Create(ctx context.Context, tourId string, dep models.Departure) (models.Departure, error) {
+	ctx, done := observe(ctx, i.m, "DepartureStore", "Create")
+	res, err := i.next.Create(ctx, tourId, dep)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedDeparture) Get(ctx context.Context, id string) (models.Departure, error) {
+	ctx, done := observe(ctx, i.m, "DepartureStore", "Get")
+	res, err := i.next.Get(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedDeparture) GetByTour(ctx context.Context, tourId string) ([]models.Departure, error) {
+	ctx, done := observe(ctx, i.m, "DepartureStore", "GetByTour")
+	res, err := i.next.GetByTour(ctx, tourId)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedDeparture) Update(ctx context.Context, id string, upd models.UpdateDeparture) (models.Departure, error) {
+	ctx, done := observe(ctx, i.m, "DepartureStore", "Update")
+	res, err := i.next.Update(ctx, id, upd)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedDeparture) Delete(ctx context.Context, id string) (bool, error) {
+	ctx, done := observe(ctx, i.m, "DepartureStore", "Delete")
+	res, err := i.next.Delete(ctx, id)
+	done(err)
+	return res, err
+}
+
+type instrumentedPayment struct {
+	next PaymentRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedPayment) Create(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "Create")
+	res, err := i.next.Create(ctx, payment)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) Get(ctx context.Context, id string) (models.Payment, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "Get")
+	res, err := i.next.Get(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) GetByIntent(ctx context.Context, intentId string) (models.Payment, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "GetByIntent")
+	res, err := i.next.GetByIntent(ctx, intentId)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) GetSucceededByBooking(ctx context.Context, bookingId primitive.ObjectID) (models.Payment, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "GetSucceededByBooking")
+	res, err := i.next.GetSucceededByBooking(ctx, bookingId)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) UpdateStatus(ctx context.Context, id primitive.ObjectID, from, to models.PaymentStatus, reason string) (bool, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "UpdateStatus")
+	res, err := i.next.UpdateStatus(ctx, id, from, to, reason)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) SetRefundPending(ctx context.Context, id primitive.ObjectID, amount float64) error {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "SetRefundPending")
+	err := i.next.SetRefundPending(ctx, id, amount)
+	done(err)
+	return err
+}
+
+func (i *instrumentedPayment) ClaimRefund(ctx context.Context, id primitive.ObjectID, now time.Time, lease time.Duration) (models.Payment, bool, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "ClaimRefund")
+	res, ok, err := i.next.ClaimRefund(ctx, id, now, lease)
+	done(err)
+	return res, ok, err
+}
+
+func (i *instrumentedPayment) AddRefund(ctx context.Context, id primitive.ObjectID, claim string, amount float64, fully bool) (bool, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "AddRefund")
+	res, err := i.next.AddRefund(ctx, id, claim, amount, fully)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedPayment) ReleaseRefund(ctx context.Context, id primitive.ObjectID, claim, reason string) error {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "ReleaseRefund")
+	err := i.next.ReleaseRefund(ctx, id, claim, reason)
+	done(err)
+	return err
+}
+
+func (i *instrumentedPayment) GetRefundPending(ctx context.Context) ([]models.Payment, error) {
+	ctx, done := observe(ctx, i.m, "PaymentStore", "GetRefundPending")
+	res, err := i.next.GetRefundPending(ctx)
+	done(err)
+	return res, err
+}
+
+type instrumentedRefreshToken struct {
+	next RefreshTokenRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedRefreshToken) Create(ctx context.Context, token models.RefreshToken) (models.RefreshToken, error) {
+	ctx, done := observe(ctx, i.m, "RefreshTokenStore", "Create")
+	res, err := i.next.Create(ctx, token)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedRefreshToken) GetByHash(ctx context.Context, hash string) (models.RefreshToken, error) {
+	ctx, done := observe(ctx, i.m, "RefreshTokenStore", "GetByHash")
+	res, err := i.next.GetByHash(ctx, hash)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedRefreshToken) Rotate(ctx context.Context, oldID primitive.ObjectID, next models.RefreshToken) (models.RefreshToken, error) {
+	ctx, done := observe(ctx, i.m, "RefreshTokenStore", "Rotate")
+	res, err := i.next.Rotate(ctx, oldID, next)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedRefreshToken) RevokeFamily(ctx context.Context, family string) error {
+	ctx, done := observe(ctx, i.m, "RefreshTokenStore", "RevokeFamily")
+	err := i.next.RevokeFamily(ctx, family)
+	done(err)
+	return err
+}
+
+func (i *instrumentedRefreshToken) RevokeUser(ctx context.Context, email string) error {
+	ctx, done := observe(ctx, i.m, "RefreshTokenStore", "RevokeUser")
+	err := i.next.RevokeUser(ctx, email)
+	done(err)
+	return err
+}
+
+type instrumentedUserToken struct {
+	next UserTokenRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedUserToken) Create(ctx context.Context, token models.UserToken) (models.UserToken, error) {
+	ctx, done := observe(ctx, i.m, "UserTokenStore", "Create")
+	res, err := i.next.Create(ctx, token)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedUserToken) Consume(ctx context.Context, purpose, hash string, now time.Time) (models.UserToken, error) {
+	ctx, done := observe(ctx, i.m, "UserTokenStore", "Consume")
+	res, err := i.next.Consume(ctx, purpose, hash, now)
+	done(err)
+	return res, err
+}
+
+type instrumentedMFA struct {
+	next MFARepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedMFA) Get(ctx context.Context, email string) (models.MFA, error) {
+	ctx, done := observe(ctx, i.m, "MFAStore", "Get")
+	res, err := i.next.Get(ctx, email)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedMFA) Enroll(ctx context.Context, mfa models.MFA) (models.MFA, error) {
+	ctx, done := observe(ctx, i.m, "MFAStore", "Enroll")
+	res, err := i.next.Enroll(ctx, mfa)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedMFA) Enable(ctx context.Context, email string, step int64, recoveryCodes []string) (models.MFA, error) {
+	ctx, done := observe(ctx, i.m, "MFAStore", "Enable")
+	res, err := i.next.Enable(ctx, email, step, recoveryCodes)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedMFA) UseStep(ctx context.Context, email string, step int64) error {
+	ctx, done := observe(ctx, i.m, "MFAStore", "UseStep")
+	err := i.next.UseStep(ctx, email, step)
+	done(err)
+	return err
+}
+
+func (i *instrumentedMFA) UseRecoveryCode(ctx context.Context, email, hash string) (models.MFA, error) {
+	ctx, done := observe(ctx, i.m, "MFAStore", "UseRecoveryCode")
+	res, err := i.next.UseRecoveryCode(ctx, email, hash)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedMFA) SetRecoveryCodes(ctx context.Context, email string, recoveryCodes []string) error {
+	ctx, done := observe(ctx, i.m, "MFAStore", "SetRecoveryCodes")
+	err := i.next.SetRecoveryCodes(ctx, email, recoveryCodes)
+	done(err)
+	return err
+}
+
+func (i *instrumentedMFA) Delete(ctx context.Context, email string) error {
+	ctx, done := observe(ctx, i.m, "MFAStore", "Delete")
+	err := i.next.Delete(ctx, email)
+	done(err)
+	return err
+}
+
+type instrumentedSigningKey struct {
+	next SigningKeyRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedSigningKey) Create(ctx context.Context, key models.SigningKey) error {
+	ctx, done := observe(ctx, i.m, "SigningKeyStore", "Create")
+	err := i.next.Create(ctx, key)
+	done(err)
+	return err
+}
+
+func (i *instrumentedSigningKey) GetValid(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	ctx, done := observe(ctx, i.m, "SigningKeyStore", "GetValid")
+	res, err := i.next.GetValid(ctx, now)
+	done(err)
+	return res, err
+}
+
+type instrumentedAPIKey struct {
+	next APIKeyRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedAPIKey) Create(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "Create")
+	res, err := i.next.Create(ctx, key)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedAPIKey) Get(ctx context.Context, id string) (models.APIKey, error) {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "Get")
+	res, err := i.next.Get(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedAPIKey) GetByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "GetByHash")
+	res, err := i.next.GetByHash(ctx, hash)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedAPIKey) GetAll(ctx context.Context, page, limit int) ([]models.APIKey, int, error) {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "GetAll")
+	res, total, err := i.next.GetAll(ctx, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedAPIKey) Revoke(ctx context.Context, id string) (models.APIKey, error) {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "Revoke")
+	res, err := i.next.Revoke(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedAPIKey) Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	ctx, done := observe(ctx, i.m, "APIKeyStore", "Touch")
+	err := i.next.Touch(ctx, id, ip, now)
+	done(err)
+	return err
+}
+
+type instrumentedIdentity struct {
+	next IdentityRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedIdentity) Get(ctx context.Context, provider, subject string) (models.Identity, error) {
+	ctx, done := observe(ctx, i.m, "IdentityStore", "Get")
+	res, err := i.next.Get(ctx, provider, subject)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedIdentity) Link(ctx context.Context, identity models.Identity, now time.Time) (models.Identity, error) {
+	ctx, done := observe(ctx, i.m, "IdentityStore", "Link")
+	res, err := i.next.Link(ctx, identity, now)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedIdentity) GetByEmail(ctx context.Context, email string) ([]models.Identity, error) {
+	ctx, done := observe(ctx, i.m, "IdentityStore", "GetByEmail")
+	res, err := i.next.GetByEmail(ctx, email)
+	done(err)
+	return res, err
+}
+
+type instrumentedOIDCState struct {
+	next OIDCStateRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedOIDCState) Create(ctx context.Context, state models.OIDCState) error {
+	ctx, done := observe(ctx, i.m, "OIDCStateStore", "Create")
+	err := i.next.Create(ctx, state)
+	done(err)
+	return err
+}
+
+func (i *instrumentedOIDCState) Consume(ctx context.Context, hash string, now time.Time) (models.OIDCState, error) {
+	ctx, done := observe(ctx, i.m, "OIDCStateStore", "Consume")
+	res, err := i.next.Consume(ctx, hash, now)
+	done(err)
+	return res, err
+}
+
+type instrumentedSession struct {
+	next SessionRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedSession) Create(ctx context.Context, session models.Session) (models.Session, error) {
+	ctx, done := observe(ctx, i.m, "SessionStore", "Create")
+	res, err := i.next.Create(ctx, session)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedSession) Get(ctx context.Context, id string) (models.Session, error) {
+	ctx, done := observe(ctx, i.m, "SessionStore", "Get")
+	res, err := i.next.Get(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedSession) GetActive(ctx context.Context, email string, now time.Time) ([]models.Session, error) {
+	ctx, done := observe(ctx, i.m, "SessionStore", "GetActive")
+	res, err := i.next.GetActive(ctx, email, now)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedSession) Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	ctx, done := observe(ctx, i.m, "SessionStore", "Touch")
+	err := i.next.Touch(ctx, id, ip, now)
+	done(err)
+	return err
+}
+
+func (i *instrumentedSession) Extend(ctx context.Context, id primitive.ObjectID, ip string, now, expiresAt time.Time) error {
+	ctx, done := observe(ctx, i.m, "SessionStore", "Extend")
+	err := i.next.Extend(ctx, id, ip, now, expiresAt)
+	done(err)
+	return err
+}
+
+func (i *instrumentedSession) Revoke(ctx context.Context, email, id string) (models.Session, error) {
+	ctx, done := observe(ctx, i.m, "SessionStore", "Revoke")
+	res, err := i.next.Revoke(ctx, email, id)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedSession) RevokeAll(ctx context.Context, email, except string) (int, error) {
+	ctx, done := observe(ctx, i.m, "SessionStore", "RevokeAll")
+	res, err := i.next.RevokeAll(ctx, email, except)
+	done(err)
+	return res, err
+}
+
+type instrumentedRateLimit struct {
+	next RateLimitRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedRateLimit) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	ctx, done := observe(ctx, i.m, "RateLimitStore", "Take")
+	res, err := i.next.Take(ctx, key, limit, now)
+	done(err)
+	return res, err
+}
+
+type instrumentedLoginAttempt struct {
+	next LoginAttemptRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedLoginAttempt) Record(ctx context.Context, attempt models.LoginAttempt) (models.LoginAttempt, error) {
+	ctx, done := observe(ctx, i.m, "LoginAttemptStore", "Record")
+	res, err := i.next.Record(ctx, attempt)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedLoginAttempt) GetByEmail(ctx context.Context, email string, page, limit int) ([]models.LoginAttempt, int, error) {
+	ctx, done := observe(ctx, i.m, "LoginAttemptStore", "GetByEmail")
+	res, total, err := i.next.GetByEmail(ctx, email, page, limit)
+	done(err)
+	return res, total, err
+}
+
+func (i *instrumentedLoginAttempt) SuccessfulIPs(ctx context.Context, email string) ([]string, error) {
+	ctx, done := observe(ctx, i.m, "LoginAttemptStore", "SuccessfulIPs")
+	res, err := i.next.SuccessfulIPs(ctx, email)
+	done(err)
+	return res, err
+}
+
+type instrumentedLoginLock struct {
+	next LoginLockRepository
+	m    *metrics.Metrics
+}
+
+func (i *instrumentedLoginLock) Get(ctx context.Context, key string, now time.Time) (models.LoginLock, error) {
+	ctx, done := observe(ctx, i.m, "LoginLockStore", "Get")
+	res, err := i.next.Get(ctx, key, now)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedLoginLock) Fail(ctx context.Context, key string, now time.Time, forgetAfter time.Duration) (models.LoginLock, error) {
+	ctx, done := observe(ctx, i.m, "LoginLockStore", "Fail")
+	res, err := i.next.Fail(ctx, key, now, forgetAfter)
+	done(err)
+	return res, err
+}
+
+func (i *instrumentedLoginLock) Lock(ctx context.Context, key string, until time.Time) error {
+	ctx, done := observe(ctx, i.m, "LoginLockStore", "Lock")
+	err := i.next.Lock(ctx, key, until)
+	done(err)
+	return err
+}
+
+func (i *instrumentedLoginLock) Reset(ctx context.Context, key string) error {
+	ctx, done := observe(ctx, i.m, "LoginLockStore", "Reset")
+	err := i.next.Reset(ctx, key)
+	done(err)
+	return err
+}
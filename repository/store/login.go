@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewLoginAttemptStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *LoginAttemptStore {
+	return &LoginAttemptStore{coll: c, timeout: timeout, logger: l.With("store", "login_attempt")}
+}
+
+// Record adds attempt to the login history
+func (s *LoginAttemptStore) Record(ctx context.Context, attempt models.LoginAttempt) (models.LoginAttempt, error) {
+	s.logger.DebugContext(ctx, "Record")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	attempt.ID = primitive.NewObjectID()
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now().UTC()
+	}
+	if _, err := s.coll.InsertOne(ctx, attempt); err != nil {
+		s.logger.ErrorContext(ctx, "Record", "error", err)
+		return models.LoginAttempt{}, err
+	}
+	return attempt, nil
+}
+
+// GetByEmail lists the login attempts of email, newest first
+func (s *LoginAttemptStore) GetByEmail(ctx context.Context, email string, page, limit int) ([]models.LoginAttempt, int, error) {
+	s.logger.DebugContext(ctx, "GetByEmail")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	skip := (page - 1) * limit
+
+	filter := bson.M{"email": email}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(skip))
+	attempts := []models.LoginAttempt{}
+
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.LoginAttempt{}, 0, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &attempts); err != nil {
+		s.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.LoginAttempt{}, 0, err
+	}
+	count, err := s.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetByEmail", "error", err)
+		return []models.LoginAttempt{}, 0, err
+	}
+	return attempts, int(count), nil
+}
+
+// SuccessfulIPs lists the IPs email logged in from
+func (s *LoginAttemptStore) SuccessfulIPs(ctx context.Context, email string) ([]string, error) {
+	s.logger.DebugContext(ctx, "SuccessfulIPs")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	values, err := s.coll.Distinct(ctx, "ip", bson.M{"email": email, "success": true})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SuccessfulIPs", "error", err)
+		return nil, err
+	}
+	ips := make([]string, 0, len(values))
+	for _, v := range values {
+		if ip, ok := v.(string); ok {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+type LoginLockStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewLoginLockStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *LoginLockStore {
+	return &LoginLockStore{coll: c, timeout: timeout, logger: l.With("store", "login_lock")}
+}
+
+// Get returns the lock of key, a key without failures has an empty lock
+func (s *LoginLockStore) Get(ctx context.Context, key string, now time.Time) (models.LoginLock, error) {
+	s.logger.DebugContext(ctx, "Get")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var lock models.LoginLock
+	err := s.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&lock)
+	if err == mongo.ErrNoDocuments {
+		return models.LoginLock{Key: key}, nil
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.LoginLock{}, err
+	}
+	// the TTL monitor only runs every minute
+	if !now.Before(lock.ExpiresAt) {
+		return models.LoginLock{Key: key}, nil
+	}
+	return lock, nil
+}
+
+// Fail counts a failed login of key, failures are forgotten forgetAfter the
+// last one
+func (s *LoginLockStore) Fail(ctx context.Context, key string, now time.Time, forgetAfter time.Duration) (models.LoginLock, error) {
+	s.logger.DebugContext(ctx, "Fail")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	expired := bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$expires_at", now}}, now}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":     bson.M{"$cond": bson.A{expired, 1, bson.M{"$add": bson.A{"$failures", 1}}}},
+			"locked_until": bson.M{"$cond": bson.A{expired, time.Time{}, "$locked_until"}},
+			"last_failure": now,
+			"expires_at":   now.Add(forgetAfter),
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var lock models.LoginLock
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&lock)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent failure created the lock first, count on it
+		err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&lock)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Fail", "error", err)
+		return models.LoginLock{}, err
+	}
+	return lock, nil
+}
+
+// Lock refuses the logins of key until until, an existing later lock is kept
+func (s *LoginLockStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.logger.DebugContext(ctx, "Lock")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	update := bson.M{"$max": bson.M{"locked_until": until, "expires_at": until}}
+	if _, err := s.coll.UpdateOne(ctx, bson.M{"_id": key}, update); err != nil {
+		s.logger.ErrorContext(ctx, "Lock", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Reset forgets the failures of key and unlocks it
+func (s *LoginLockStore) Reset(ctx context.Context, key string) error {
+	s.logger.DebugContext(ctx, "Reset")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.coll.DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		s.logger.ErrorContext(ctx, "Reset", "error", err)
+		return err
+	}
+	return nil
+}
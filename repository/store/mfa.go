@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MFAStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewMFAStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *MFAStore {
+	return &MFAStore{coll: c, timeout: timeout, logger: l.With("store", "mfa")}
+}
+
+// Get returns the second factor of email, pending or enabled
+func (s *MFAStore) Get(ctx context.Context, email string) (models.MFA, error) {
+	s.logger.DebugContext(ctx, "Get")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var mfa models.MFA
+	err := s.coll.FindOne(ctx, bson.M{"_id": email}).Decode(&mfa)
+	if err == mongo.ErrNoDocuments {
+		return models.MFA{}, errors.ErrMFANotEnrolled
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.MFA{}, err
+	}
+	return mfa, nil
+}
+
+// Enroll stores a pending second factor, replacing an earlier pending one.
+// An enabled second factor is kept, it has to be removed first.
+func (s *MFAStore) Enroll(ctx context.Context, mfa models.MFA) (models.MFA, error) {
+	s.logger.DebugContext(ctx, "Enroll")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	mfa.Enabled = false
+	mfa.CreatedAt = time.Now().UTC()
+	filter := bson.M{"_id": mfa.Email, "enabled": bson.M{"$ne": true}}
+	_, err := s.coll.ReplaceOne(ctx, filter, mfa, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return models.MFA{}, errors.ErrMFAEnabled
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Enroll", "error", err)
+		return models.MFA{}, err
+	}
+	return mfa, nil
+}
+
+// Enable turns on the pending second factor of email, step is the step of
+// the code that confirmed it
+func (s *MFAStore) Enable(ctx context.Context, email string, step int64, recoveryCodes []string) (models.MFA, error) {
+	s.logger.DebugContext(ctx, "Enable")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	update := bson.M{"$set": bson.M{
+		"enabled":        true,
+		"last_step":      step,
+		"recovery_codes": recoveryCodes,
+		"enabledAt":      time.Now().UTC(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var mfa models.MFA
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": email, "enabled": false}, update, opts).Decode(&mfa)
+	if err == mongo.ErrNoDocuments {
+		return models.MFA{}, errors.ErrMFANotEnrolled
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Enable", "error", err)
+		return models.MFA{}, err
+	}
+	return mfa, nil
+}
+
+// UseStep records that a code of step was accepted. It fails when a code of
+// the same or a later step was accepted before, so each code works once.
+func (s *MFAStore) UseStep(ctx context.Context, email string, step int64) error {
+	s.logger.DebugContext(ctx, "UseStep")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": email, "enabled": true, "last_step": bson.M{"$lt": step}}
+	res, err := s.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_step": step}})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "UseStep", "error", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseRecoveryCode removes the recovery code with hash, it fails when email
+// has no such code
+func (s *MFAStore) UseRecoveryCode(ctx context.Context, email, hash string) (models.MFA, error) {
+	s.logger.DebugContext(ctx, "UseRecoveryCode")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": email, "enabled": true, "recovery_codes": hash}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var mfa models.MFA
+	err := s.coll.FindOneAndUpdate(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}}, opts).Decode(&mfa)
+	if err == mongo.ErrNoDocuments {
+		return models.MFA{}, errors.ErrInvalidMFACode
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "UseRecoveryCode", "error", err)
+		return models.MFA{}, err
+	}
+	return mfa, nil
+}
+
+// SetRecoveryCodes replaces the recovery codes of email
+func (s *MFAStore) SetRecoveryCodes(ctx context.Context, email string, recoveryCodes []string) error {
+	s.logger.DebugContext(ctx, "SetRecoveryCodes")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": email, "enabled": true}
+	res, err := s.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"recovery_codes": recoveryCodes}})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SetRecoveryCodes", "error", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.ErrMFANotEnrolled
+	}
+	return nil
+}
+
+// Delete removes the second factor of email
+func (s *MFAStore) Delete(ctx context.Context, email string) error {
+	s.logger.DebugContext(ctx, "Delete")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	res, err := s.coll.DeleteOne(ctx, bson.M{"_id": email})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Delete", "error", err)
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.ErrMFANotEnrolled
+	}
+	return nil
+}
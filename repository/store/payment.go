@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PaymentStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewPaymentStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *PaymentStore {
+	return &PaymentStore{coll: c, timeout: timeout, logger: l.With("store", "payment")}
+}
+
+// Create a new payment Document
+func (p *PaymentStore) Create(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	p.logger.DebugContext(ctx, "Create")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	payment.ID = primitive.NewObjectID()
+	payment.CreatedAt = time.Now().UTC()
+	payment.UpdatedAt = time.Now().UTC()
+	if _, err := p.coll.InsertOne(ctx, payment); err != nil {
+		p.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Payment{}, err
+	}
+	p.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("Payment created: %v", payment.ID.Hex()))
+
+	return payment, nil
+}
+
+// Get Payment Document by ID
+func (p *PaymentStore) Get(ctx context.Context, id string) (models.Payment, error) {
+	p.logger.DebugContext(ctx, "Get")
+
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Payment{}, errors.ErrPaymentNotFound
+	}
+	return p.findOne(ctx, bson.M{"_id": objId}, nil)
+}
+
+// Get Payment Document by provider intent ID
+func (p *PaymentStore) GetByIntent(ctx context.Context, intentId string) (models.Payment, error) {
+	p.logger.DebugContext(ctx, "GetByIntent")
+
+	return p.findOne(ctx, bson.M{"intent_id": intentId}, nil)
+}
+
+// Get the latest successful Payment Document of a booking
+func (p *PaymentStore) GetSucceededByBooking(ctx context.Context, bookingId primitive.ObjectID) (models.Payment, error) {
+	p.logger.DebugContext(ctx, "GetSucceededByBooking")
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	return p.findOne(ctx, bson.M{"booking_id": bookingId, "status": models.PaymentSucceeded}, opts)
+}
+
+func (p *PaymentStore) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptions) (models.Payment, error) {
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	var payment models.Payment
+	if err := p.coll.FindOne(ctx, filter, opts).Decode(&payment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			p.logger.WarnContext(ctx, "findOne", "detail", fmt.Sprintf("Payment not found: %v", filter))
+			return models.Payment{}, errors.ErrPaymentNotFound
+		}
+		p.logger.ErrorContext(ctx, "findOne", "error", err)
+		return models.Payment{}, err
+	}
+	return payment, nil
+}
+
+// UpdateStatus moves a payment from one status to another. It reports false
+// when the payment was no longer in status from, so that a webhook and a
+// synchronous confirmation can not both apply the same result.
+func (p *PaymentStore) UpdateStatus(ctx context.Context, id primitive.ObjectID, from, to models.PaymentStatus, reason string) (bool, error) {
+	p.logger.DebugContext(ctx, "UpdateStatus")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	set := bson.M{"status": to, "updatedAt": time.Now().UTC()}
+	if reason != "" {
+		set["failure_reason"] = reason
+	}
+	res, err := p.coll.UpdateOne(ctx, bson.M{"_id": id, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "UpdateStatus", "error", err)
+		return false, err
+	}
+	p.logger.InfoContext(ctx, "UpdateStatus", "detail", fmt.Sprintf("Payment %v from %v to %v: %d", id.Hex(), from, to, res.ModifiedCount))
+
+	return res.ModifiedCount > 0, nil
+}
+
+// SetRefundPending records amount as owed back on a payment until AddRefund
+// records it as refunded
+func (p *PaymentStore) SetRefundPending(ctx context.Context, id primitive.ObjectID, amount float64) error {
+	p.logger.DebugContext(ctx, "SetRefundPending")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	set := bson.M{"refund_pending": amount, "updatedAt": time.Now().UTC()}
+	if _, err := p.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+		p.logger.ErrorContext(ctx, "SetRefundPending", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ClaimRefund leases the pending refund of a payment to the caller until
+// now+lease, so that only one caller at a time sends it to the provider. It
+// reports false when nothing is pending or another caller holds an unexpired
+// lease. The returned payment carries the claim AddRefund and ReleaseRefund
+// are conditional on.
+func (p *PaymentStore) ClaimRefund(ctx context.Context, id primitive.ObjectID, now time.Time, lease time.Duration) (models.Payment, bool, error) {
+	p.logger.DebugContext(ctx, "ClaimRefund")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	filter := bson.M{
+		"_id":            id,
+		"refund_pending": bson.M{"$gt": 0},
+		"$or": bson.A{
+			bson.M{"refund_lease_until": bson.M{"$exists": false}},
+			bson.M{"refund_lease_until": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"refund_claim": primitive.NewObjectID().Hex(), "refund_lease_until": now.Add(lease), "updatedAt": now},
+		"$inc": bson.M{"refund_attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var payment models.Payment
+	err := p.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		return models.Payment{}, false, nil
+	}
+	if err != nil {
+		p.logger.ErrorContext(ctx, "ClaimRefund", "error", err)
+		return models.Payment{}, false, err
+	}
+	return payment, true, nil
+}
+
+// AddRefund records a refunded amount on a payment if the caller still holds
+// claim, the refund is no longer pending. It reports false when the claim was
+// lost, the amount is then left to the caller holding the new claim.
+func (p *PaymentStore) AddRefund(ctx context.Context, id primitive.ObjectID, claim string, amount float64, fully bool) (bool, error) {
+	p.logger.DebugContext(ctx, "AddRefund")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	set := bson.M{"updatedAt": time.Now().UTC()}
+	if fully {
+		set["status"] = models.PaymentRefunded
+	}
+	update := bson.M{
+		"$inc":   bson.M{"refunded_amount": amount},
+		"$set":   set,
+		"$unset": bson.M{"refund_pending": "", "refund_error": "", "refund_claim": "", "refund_lease_until": "", "refund_attempts": ""},
+	}
+	res, err := p.coll.UpdateOne(ctx, bson.M{"_id": id, "refund_claim": claim}, update)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "AddRefund", "error", err)
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// ReleaseRefund gives up claim on the pending refund of a payment after the
+// provider failed it, recording why, so the next run can claim it again
+func (p *PaymentStore) ReleaseRefund(ctx context.Context, id primitive.ObjectID, claim, reason string) error {
+	p.logger.DebugContext(ctx, "ReleaseRefund")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	update := bson.M{
+		"$set":   bson.M{"refund_error": reason, "updatedAt": time.Now().UTC()},
+		"$unset": bson.M{"refund_claim": "", "refund_lease_until": ""},
+	}
+	if _, err := p.coll.UpdateOne(ctx, bson.M{"_id": id, "refund_claim": claim}, update); err != nil {
+		p.logger.ErrorContext(ctx, "ReleaseRefund", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Get the Payment Documents with a refund still pending, oldest first
+func (p *PaymentStore) GetRefundPending(ctx context.Context) ([]models.Payment, error) {
+	p.logger.DebugContext(ctx, "GetRefundPending")
+
+	ctx, cancle := context.WithTimeout(ctx, p.timeout)
+	defer cancle()
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}})
+	cur, err := p.coll.Find(ctx, bson.M{"refund_pending": bson.M{"$gt": 0}}, opts)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "GetRefundPending", "error", err)
+		return []models.Payment{}, err
+	}
+	defer cur.Close(ctx)
+	payments := []models.Payment{}
+	if err := cur.All(ctx, &payments); err != nil {
+		p.logger.ErrorContext(ctx, "GetRefundPending", "error", err)
+		return []models.Payment{}, err
+	}
+
+	return payments, nil
+}
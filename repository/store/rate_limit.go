@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/ratelimit"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitStore keeps the token buckets of every instance in MongoDB, so a
+// client is limited the same whichever instance serves it
+type RateLimitStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewRateLimitStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *RateLimitStore {
+	return &RateLimitStore{coll: c, timeout: timeout, logger: l.With("store", "rate_limit")}
+}
+
+type rateLimitBucket struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+// Take refills and takes from the bucket of key in a single update, so
+// concurrent requests of a client can not take the same token twice
+func (r *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	r.logger.DebugContext(ctx, "Take")
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	capacity := float64(limit.Requests)
+	rate := limit.Rate()
+	elapsed := bson.M{"$divide": bson.A{
+		bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}}}},
+		1000,
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{elapsed, rate}},
+			}}}},
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":     bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"updated_at": now,
+		}}},
+		// the TTL index deletes the bucket once it has refilled
+		{{Key: "$set", Value: bson.M{
+			"expires_at": bson.M{"$add": bson.A{now, bson.M{"$multiply": bson.A{
+				bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{capacity, "$tokens"}}, rate}},
+				1000,
+			}}}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var bucket rateLimitBucket
+	err := r.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent request created the bucket first, take from it
+		err = r.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&bucket)
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "Take", "error", err)
+		return ratelimit.Result{}, err
+	}
+	return ratelimit.NewResult(limit, bucket.Tokens, bucket.Allowed), nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RefreshTokenStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewRefreshTokenStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *RefreshTokenStore {
+	return &RefreshTokenStore{coll: c, timeout: timeout, logger: l.With("store", "refresh_token")}
+}
+
+// Create a new refresh token Document
+func (r *RefreshTokenStore) Create(ctx context.Context, token models.RefreshToken) (models.RefreshToken, error) {
+	r.logger.DebugContext(ctx, "Create")
+
+	ctx, cancle := context.WithTimeout(ctx, r.timeout)
+	defer cancle()
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now().UTC()
+	if _, err := r.coll.InsertOne(ctx, token); err != nil {
+		r.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.RefreshToken{}, err
+	}
+	r.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("RefreshToken created for family %v", token.Family))
+
+	return token, nil
+}
+
+// Get refresh token Document by its hash
+func (r *RefreshTokenStore) GetByHash(ctx context.Context, hash string) (models.RefreshToken, error) {
+	r.logger.DebugContext(ctx, "GetByHash")
+
+	ctx, cancle := context.WithTimeout(ctx, r.timeout)
+	defer cancle()
+	var token models.RefreshToken
+	if err := r.coll.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&token); err != nil {
+		if err == mongo.ErrNoDocuments {
+			r.logger.WarnContext(ctx, "GetByHash", "detail", "RefreshToken not found")
+			return models.RefreshToken{}, errors.ErrInvalidRefreshToken
+		}
+		r.logger.ErrorContext(ctx, "GetByHash", "error", err)
+		return models.RefreshToken{}, err
+	}
+
+	return token, nil
+}
+
+// Rotate stores the replacement of the old token and then marks the old
+// token as used. The replacement is stored first so a failed write leaves the
+// client with its old token, which it can present again. The old token is
+// only consumed if it has not been revoked yet, so two concurrent refreshes
+// with the same token cannot both succeed; the loser's replacement is removed.
+func (r *RefreshTokenStore) Rotate(ctx context.Context, oldID primitive.ObjectID, next models.RefreshToken) (models.RefreshToken, error) {
+	r.logger.DebugContext(ctx, "Rotate")
+
+	ctx, cancle := context.WithTimeout(ctx, r.timeout)
+	defer cancle()
+	next.ID = primitive.NewObjectID()
+	next.CreatedAt = time.Now().UTC()
+
+	if _, err := r.coll.InsertOne(ctx, next); err != nil {
+		r.logger.ErrorContext(ctx, "Rotate", "error", err)
+		return models.RefreshToken{}, err
+	}
+
+	filter := bson.M{"_id": oldID, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedAt": next.CreatedAt, "replacedBy": next.ID}}
+	res, err := r.coll.UpdateOne(ctx, filter, update)
+	if err == nil && res.ModifiedCount == 0 {
+		r.logger.WarnContext(ctx, "Rotate", "detail", fmt.Sprintf("RefreshToken %v already used", oldID.Hex()))
+		err = errors.ErrRefreshTokenReused
+	}
+	if err != nil {
+		if !errors.Is(err, errors.ErrRefreshTokenReused) {
+			r.logger.ErrorContext(ctx, "Rotate", "error", err)
+		}
+		// the replacement was never handed out, it must not outlive the
+		// failed rotation
+		if _, delErr := r.coll.DeleteOne(ctx, bson.M{"_id": next.ID}); delErr != nil {
+			r.logger.ErrorContext(ctx, "Rotate", "error", delErr)
+		}
+		return models.RefreshToken{}, err
+	}
+	r.logger.InfoContext(ctx, "Rotate", "detail", fmt.Sprintf("RefreshToken rotated for family %v", next.Family))
+
+	return next, nil
+}
+
+// Revoke every token of a family
+func (r *RefreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	r.logger.DebugContext(ctx, "RevokeFamily")
+
+	ctx, cancle := context.WithTimeout(ctx, r.timeout)
+	defer cancle()
+	filter := bson.M{"family": family, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}}
+	res, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "RevokeFamily", "error", err)
+		return err
+	}
+	r.logger.InfoContext(ctx, "RevokeFamily", "detail", fmt.Sprintf("%d tokens revoked in family %v", res.ModifiedCount, family))
+
+	return nil
+}
+
+// RevokeUser revokes every refresh token of email, signing the user out of
+// every device
+func (r *RefreshTokenStore) RevokeUser(ctx context.Context, email string) error {
+	r.logger.DebugContext(ctx, "RevokeUser")
+
+	ctx, cancle := context.WithTimeout(ctx, r.timeout)
+	defer cancle()
+	filter := bson.M{"email": email, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}}
+	res, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "RevokeUser", "error", err)
+		return err
+	}
+	r.logger.InfoContext(ctx, "RevokeUser", "detail", fmt.Sprintf("%d tokens revoked of %v", res.ModifiedCount, email))
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -14,42 +15,47 @@ import (
 )
 
 type ReviewStore struct {
-	review mongo.Collection
-	tour   mongo.Collection
-	logger *slog.Logger
+	review  mongo.Collection
+	tour    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
 }
 
-func NewReviewStore(r mongo.Collection, t mongo.Collection, l *slog.Logger) *ReviewStore {
-	return &ReviewStore{review: r, tour: t, logger: l}
+func NewReviewStore(r mongo.Collection, t mongo.Collection, timeout time.Duration, l *slog.Logger) *ReviewStore {
+	return &ReviewStore{review: r, tour: t, timeout: timeout, logger: l.With("store", "review")}
 }
 
 // Create a new review Document
-func (s *ReviewStore) CreateReviw(id string, review models.Review) (models.Review, error) {
-	s.logger.Info("repository", "CreateReviw", "Review")
+func (s *ReviewStore) CreateReviw(ctx context.Context, id string, review models.Review) (models.Review, error) {
+	s.logger.DebugContext(ctx, "CreateReviw")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 35*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, s.timeout)
 	defer cancle()
 	var tour models.Tour
 	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		s.logger.Error("repository", "CreateReviw", err.Error())
-		return models.Review{}, err
+		s.logger.ErrorContext(ctx, "CreateReviw", "error", err)
+		return models.Review{}, errors.ErrTourNotFound
 	}
 
 	if err = s.tour.FindOne(ctx, bson.M{"_id": objId}).Decode(&tour); err != nil {
-		s.logger.Error("repository", "CreateReviw", err.Error())
+		s.logger.ErrorContext(ctx, "CreateReviw", "error", err)
+		if err == mongo.ErrNoDocuments {
+			return models.Review{}, errors.ErrTourNotFound
+		}
 		return models.Review{}, err
 	}
 
-	s.logger.Info("repository", "CreateReviw", fmt.Sprintf("Document Found %v", tour))
+	s.logger.InfoContext(ctx, "CreateReviw", "detail", fmt.Sprintf("Document Found %v", tour))
 
 	review.ID = primitive.NewObjectID()
 	review.TourID = tour.ID
 	review.CreatedAt = time.Now().UTC()
 	review.UpdatedAt = time.Now().UTC()
+	// tours created without reviews store a null array that $push can not extend
 	arryFilter := bson.M{"$set": bson.M{"reviews": []interface{}{}}}
-	if _, err := s.tour.UpdateOne(ctx, bson.M{"_id": objId}, arryFilter); err != nil {
-		s.logger.Error("repository", "CreateReviw", err.Error())
+	if _, err := s.tour.UpdateOne(ctx, bson.M{"_id": objId, "reviews": nil}, arryFilter); err != nil {
+		s.logger.ErrorContext(ctx, "CreateReviw", "error", err)
 		return models.Review{}, err
 	}
 
@@ -61,44 +67,44 @@ func (s *ReviewStore) CreateReviw(id string, review models.Review) (models.Revie
 	opts := options.Update().SetUpsert(upsert)
 
 	if _, err := s.tour.UpdateOne(ctx, filter, update, opts); err != nil {
-		s.logger.Error("repository", "CreateReviw", err.Error())
+		s.logger.ErrorContext(ctx, "CreateReviw", "error", err)
 		return models.Review{}, err
 	}
-	s.logger.Info("repository", "CreateReviw", fmt.Sprintf("Document Review insetred in Tour %v", review))
+	s.logger.InfoContext(ctx, "CreateReviw", "detail", fmt.Sprintf("Document Review insetred in Tour %v", review))
 
 	if _, err = s.review.InsertOne(ctx, review); err != nil {
-		s.logger.Error("repository", "CreateReviw", err.Error())
+		s.logger.ErrorContext(ctx, "CreateReviw", "error", err)
 		return models.Review{}, err
 	}
-	s.logger.Info("repository", "CreateReviw", fmt.Sprintf("Document Review Created %v", review))
+	s.logger.InfoContext(ctx, "CreateReviw", "detail", fmt.Sprintf("Document Review Created %v", review))
 
 	return review, nil
 }
 
 // ISExist Document
-func (s *ReviewStore) IsExist(data, value string) (bool, error) {
-	s.logger.Info("repository", "IsExist", "Review")
+func (s *ReviewStore) IsExist(ctx context.Context, data, value string) (bool, error) {
+	s.logger.DebugContext(ctx, "IsExist")
 
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, s.timeout)
 	defer cancle()
 	count, err := s.review.CountDocuments(ctx, bson.M{value: data})
 	if err != nil {
-		s.logger.Error("repository", "IsExist", err.Error())
+		s.logger.ErrorContext(ctx, "IsExist", "error", err)
 		return false, err
 	}
 	if count > 0 {
 		return true, nil
 	}
-	s.logger.Warn("repository", "IsExist", fmt.Sprintf("%v documents not found", value))
+	s.logger.WarnContext(ctx, "IsExist", "detail", fmt.Sprintf("%v documents not found", value))
 
 	return false, nil
 }
 
 // Get All Document
-func (s *ReviewStore) GetAll(page, limit int) ([]models.Review, int, error) {
-	s.logger.Info("repository", "GetAll", "Review")
+func (s *ReviewStore) GetAll(ctx context.Context, page, limit int) ([]models.Review, int, error) {
+	s.logger.DebugContext(ctx, "GetAll")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, s.timeout)
 	defer cancle()
 	skip := (page - 1) * limit
 
@@ -107,20 +113,20 @@ func (s *ReviewStore) GetAll(page, limit int) ([]models.Review, int, error) {
 
 	cur, err := s.review.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		s.logger.Error("repository", "GetAll", err.Error())
+		s.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return []models.Review{}, 0, err
 	}
 	for cur.Next(ctx) {
 		var review models.Review
 		if err := cur.Decode(&review); err != nil {
-			s.logger.Error("repository", "GetAll", err.Error())
+			s.logger.ErrorContext(ctx, "GetAll", "error", err)
 
 			return []models.Review{}, 0, err
 		}
 		reviews = append(reviews, review)
 	}
 	if err := cur.Err(); err != nil {
-		s.logger.Error("repository", "GetAll", err.Error())
+		s.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return []models.Review{}, 0, err
 	}
 	count, err := s.review.CountDocuments(ctx, bson.M{})
@@ -128,67 +134,72 @@ func (s *ReviewStore) GetAll(page, limit int) ([]models.Review, int, error) {
 }
 
 // Count Reviews Documents
-func (s *ReviewStore) CountReviews() (int, error) {
-	s.logger.Info("repository", "CountReviews", "Review")
+func (s *ReviewStore) CountReviews(ctx context.Context) (int, error) {
+	s.logger.DebugContext(ctx, "CountReviews")
 
+	ctx, cancle := context.WithTimeout(ctx, s.timeout)
+	defer cancle()
 	opts := options.Count().SetHint("_id_")
-	count, err := s.review.CountDocuments(context.TODO(), opts)
+	count, err := s.review.CountDocuments(ctx, bson.D{}, opts)
 	if err != nil {
-		s.logger.Error("repository", "CountReviews", err.Error())
+		s.logger.ErrorContext(ctx, "CountReviews", "error", err)
 		return 0, err
 	}
-	s.logger.Error("repository", "CountReviews", fmt.Sprintf("Found %d Documents", count))
+	s.logger.DebugContext(ctx, "CountReviews", "detail", fmt.Sprintf("Found %d Documents", count))
 
 	return int(count), nil
 }
 
 // Get One Review Document
-func (s *ReviewStore) GetOne(id string) (models.Review, error) {
-	s.logger.Info("repository", "GetOne", "Review")
+func (s *ReviewStore) GetOne(ctx context.Context, id string) (models.Review, error) {
+	s.logger.DebugContext(ctx, "GetOne")
 
 	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return models.Review{}, err
+		return models.Review{}, errors.ErrReviewNotFound
 	}
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, s.timeout)
 	defer cancle()
 	var review models.Review
 	if err := s.review.FindOne(ctx, bson.M{"_id": objId}).Decode(&review); err != nil {
-		s.logger.Error("repository", "GetOne", err.Error())
+		s.logger.ErrorContext(ctx, "GetOne", "error", err)
+		if err == mongo.ErrNoDocuments {
+			return models.Review{}, errors.ErrReviewNotFound
+		}
 		return models.Review{}, err
 	}
-	s.logger.Info("repository", "GetOne", fmt.Sprintf("Found %v", review))
+	s.logger.InfoContext(ctx, "GetOne", "detail", fmt.Sprintf("Found %v", review))
 
 	return review, nil
 }
 
 // Delete Document
-func (s *ReviewStore) Delete(tourId, reviewId string) (bool, error) {
-	s.logger.Info("repository", "Delete", "Review")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (s *ReviewStore) Delete(ctx context.Context, tourId, reviewId string) (bool, error) {
+	s.logger.DebugContext(ctx, "Delete")
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
 	tourObjId, err := primitive.ObjectIDFromHex(tourId)
 	if err != nil {
-		return false, err
+		return false, errors.ErrTourNotFound
 	}
 	reviewObjId, err := primitive.ObjectIDFromHex(reviewId)
 	if err != nil {
-		return false, err
+		return false, errors.ErrReviewNotFound
 	}
 
 	filter := bson.M{"_id": tourObjId}
 	update := bson.M{"$pull": bson.M{"reviews": reviewObjId}}
 	if _, err := s.tour.UpdateOne(ctx, filter, update); err != nil {
-		s.logger.Error("repository", "Delete", err.Error())
+		s.logger.ErrorContext(ctx, "Delete", "error", err)
 		return false, err
 	}
 
 	if _, err := s.review.DeleteOne(ctx, bson.M{"_id": reviewObjId}); err != nil {
-		s.logger.Error("repository", "Delete", err.Error())
+		s.logger.ErrorContext(ctx, "Delete", "error", err)
 		return false, err
 	}
-	s.logger.Info("repository", "Delete", fmt.Sprintf("reviews: %v deleted", reviewObjId))
+	s.logger.InfoContext(ctx, "Delete", "detail", fmt.Sprintf("reviews: %v deleted", reviewObjId))
 
 	return true, nil
 }
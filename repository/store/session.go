@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SessionTouchInterval is how stale the recorded last activity of a session
+// may get
+const SessionTouchInterval = time.Minute
+
+type SessionStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewSessionStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *SessionStore {
+	return &SessionStore{coll: c, timeout: timeout, logger: l.With("store", "session")}
+}
+
+// Create a new session Document
+func (s *SessionStore) Create(ctx context.Context, session models.Session) (models.Session, error) {
+	s.logger.DebugContext(ctx, "Create")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now().UTC()
+	session.LastSeenAt = session.CreatedAt
+	session.LastSeenIP = session.IP
+	if _, err := s.coll.InsertOne(ctx, session); err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.Session{}, err
+	}
+	s.logger.InfoContext(ctx, "Create", "detail", fmt.Sprintf("Session %v started for %v", session.ID.Hex(), session.UserEmail))
+
+	return session, nil
+}
+
+// Get returns the session id, revoked and expired sessions included
+func (s *SessionStore) Get(ctx context.Context, id string) (models.Session, error) {
+	s.logger.DebugContext(ctx, "Get")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	var session models.Session
+	err = s.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Get", "error", err)
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// GetActive lists the sessions of email active at now, the most recently
+// seen first
+func (s *SessionStore) GetActive(ctx context.Context, email string, now time.Time) ([]models.Session, error) {
+	s.logger.DebugContext(ctx, "GetActive")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"email": email, "revokedAt": bson.M{"$exists": false}, "expiresAt": bson.M{"$gt": now.UTC()}}
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	sessions := []models.Session{}
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetActive", "error", err)
+		return []models.Session{}, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &sessions); err != nil {
+		s.logger.ErrorContext(ctx, "GetActive", "error", err)
+		return []models.Session{}, err
+	}
+	return sessions, nil
+}
+
+// Touch records activity of the session id from ip at now. A session seen
+// again from the same address within SessionTouchInterval is not written,
+// so busy sessions do not write on every request.
+func (s *SessionStore) Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error {
+	s.logger.DebugContext(ctx, "Touch")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"lastSeenAt": bson.M{"$lte": now.Add(-SessionTouchInterval)}},
+		bson.M{"lastSeenIP": bson.M{"$ne": ip}},
+	}}
+	update := bson.M{"$set": bson.M{"lastSeenAt": now.UTC(), "lastSeenIP": ip}}
+	if _, err := s.coll.UpdateOne(ctx, filter, update); err != nil {
+		s.logger.ErrorContext(ctx, "Touch", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Extend keeps the active session id alive until expiresAt, its refresh
+// token was rotated from ip at now
+func (s *SessionStore) Extend(ctx context.Context, id primitive.ObjectID, ip string, now, expiresAt time.Time) error {
+	s.logger.DebugContext(ctx, "Extend")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"lastSeenAt": now.UTC(), "lastSeenIP": ip, "expiresAt": expiresAt.UTC()}}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Extend", "error", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.ErrSessionRevoked
+	}
+	return nil
+}
+
+// Revoke revokes the session id of email, revoking a revoked session keeps
+// its first revocation
+func (s *SessionStore) Revoke(ctx context.Context, email, id string) (models.Session, error) {
+	s.logger.DebugContext(ctx, "Revoke")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	filter := bson.M{"_id": objId, "email": email, "revokedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}}
+	if _, err := s.coll.UpdateOne(ctx, filter, update); err != nil {
+		s.logger.ErrorContext(ctx, "Revoke", "error", err)
+		return models.Session{}, err
+	}
+	var session models.Session
+	err = s.coll.FindOne(ctx, bson.M{"_id": objId, "email": email}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return models.Session{}, errors.ErrSessionNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Revoke", "error", err)
+		return models.Session{}, err
+	}
+	s.logger.InfoContext(ctx, "Revoke", "detail", fmt.Sprintf("Session %v of %v revoked", id, email))
+
+	return session, nil
+}
+
+// RevokeAll revokes every session of email but except, and returns how many
+// it revoked. An empty except revokes them all.
+func (s *SessionStore) RevokeAll(ctx context.Context, email, except string) (int, error) {
+	s.logger.DebugContext(ctx, "RevokeAll")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{"email": email, "revokedAt": bson.M{"$exists": false}}
+	if except != "" {
+		objId, _ := primitive.ObjectIDFromHex(except)
+		filter["_id"] = bson.M{"$ne": objId}
+	}
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}}
+	res, err := s.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "RevokeAll", "error", err)
+		return 0, err
+	}
+	s.logger.InfoContext(ctx, "RevokeAll", "detail", fmt.Sprintf("%d sessions of %v revoked", res.ModifiedCount, email))
+
+	return int(res.ModifiedCount), nil
+}
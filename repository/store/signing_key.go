@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SigningKeyStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewSigningKeyStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *SigningKeyStore {
+	return &SigningKeyStore{coll: c, timeout: timeout, logger: l.With("store", "signing_key")}
+}
+
+// Create stores key, a key with its ID created by another instance wins
+func (s *SigningKeyStore) Create(ctx context.Context, key models.SigningKey) error {
+	s.logger.DebugContext(ctx, "Create")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err := s.coll.InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return keyring.ErrKeyExists
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return err
+	}
+	s.logger.InfoContext(ctx, "Create", "detail", "signing key created", "kid", key.ID, "active_at", key.ActiveAt)
+	return nil
+}
+
+// GetValid lists the keys still verifying at now
+func (s *SigningKeyStore) GetValid(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	s.logger.DebugContext(ctx, "GetValid")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	keys := []models.SigningKey{}
+	cur, err := s.coll.Find(ctx, bson.M{"verify_until": bson.M{"$gt": now}})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "GetValid", "error", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &keys); err != nil {
+		s.logger.ErrorContext(ctx, "GetValid", "error", err)
+		return nil, err
+	}
+	return keys, nil
+}
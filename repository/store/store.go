@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/ratelimit"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TourRepository is implemented by TourStore and by the in-memory store
+type TourRepository interface {
+	CreateTour(ctx context.Context, tour models.Tour) (models.Tour, error)
+	DeleteTour(ctx context.Context, id string) (bool, error)
+	Get(ctx context.Context, id string) (models.Tour, error)
+	GetAll(ctx context.Context, page, limit int) ([]models.Tour, int, error)
+	Update(ctx context.Context, id string, updated models.Tour) (models.Tour, error)
+	SearchTour(ctx context.Context, city string, distance float32, maxgroupsize int) ([]models.Tour, error)
+	FeaturedTour(ctx context.Context) ([]models.Tour, error)
+	CountTours(ctx context.Context) (int, error)
+}
+
+// UserRepository is implemented by UserStore and by the in-memory store
+type UserRepository interface {
+	CreaterUser(ctx context.Context, usr models.User) (models.User, error)
+	GetAll(ctx context.Context, page, limit int) ([]models.User, int, error)
+	Get(ctx context.Context, email string) (models.User, error)
+	Delete(ctx context.Context, email string) (bool, error)
+	UpdateUser(ctx context.Context, email string, usr models.UserUpdate) (models.User, error)
+	UpdateRole(ctx context.Context, email, role string) (models.User, error)
+	CountUser(ctx context.Context) (int, error)
+	GetByID(ctx context.Context, id string) (models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	SetPassword(ctx context.Context, email, password string) error
+	MarkVerified(ctx context.Context, email string) (models.User, error)
+}
+
+// BookingRepository is implemented by BookingStore and by the in-memory store
+type BookingRepository interface {
+	GetBooking(ctx context.Context, id string) (models.Booking, error)
+	CreateBooking(ctx context.Context, userId string, book models.Booking) (models.Booking, error)
+	Transition(ctx context.Context, id string, to models.BookingStatus, actor, note string) (models.Booking, error)
+	ExpireHolds(ctx context.Context, now time.Time) (int, error)
+	Update(ctx context.Context, id string, bookUpdate models.UpdateBooking) (models.Booking, error)
+	GetAll(ctx context.Context, page, limit int) ([]models.Booking, int, error)
+	GetByEmail(ctx context.Context, email string, page, limit int) ([]models.Booking, int, error)
+	Count(ctx context.Context) (int, error)
+}
+
+// ReviewRepository is implemented by ReviewStore and by the in-memory store
+type ReviewRepository interface {
+	CreateReviw(ctx context.Context, tourId string, review models.Review) (models.Review, error)
+	GetAll(ctx context.Context, page, limit int) ([]models.Review, int, error)
+	CountReviews(ctx context.Context) (int, error)
+	GetOne(ctx context.Context, id string) (models.Review, error)
+	Delete(ctx context.Context, tourId, reviewId string) (bool, error)
+}
+
+// DepartureRepository is implemented by DepartureStore and by the in-memory store
+type DepartureRepository interface {
+	Create(ctx context.Context, tourId string, dep models.Departure) (models.Departure, error)
+	Get(ctx context.Context, id string) (models.Departure, error)
+	GetByTour(ctx context.Context, tourId string) ([]models.Departure, error)
+	Update(ctx context.Context, id string, upd models.UpdateDeparture) (models.Departure, error)
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// PaymentRepository is implemented by PaymentStore and by the in-memory store
+type PaymentRepository interface {
+	Create(ctx context.Context, payment models.Payment) (models.Payment, error)
+	Get(ctx context.Context, id string) (models.Payment, error)
+	GetByIntent(ctx context.Context, intentId string) (models.Payment, error)
+	GetSucceededByBooking(ctx context.Context, bookingId primitive.ObjectID) (models.Payment, error)
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, from, to models.PaymentStatus, reason string) (bool, error)
+	SetRefundPending(ctx context.Context, id primitive.ObjectID, amount float64) error
+	ClaimRefund(ctx context.Context, id primitive.ObjectID, now time.Time, lease time.Duration) (models.Payment, bool, error)
+	AddRefund(ctx context.Context, id primitive.ObjectID, claim string, amount float64, fully bool) (bool, error)
+	ReleaseRefund(ctx context.Context, id primitive.ObjectID, claim, reason string) error
+	GetRefundPending(ctx context.Context) ([]models.Payment, error)
+}
+
+// RefreshTokenRepository is implemented by RefreshTokenStore and by the in-memory store
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token models.RefreshToken) (models.RefreshToken, error)
+	GetByHash(ctx context.Context, hash string) (models.RefreshToken, error)
+	Rotate(ctx context.Context, oldID primitive.ObjectID, next models.RefreshToken) (models.RefreshToken, error)
+	RevokeFamily(ctx context.Context, family string) error
+	RevokeUser(ctx context.Context, email string) error
+}
+
+// UserTokenRepository is implemented by UserTokenStore and by the in-memory store
+type UserTokenRepository interface {
+	Create(ctx context.Context, token models.UserToken) (models.UserToken, error)
+	Consume(ctx context.Context, purpose, hash string, now time.Time) (models.UserToken, error)
+}
+
+// MFARepository is implemented by MFAStore and by the in-memory store
+type MFARepository interface {
+	Get(ctx context.Context, email string) (models.MFA, error)
+	Enroll(ctx context.Context, mfa models.MFA) (models.MFA, error)
+	Enable(ctx context.Context, email string, step int64, recoveryCodes []string) (models.MFA, error)
+	UseStep(ctx context.Context, email string, step int64) error
+	UseRecoveryCode(ctx context.Context, email, hash string) (models.MFA, error)
+	SetRecoveryCodes(ctx context.Context, email string, recoveryCodes []string) error
+	Delete(ctx context.Context, email string) error
+}
+
+// SigningKeyRepository is implemented by SigningKeyStore and by the in-memory store
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key models.SigningKey) error
+	GetValid(ctx context.Context, now time.Time) ([]models.SigningKey, error)
+}
+
+// APIKeyRepository is implemented by APIKeyStore and by the in-memory store
+type APIKeyRepository interface {
+	Create(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	Get(ctx context.Context, id string) (models.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (models.APIKey, error)
+	GetAll(ctx context.Context, page, limit int) ([]models.APIKey, int, error)
+	Revoke(ctx context.Context, id string) (models.APIKey, error)
+	Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error
+}
+
+// IdentityRepository is implemented by IdentityStore and by the in-memory store
+type IdentityRepository interface {
+	Get(ctx context.Context, provider, subject string) (models.Identity, error)
+	Link(ctx context.Context, identity models.Identity, now time.Time) (models.Identity, error)
+	GetByEmail(ctx context.Context, email string) ([]models.Identity, error)
+}
+
+// OIDCStateRepository is implemented by OIDCStateStore and by the in-memory store
+type OIDCStateRepository interface {
+	Create(ctx context.Context, state models.OIDCState) error
+	Consume(ctx context.Context, hash string, now time.Time) (models.OIDCState, error)
+}
+
+// SessionRepository is implemented by SessionStore and by the in-memory store
+type SessionRepository interface {
+	Create(ctx context.Context, session models.Session) (models.Session, error)
+	Get(ctx context.Context, id string) (models.Session, error)
+	GetActive(ctx context.Context, email string, now time.Time) ([]models.Session, error)
+	Touch(ctx context.Context, id primitive.ObjectID, ip string, now time.Time) error
+	Extend(ctx context.Context, id primitive.ObjectID, ip string, now, expiresAt time.Time) error
+	Revoke(ctx context.Context, email, id string) (models.Session, error)
+	RevokeAll(ctx context.Context, email, except string) (int, error)
+}
+
+// RateLimitRepository is implemented by RateLimitStore and by ratelimit.Memory
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error)
+}
+
+// LoginAttemptRepository is implemented by LoginAttemptStore and by the in-memory store
+type LoginAttemptRepository interface {
+	Record(ctx context.Context, attempt models.LoginAttempt) (models.LoginAttempt, error)
+	GetByEmail(ctx context.Context, email string, page, limit int) ([]models.LoginAttempt, int, error)
+	SuccessfulIPs(ctx context.Context, email string) ([]string, error)
+}
+
+// LoginLockRepository is implemented by LoginLockStore and by the in-memory store
+type LoginLockRepository interface {
+	Get(ctx context.Context, key string, now time.Time) (models.LoginLock, error)
+	Fail(ctx context.Context, key string, now time.Time, forgetAfter time.Duration) (models.LoginLock, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Collection names of the MongoDB stores
+const (
+	TourCollection         = "Tour"
+	UserCollection         = "User"
+	BookingCollection      = "Booking"
+	ReviewCollection       = "Reviews"
+	DepartureCollection    = "Departure"
+	PaymentCollection      = "Payment"
+	RefreshTokenCollection = "RefreshToken"
+	RateLimitCollection    = "RateLimit"
+	LoginAttemptCollection = "LoginAttempt"
+	LoginLockCollection    = "LoginLock"
+	UserTokenCollection    = "UserToken"
+	MFACollection          = "MFA"
+	SigningKeyCollection   = "SigningKey"
+	APIKeyCollection       = "APIKey"
+	IdentityCollection     = "Identity"
+	OIDCStateCollection    = "OIDCState"
+	SessionCollection      = "Session"
+)
+
+// Stores groups every repository the HTTP layer depends on
+type Stores struct {
+	Tours         TourRepository
+	Users         UserRepository
+	Bookings      BookingRepository
+	Reviews       ReviewRepository
+	Departures    DepartureRepository
+	Payments      PaymentRepository
+	RefreshTokens RefreshTokenRepository
+	RateLimits    RateLimitRepository
+	LoginAttempts LoginAttemptRepository
+	LoginLocks    LoginLockRepository
+	UserTokens    UserTokenRepository
+	MFA           MFARepository
+	SigningKeys   SigningKeyRepository
+	APIKeys       APIKeyRepository
+	Identities    IdentityRepository
+	OIDCStates    OIDCStateRepository
+	Sessions      SessionRepository
+}
+
+// NewMongoStores returns the MongoDB backed repositories of db
+func NewMongoStores(db *mongo.Database, cfg *config.Config, l *slog.Logger) Stores {
+	timeout := cfg.Mongo.QueryTimeout
+	tourColl := db.Collection(TourCollection)
+	userColl := db.Collection(UserCollection)
+	bookingColl := db.Collection(BookingCollection)
+	reviewColl := db.Collection(ReviewCollection)
+	departureColl := db.Collection(DepartureCollection)
+	paymentColl := db.Collection(PaymentCollection)
+	tokenColl := db.Collection(RefreshTokenCollection)
+	rateLimitColl := db.Collection(RateLimitCollection)
+	attemptColl := db.Collection(LoginAttemptCollection)
+	lockColl := db.Collection(LoginLockCollection)
+	userTokenColl := db.Collection(UserTokenCollection)
+	mfaColl := db.Collection(MFACollection)
+	signingKeyColl := db.Collection(SigningKeyCollection)
+	apiKeyColl := db.Collection(APIKeyCollection)
+	identityColl := db.Collection(IdentityCollection)
+	oidcStateColl := db.Collection(OIDCStateCollection)
+	sessionColl := db.Collection(SessionCollection)
+	return Stores{
+		Tours:         NewTourStore(*tourColl, timeout, l),
+		Users:         NewUserStore(*userColl, timeout, l),
+		Bookings:      NewBookStore(*bookingColl, *userColl, *departureColl, *tourColl, cfg.Booking.HoldWindow(), timeout, l),
+		Reviews:       NewReviewStore(*reviewColl, *tourColl, timeout, l),
+		Departures:    NewDepartureStore(*departureColl, *tourColl, timeout, l),
+		Payments:      NewPaymentStore(*paymentColl, timeout, l),
+		RefreshTokens: NewRefreshTokenStore(*tokenColl, timeout, l),
+		RateLimits:    NewRateLimitStore(*rateLimitColl, timeout, l),
+		LoginAttempts: NewLoginAttemptStore(*attemptColl, timeout, l),
+		LoginLocks:    NewLoginLockStore(*lockColl, timeout, l),
+		UserTokens:    NewUserTokenStore(*userTokenColl, timeout, l),
+		MFA:           NewMFAStore(*mfaColl, timeout, l),
+		SigningKeys:   NewSigningKeyStore(*signingKeyColl, timeout, l),
+		APIKeys:       NewAPIKeyStore(*apiKeyColl, timeout, l),
+		Identities:    NewIdentityStore(*identityColl, timeout, l),
+		OIDCStates:    NewOIDCStateStore(*oidcStateColl, timeout, l),
+		Sessions:      NewSessionStore(*sessionColl, timeout, l),
+	}
+}
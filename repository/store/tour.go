@@ -15,23 +15,27 @@ import (
 )
 
 type TourStore struct {
-	coll   mongo.Collection
-	logger *slog.Logger
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
 }
 
-func NewTourStore(c mongo.Collection, l *slog.Logger) *TourStore {
-	return &TourStore{coll: c, logger: l}
+func NewTourStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *TourStore {
+	return &TourStore{coll: c, timeout: timeout, logger: l.With("store", "tour")}
 }
-func (t *TourStore) CreateTour(tour models.Tour) (models.Tour, error) {
-	t.logger.Info("repository", "CreateTour", "Tour")
+func (t *TourStore) CreateTour(ctx context.Context, tour models.Tour) (models.Tour, error) {
+	t.logger.DebugContext(ctx, "CreateTour")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 	tour.ID = primitive.NewObjectID()
 	tour.CreatedAt = time.Now().UTC()
 	tour.UpdatedAt = time.Now().UTC()
 
 	_, err := t.coll.InsertOne(ctx, tour)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Tour{}, errors.ErrTourExists.Wrap(err)
+	}
 	if err != nil {
 		return models.Tour{}, err
 	}
@@ -39,7 +43,7 @@ func (t *TourStore) CreateTour(tour models.Tour) (models.Tour, error) {
 	return tour, nil
 }
 func (t *TourStore) IsExist(ctx context.Context, filter primitive.M) (bool, error) {
-	t.logger.Info("repository", "IsExist", "Tour")
+	t.logger.DebugContext(ctx, "IsExist")
 
 	count, err := t.coll.CountDocuments(ctx, filter)
 	if err != nil {
@@ -54,10 +58,10 @@ func (t *TourStore) IsExist(ctx context.Context, filter primitive.M) (bool, erro
 
 }
 
-func (t *TourStore) DeleteTour(_id string) (bool, error) {
-	t.logger.Info("repository", "DeleteTour", "Tour")
+func (t *TourStore) DeleteTour(ctx context.Context, _id string) (bool, error) {
+	t.logger.DebugContext(ctx, "DeleteTour")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 	objId, _ := primitive.ObjectIDFromHex(_id)
 	filter := bson.M{"_id": objId}
@@ -66,7 +70,7 @@ func (t *TourStore) DeleteTour(_id string) (bool, error) {
 		return false, err
 	}
 	if !ok {
-		return false, errors.ErrUserNotFound
+		return false, errors.ErrTourNotFound
 	}
 	_, err = t.coll.DeleteOne(ctx, bson.M{"_id": objId})
 	if err != nil {
@@ -75,10 +79,10 @@ func (t *TourStore) DeleteTour(_id string) (bool, error) {
 	return true, nil
 }
 
-func (t *TourStore) Get(id string) (models.Tour, error) {
-	t.logger.Info("repository", "Get", "Tour")
+func (t *TourStore) Get(ctx context.Context, id string) (models.Tour, error) {
+	t.logger.DebugContext(ctx, "Get")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 	var tour models.Tour
 	objId, _ := primitive.ObjectIDFromHex(id)
@@ -88,7 +92,7 @@ func (t *TourStore) Get(id string) (models.Tour, error) {
 		return models.Tour{}, err
 	}
 	if !ok {
-		return models.Tour{}, errors.ErrUserNotFound
+		return models.Tour{}, errors.ErrTourNotFound
 	}
 	if err := t.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&tour); err != nil {
 		return models.Tour{}, err
@@ -97,11 +101,11 @@ func (t *TourStore) Get(id string) (models.Tour, error) {
 
 }
 
-func (t *TourStore) GetAll(page, limit int) ([]models.Tour, int, error) {
-	t.logger.Info("repository", "GetAll", "Tour")
+func (t *TourStore) GetAll(ctx context.Context, page, limit int) ([]models.Tour, int, error) {
+	t.logger.DebugContext(ctx, "GetAll")
 
 	tours := []models.Tour{}
-	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 	skip := (page - 1) * limit
 
@@ -125,10 +129,10 @@ func (t *TourStore) GetAll(page, limit int) ([]models.Tour, int, error) {
 	return tours, int(count), nil
 }
 
-func (t *TourStore) Update(_id string, updated models.Tour) (models.Tour, error) {
-	t.logger.Info("repository", "Update", "Tour")
+func (t *TourStore) Update(ctx context.Context, _id string, updated models.Tour) (models.Tour, error) {
+	t.logger.DebugContext(ctx, "Update")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 	objId, _ := primitive.ObjectIDFromHex(_id)
 	filter := bson.M{"_id": objId}
@@ -137,31 +141,38 @@ func (t *TourStore) Update(_id string, updated models.Tour) (models.Tour, error)
 		return models.Tour{}, err
 	}
 	if !ok {
-		return models.Tour{}, errors.ErrUserNotFound
+		return models.Tour{}, errors.ErrTourNotFound
 	} else {
 		updated := bson.M{
-			"title":        updated.Title,
-			"city":         updated.City,
-			"address":      updated.Address,
-			"photo":        updated.Photo,
-			"desc":         updated.Description,
-			"price":        updated.Price,
-			"maxGroupSize": updated.MaxGroupSize,
-			"updatedAt":    time.Now().UTC(),
+			"title":               updated.Title,
+			"city":                updated.City,
+			"address":             updated.Address,
+			"distance":            updated.Distance,
+			"featured":            updated.Featured,
+			"photo":               updated.Photo,
+			"desc":                updated.Description,
+			"price":               updated.Price,
+			"maxGroupSize":        updated.MaxGroupSize,
+			"cancellation_policy": updated.CancellationPolicy,
+			"location":            updated.Location,
+			"updatedAt":           time.Now().UTC(),
 		}
 
 		_, err := t.coll.UpdateOne(ctx, bson.M{"_id": objId}, bson.M{"$set": updated})
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Tour{}, errors.ErrTourExists.Wrap(err)
+		}
 		if err != nil {
 			return models.Tour{}, err
 		}
-		return t.Get(_id)
+		return t.Get(ctx, _id)
 	}
 }
 
-func (t *TourStore) SearchTour(city string, distance float32, maxgroupsize int) ([]models.Tour, error) {
-	t.logger.Info("repository", "SearchTour", "Tour")
+func (t *TourStore) SearchTour(ctx context.Context, city string, distance float32, maxgroupsize int) ([]models.Tour, error) {
+	t.logger.DebugContext(ctx, "SearchTour")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 
 	filter := bson.M{"city": city, "distance": bson.M{"$gte": distance}, "maxGroupSize": bson.M{"$gte": maxgroupsize}}
@@ -184,24 +195,24 @@ func (t *TourStore) SearchTour(city string, distance float32, maxgroupsize int)
 	return tours, nil
 }
 
-func (t *TourStore) FeaturedTour() ([]models.Tour, error) {
-	t.logger.Info("repository", "FeaturedTour", "Tour")
+func (t *TourStore) FeaturedTour(ctx context.Context) ([]models.Tour, error) {
+	t.logger.DebugContext(ctx, "FeaturedTour")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
 	defer cancle()
 	filter := bson.M{"featured": true}
 	ok, err := t.IsExist(ctx, filter)
 	if err != nil {
-		t.logger.Error("repository", "FeaturedTour", err.Error())
+		t.logger.ErrorContext(ctx, "FeaturedTour", "error", err)
 		return []models.Tour{}, err
 	}
 	if !ok {
-		t.logger.Error("repository", "FeaturedTour", fmt.Sprintf("%v is not Found", filter))
-		return []models.Tour{}, errors.ErrUserNotFound
+		t.logger.InfoContext(ctx, "FeaturedTour", "detail", fmt.Sprintf("%v is not Found", filter))
+		return []models.Tour{}, nil
 	}
 	cur, err := t.coll.Find(ctx, filter)
 	if err != nil {
-		t.logger.Error("repository", "FeaturedTour", err.Error())
+		t.logger.ErrorContext(ctx, "FeaturedTour", "error", err)
 		return []models.Tour{}, err
 	}
 	tours := []models.Tour{}
@@ -209,7 +220,7 @@ func (t *TourStore) FeaturedTour() ([]models.Tour, error) {
 	for cur.Next(ctx) {
 		tour := models.Tour{}
 		if err := cur.Decode(&tour); err != nil {
-			t.logger.Error("repository", "FeaturedTour", fmt.Sprintf("Could not Decode the %v, err : %v", tour, err.Error()))
+			t.logger.ErrorContext(ctx, "FeaturedTour", "detail", fmt.Sprintf("Could not Decode the %v, err : %v", tour, err.Error()))
 
 			continue
 		}
@@ -217,22 +228,26 @@ func (t *TourStore) FeaturedTour() ([]models.Tour, error) {
 	}
 
 	if cur.Err() != nil {
-		t.logger.Error("repository", "FeaturedTour", cur.Err())
+		t.logger.ErrorContext(ctx, "FeaturedTour", "error", cur.Err())
 		return []models.Tour{}, err
 	}
 	return tours, nil
 
 }
 
-func (t *TourStore) CountTours() (int, error) {
-	t.logger.Info("repository", "CountTours", "Tour")
+func (t *TourStore) CountTours(ctx context.Context) (int, error) {
+	t.logger.DebugContext(ctx, "CountTours")
 
+	ctx, cancle := context.WithTimeout(ctx, t.timeout)
+	defer cancle()
 	opts := options.Count().SetHint("_id_")
-	count, err := t.coll.CountDocuments(context.TODO(), bson.D{}, opts)
+	count, err := t.coll.CountDocuments(ctx, bson.D{}, opts)
 	if err != nil {
-		t.logger.Error("repository", "CountTours", err.Error())
+		t.logger.ErrorContext(ctx, "CountTours", "error", err)
 
 		return 0, err
 	}
+	t.logger.DebugContext(ctx, "CountTours", "detail", fmt.Sprintf("Found %d Documents", count))
+
 	return int(count), nil
 }
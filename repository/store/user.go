@@ -8,6 +8,7 @@ import (
 
 	"github.com/tabed23/travel-api/models"
 	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -15,45 +16,49 @@ import (
 )
 
 type UserStore struct {
-	coll   mongo.Collection
-	logger *slog.Logger
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
 }
 
-func NewUserStore(c mongo.Collection, l *slog.Logger) *UserStore {
-	return &UserStore{coll: c, logger: l}
+func NewUserStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *UserStore {
+	return &UserStore{coll: c, timeout: timeout, logger: l.With("store", "user")}
 }
 
 // Create User Document
-func (u *UserStore) CreaterUser(usr models.User) (models.User, error) {
-	u.logger.Info("repository", "CreaterUser", "User")
+func (u *UserStore) CreaterUser(ctx context.Context, usr models.User) (models.User, error) {
+	u.logger.DebugContext(ctx, "CreaterUser")
 
 	usr.ID = primitive.NewObjectID()
 	usr.CreatedAt = time.Now().UTC()
 	usr.UpdatedAt = time.Now().UTC()
 	hash, err := utils.EnscryptPassword(usr.Password)
 	if err != nil {
-		u.logger.Error("repository", "CreaterUser", err.Error())
+		u.logger.ErrorContext(ctx, "CreaterUser", "error", err)
 		return models.User{}, err
 	}
 	usr.Password = hash
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	_, err = u.coll.InsertOne(ctx, &usr)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.User{}, errors.ErrUserExists.Wrap(err)
+	}
 	if err != nil {
-		u.logger.Error("repository", "CreaterUser", err.Error())
+		u.logger.ErrorContext(ctx, "CreaterUser", "error", err)
 
 		return models.User{}, err
 	}
-	u.logger.Info("repository", "CreaterUser", fmt.Sprintf("User created %v", usr))
+	u.logger.InfoContext(ctx, "CreaterUser", "detail", fmt.Sprintf("User created %v", usr.Email))
 
 	return usr, nil
 }
 
 // Get All Users Document
-func (u *UserStore) GetAll(page, limit int) ([]models.User, int, error) {
-	u.logger.Info("repository", "GetAll", "User")
+func (u *UserStore) GetAll(ctx context.Context, page, limit int) ([]models.User, int, error) {
+	u.logger.DebugContext(ctx, "GetAll")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	users := []models.User{}
 	skip := (page - 1) * limit
@@ -61,14 +66,14 @@ func (u *UserStore) GetAll(page, limit int) ([]models.User, int, error) {
 	opts := options.Find().SetLimit(int64(limit)).SetSkip(int64(skip))
 	cur, err := u.coll.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		u.logger.Error("repository", "GetAll", err.Error())
+		u.logger.ErrorContext(ctx, "GetAll", "error", err)
 
 		return nil, 0, err
 	}
 	for cur.Next(ctx) {
 		var user models.User
 		if err := cur.Decode(&user); err != nil {
-			u.logger.Error("repository", "GetAll", err.Error())
+			u.logger.ErrorContext(ctx, "GetAll", "error", err)
 
 			return nil, 0, err
 		}
@@ -76,7 +81,7 @@ func (u *UserStore) GetAll(page, limit int) ([]models.User, int, error) {
 	}
 	count, err := u.coll.CountDocuments(ctx, bson.M{})
 	if err != nil {
-		u.logger.Error("repository", "GetAll", err.Error())
+		u.logger.ErrorContext(ctx, "GetAll", "error", err)
 		return nil, 0, err
 	}
 
@@ -84,104 +89,195 @@ func (u *UserStore) GetAll(page, limit int) ([]models.User, int, error) {
 }
 
 // Get Document
-func (u UserStore) Get(Email string) (models.User, error) {
-	u.logger.Info("repository", "Get", "User")
+func (u UserStore) Get(ctx context.Context, Email string) (models.User, error) {
+	u.logger.DebugContext(ctx, "Get")
 
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	var usr models.User
 	if err := u.coll.FindOne(ctx, bson.M{"email": Email}).Decode(&usr); err != nil {
-		u.logger.Error("repository", "Get", err.Error())
+		u.logger.ErrorContext(ctx, "Get", "error", err)
+		if err == mongo.ErrNoDocuments {
+			return models.User{}, errors.ErrUserNotFound
+		}
 
 		return models.User{}, err
 	}
-	u.logger.Info("repository", "Get", fmt.Sprintf("User Found %v", usr))
+	u.logger.DebugContext(ctx, "Get", "detail", fmt.Sprintf("User Found %v", usr.Email))
 	return usr, nil
 
 }
 
 // Delete User Document
-func (u UserStore) Delete(Email string) (bool, error) {
-	u.logger.Info("repository", "Delete", "User")
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+func (u UserStore) Delete(ctx context.Context, Email string) (bool, error) {
+	u.logger.DebugContext(ctx, "Delete")
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	_, err := u.coll.DeleteOne(ctx, bson.M{"email": Email})
 	if err != nil {
-		u.logger.Info("repository", "Delete", err.Error())
+		u.logger.InfoContext(ctx, "Delete", "error", err)
 
 		return false, err
 	}
 
-	u.logger.Info("repository", "Delete", fmt.Sprintf("User with %v Delete", Email))
+	u.logger.InfoContext(ctx, "Delete", "detail", fmt.Sprintf("User with %v Delete", Email))
 
 	return true, nil
 }
 
 // Update the User Document
-func (u UserStore) UpdateUser(email string, usr models.UserUpdate) (models.User, error) {
-	u.logger.Info("repository", "Update", "User")
-
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
-	var hashPass string
-	if usr.Password != "" {
-		hashPass, _ = utils.EnscryptPassword(usr.Password)
-	}
+func (u UserStore) UpdateUser(ctx context.Context, email string, usr models.UserUpdate) (models.User, error) {
+	u.logger.DebugContext(ctx, "Update")
 
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	update := bson.M{
 		"first_name": usr.FirstName,
 		"last_name":  usr.Lastname,
-		"Password":   hashPass,
-		"role":       usr.Role,
 		"photo":      usr.Photo,
 		"updatedAt":  time.Now().UTC(),
 	}
+	if usr.Password != "" {
+		hashPass, err := utils.EnscryptPassword(usr.Password)
+		if err != nil {
+			u.logger.ErrorContext(ctx, "UpdateUser", "error", err)
+			return models.User{}, err
+		}
+		update["password"] = hashPass
+	}
 	_, err := u.coll.UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": update})
 	if err != nil {
-		u.logger.Error("repository", "UpdateUser", err.Error())
+		u.logger.ErrorContext(ctx, "UpdateUser", "error", err)
+		return models.User{}, err
+	}
+	u.logger.InfoContext(ctx, "UpdateUser", "detail", fmt.Sprintf("user %v has been updated", email))
+
+	return u.Get(ctx, email)
+
+}
+
+// Update the role of a User Document
+func (u UserStore) UpdateRole(ctx context.Context, email, role string) (models.User, error) {
+	u.logger.DebugContext(ctx, "UpdateRole")
+
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
+	defer cancle()
+	update := bson.M{
+		"role":      role,
+		"updatedAt": time.Now().UTC(),
+	}
+	res, err := u.coll.UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": update})
+	if err != nil {
+		u.logger.ErrorContext(ctx, "UpdateRole", "error", err)
 		return models.User{}, err
 	}
-	u.logger.Info("repository", "UpdateUser", fmt.Sprintf("user %v has been updated", email))
+	if res.MatchedCount == 0 {
+		u.logger.WarnContext(ctx, "UpdateRole", "detail", fmt.Sprintf("user %v not found", email))
+		return models.User{}, errors.ErrUserNotFound
+	}
+	u.logger.InfoContext(ctx, "UpdateRole", "detail", fmt.Sprintf("user %v is now %v", email, role))
+
+	return u.Get(ctx, email)
+}
+
+// SetPassword replaces the password of a User Document
+func (u UserStore) SetPassword(ctx context.Context, email, password string) error {
+	u.logger.DebugContext(ctx, "SetPassword")
+
+	hashPass, err := utils.EnscryptPassword(password)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "SetPassword", "error", err)
+		return err
+	}
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
+	defer cancle()
+	update := bson.M{"$set": bson.M{"password": hashPass, "updatedAt": time.Now().UTC()}}
+	res, err := u.coll.UpdateOne(ctx, bson.M{"email": email}, update)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "SetPassword", "error", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.ErrUserNotFound
+	}
+	return nil
+}
+
+// MarkVerified clears the unverified flag of a User Document
+func (u UserStore) MarkVerified(ctx context.Context, email string) (models.User, error) {
+	u.logger.DebugContext(ctx, "MarkVerified")
 
-	return u.Get(email)
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
+	defer cancle()
+	update := bson.M{
+		"$unset": bson.M{"unverified": ""},
+		"$set":   bson.M{"updatedAt": time.Now().UTC()},
+	}
+	res, err := u.coll.UpdateOne(ctx, bson.M{"email": email}, update)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "MarkVerified", "error", err)
+		return models.User{}, err
+	}
+	if res.MatchedCount == 0 {
+		return models.User{}, errors.ErrUserNotFound
+	}
 
+	return u.Get(ctx, email)
 }
 
 // Count User Document
-func (u UserStore) CountUser() (int, error) {
-	u.logger.Info("repository", "CountUser", "User")
+func (u UserStore) CountUser(ctx context.Context) (int, error) {
+	u.logger.DebugContext(ctx, "CountUser")
 
-	ctx, cancle := context.WithTimeout(context.TODO(), 10*time.Second)
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	opts := options.Count().SetHint("_id_")
 	count, err := u.coll.CountDocuments(ctx, bson.D{}, opts)
 	if err != nil {
-		u.logger.Error("repository", "CountUser", err.Error())
+		u.logger.ErrorContext(ctx, "CountUser", "error", err)
 
 		return 0, err
 	}
 
-	u.logger.Info("repository", "CountUser", fmt.Sprintf("Total User Count %d", count))
+	u.logger.InfoContext(ctx, "CountUser", "detail", fmt.Sprintf("Total User Count %d", count))
 
 	return int(count), nil
 }
 
-// Get User by Value Document
-func (u *UserStore) GetUserByValue(ctx context.Context, value, data string) (models.User, error) {
-	usr := models.User{}
-	u.coll.FindOne(ctx, bson.M{value: data}).Decode(&u)
+// Get User Document by ID
+func (u *UserStore) GetByID(ctx context.Context, id string) (models.User, error) {
+	u.logger.DebugContext(ctx, "GetByID")
+
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
+	defer cancle()
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "GetByID", "error", err)
+		return models.User{}, errors.ErrUserNotFound
+	}
+	var usr models.User
+	if err := u.coll.FindOne(ctx, bson.M{"_id": objId}).Decode(&usr); err != nil {
+		u.logger.ErrorContext(ctx, "GetByID", "error", err)
+		if err == mongo.ErrNoDocuments {
+			return models.User{}, errors.ErrUserNotFound
+		}
+		return models.User{}, err
+	}
 	return usr, nil
 }
 
 // Get User By Email Document
-func (u *UserStore) GetUserByEmail(email string) (models.User, error) {
-	ctx, cancle := context.WithTimeout(context.Background(), 10*time.Second)
+func (u *UserStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	ctx, cancle := context.WithTimeout(ctx, u.timeout)
 	defer cancle()
 	var user models.User
 	if err := u.coll.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
-		u.logger.Error("repository", "GetUserByEmail", err.Error())
+		u.logger.ErrorContext(ctx, "GetUserByEmail", "error", err)
+		if err == mongo.ErrNoDocuments {
+			return models.User{}, errors.ErrUserNotFound
+		}
 		return models.User{}, err
 	}
-	u.logger.Info("repository", "GetUserByEmail", fmt.Sprintf("Found ther user with email: %v", email))
+	u.logger.InfoContext(ctx, "GetUserByEmail", "detail", fmt.Sprintf("Found ther user with email: %v", email))
 	return user, nil
 }
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserTokenStore struct {
+	coll    mongo.Collection
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewUserTokenStore(c mongo.Collection, timeout time.Duration, l *slog.Logger) *UserTokenStore {
+	return &UserTokenStore{coll: c, timeout: timeout, logger: l.With("store", "user_token")}
+}
+
+// Create stores token and uses up the earlier tokens of its email and
+// purpose, only the link of the latest mail works
+func (s *UserTokenStore) Create(ctx context.Context, token models.UserToken) (models.UserToken, error) {
+	s.logger.DebugContext(ctx, "Create")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now().UTC()
+	filter := bson.M{"email": token.Email, "purpose": token.Purpose, "usedAt": bson.M{"$exists": false}}
+	if _, err := s.coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"usedAt": token.CreatedAt}}); err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.UserToken{}, err
+	}
+	if _, err := s.coll.InsertOne(ctx, token); err != nil {
+		s.logger.ErrorContext(ctx, "Create", "error", err)
+		return models.UserToken{}, err
+	}
+	return token, nil
+}
+
+// Consume uses up the unexpired token of purpose with hash. The token is
+// only consumed if it is still unused, so it can not be used twice.
+func (s *UserTokenStore) Consume(ctx context.Context, purpose, hash string, now time.Time) (models.UserToken, error) {
+	s.logger.DebugContext(ctx, "Consume")
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	filter := bson.M{
+		"token_hash": hash,
+		"purpose":    purpose,
+		"usedAt":     bson.M{"$exists": false},
+		"expiresAt":  bson.M{"$gt": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var token models.UserToken
+	err := s.coll.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}}, opts).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return models.UserToken{}, errors.ErrInvalidAccountToken
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Consume", "error", err)
+		return models.UserToken{}, err
+	}
+	return token, nil
+}
@@ -0,0 +1,152 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+const (
+	verificationSubject  = "Verify your email address"
+	passwordResetSubject = "Reset your password"
+)
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// mailedToken returns the token of the last mail with subject sent to to.
+// Password reset mails are sent in the background, it waits a moment for
+// them.
+func (a *testApp) mailedToken(to, subject string) string {
+	a.t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		messages := a.outbox.Messages()
+		for i := len(messages) - 1; i >= 0; i-- {
+			m := messages[i]
+			if m.To != to || m.Subject != subject {
+				continue
+			}
+			match := linkToken.FindStringSubmatch(m.Body)
+			if match == nil {
+				a.t.Fatalf("mail %q to %s without a link: %s", subject, to, m.Body)
+			}
+			token, err := url.QueryUnescape(match[1])
+			if err != nil {
+				a.t.Fatalf("token of the link %s: %v", match[0], err)
+			}
+			return token
+		}
+	}
+	a.t.Fatalf("no mail %q to %s", subject, to)
+	return ""
+}
+
+// resetToken asks for a password reset of email and returns the mailed token
+func (a *testApp) resetToken(email string) string {
+	a.t.Helper()
+	if status := a.do(http.MethodPost, "/api/v1/auth/password/forgot", "", fiber.Map{"email": email}, nil); status != http.StatusAccepted {
+		a.t.Fatalf("forgot password of %s: status %d, want %d", email, status, http.StatusAccepted)
+	}
+	return a.mailedToken(email, passwordResetSubject)
+}
+
+func (a *testApp) resetPassword(token string) (int, middleware.Problem) {
+	a.t.Helper()
+	var problem middleware.Problem
+	status := a.do(http.MethodPost, "/api/v1/auth/password/reset", "", fiber.Map{"token": token, "password": newPassword}, &problem)
+	return status, problem
+}
+
+func (a *testApp) verify(token string) (int, middleware.Problem) {
+	a.t.Helper()
+	var problem middleware.Problem
+	status := a.do(http.MethodPost, "/api/v1/auth/verify", "", fiber.Map{"token": token}, &problem)
+	return status, problem
+}
+
+func TestResetPasswordSignsSessionsOut(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	phone := a.loginTokens("ada@example.com")
+	laptop := a.loginTokens("ada@example.com")
+
+	token := a.resetToken("ada@example.com")
+	if status, problem := a.resetPassword(token); status != http.StatusOK {
+		t.Fatalf("reset: status %d code %q, want %d", status, problem.Code, http.StatusOK)
+	}
+	a.assertSignedOut("phone", phone)
+	a.assertSignedOut("laptop", laptop)
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": "ada@example.com", "password": testPassword}, nil); status != http.StatusUnauthorized {
+		t.Errorf("login with the old password: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": "ada@example.com", "password": newPassword}, nil); status != http.StatusOK {
+		t.Errorf("login with the new password: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestAccountTokensAreSingleUse(t *testing.T) {
+	a := newTestApp(t)
+	if status := a.register("ada@example.com"); status != http.StatusCreated {
+		t.Fatalf("registering: status %d", status)
+	}
+	verification := a.mailedToken("ada@example.com", verificationSubject)
+	reset := a.resetToken("ada@example.com")
+
+	// a token only works for its purpose
+	if status, problem := a.verify(reset); status != http.StatusBadRequest || problem.Code != "invalid_account_token" {
+		t.Errorf("verifying with the reset token: status %d code %q, want %d invalid_account_token", status, problem.Code, http.StatusBadRequest)
+	}
+	if status, problem := a.resetPassword(verification); status != http.StatusBadRequest || problem.Code != "invalid_account_token" {
+		t.Errorf("resetting with the verification token: status %d code %q, want %d invalid_account_token", status, problem.Code, http.StatusBadRequest)
+	}
+
+	tests := []struct {
+		name  string
+		use   func(string) (int, middleware.Problem)
+		token string
+	}{
+		{"verification", a.verify, verification},
+		{"password reset", a.resetPassword, reset},
+	}
+	for _, tt := range tests {
+		if status, problem := tt.use(tt.token); status != http.StatusOK {
+			t.Errorf("%s: status %d code %q, want %d", tt.name, status, problem.Code, http.StatusOK)
+		}
+		if status, problem := tt.use(tt.token); status != http.StatusBadRequest || problem.Code != "invalid_account_token" {
+			t.Errorf("%s again: status %d code %q, want %d invalid_account_token", tt.name, status, problem.Code, http.StatusBadRequest)
+		}
+	}
+	user, err := a.stores.Users.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil || user.Unverified {
+		t.Errorf("user %+v, %v, want verified", user, err)
+	}
+}
+
+func TestAccountTokensExpire(t *testing.T) {
+	// the tokens expire before they reach the API
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.Account.VerificationTTL = time.Nanosecond
+		cfg.Account.PasswordResetTTL = time.Nanosecond
+	})
+	if status := a.register("ada@example.com"); status != http.StatusCreated {
+		t.Fatalf("registering: status %d", status)
+	}
+
+	if status, problem := a.verify(a.mailedToken("ada@example.com", verificationSubject)); status != http.StatusBadRequest || problem.Code != "invalid_account_token" {
+		t.Errorf("expired verification: status %d code %q, want %d invalid_account_token", status, problem.Code, http.StatusBadRequest)
+	}
+	if status, problem := a.resetPassword(a.resetToken("ada@example.com")); status != http.StatusBadRequest || problem.Code != "invalid_account_token" {
+		t.Errorf("expired password reset: status %d code %q, want %d invalid_account_token", status, problem.Code, http.StatusBadRequest)
+	}
+	user, err := a.stores.Users.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil || !user.Unverified {
+		t.Errorf("user %+v, %v, want still unverified", user, err)
+	}
+}
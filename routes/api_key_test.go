@@ -0,0 +1,162 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// testClientIP is the address requests of app.Test come from
+const testClientIP = "0.0.0.0"
+
+// apiKeyFixture is an admin and a user owning the keys of the tests
+type apiKeyFixture struct {
+	*testApp
+	admin string
+}
+
+func newAPIKeyFixture(t *testing.T, configure ...func(*config.Config)) *apiKeyFixture {
+	t.Helper()
+	a := newTestApp(t, configure...)
+	f := &apiKeyFixture{testApp: a, admin: a.user("admin@example.com", constant.AdminRole)}
+	a.user("ada@example.com", constant.UserRole)
+	return f
+}
+
+// createKey issues a key of ada as the admin and returns the key and its id
+func (f *apiKeyFixture) createKey(body fiber.Map) (string, string) {
+	f.t.Helper()
+	body["owner_email"] = "ada@example.com"
+	if body["name"] == nil {
+		body["name"] = "partner"
+	}
+	var res struct {
+		APIKey string `json:"api_key"`
+		Data   struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if status := f.do(http.MethodPost, "/api/v1/user/api-keys", f.admin, body, &res); status != http.StatusCreated {
+		f.t.Fatalf("creating the key: status %d", status)
+	}
+	return res.APIKey, res.Data.ID
+}
+
+// withKey sends a request with key in the X-API-Key header and returns the
+// status and the problem of a refusal
+func (f *apiKeyFixture) withKey(method, path, key string) (int, middleware.Problem) {
+	f.t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(middleware.APIKeyHeader, key)
+	res, err := f.app.Test(req, -1)
+	if err != nil {
+		f.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	var problem middleware.Problem
+	if res.StatusCode >= http.StatusBadRequest {
+		json.NewDecoder(res.Body).Decode(&problem)
+	}
+	return res.StatusCode, problem
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	f := newAPIKeyFixture(t)
+	key, _ := f.createKey(fiber.Map{"scopes": []string{constant.PermUsersRead}})
+
+	if status, _ := f.withKey(http.MethodGet, "/api/v1/user/me", key); status != http.StatusOK {
+		t.Errorf("route in scope: status %d, want %d", status, http.StatusOK)
+	}
+	status, problem := f.withKey(http.MethodGet, "/api/v1/booking/me", key)
+	if status != http.StatusForbidden || problem.Code != "api_key_scope" {
+		t.Errorf("route out of scope: status %d code %q, want %d api_key_scope", status, problem.Code, http.StatusForbidden)
+	}
+}
+
+func TestAPIKeyScopesAreLimitedToTheOwnerRole(t *testing.T) {
+	f := newAPIKeyFixture(t)
+
+	var problem middleware.Problem
+	status := f.do(http.MethodPost, "/api/v1/user/api-keys", f.admin, fiber.Map{
+		"name":        "too much",
+		"owner_email": "ada@example.com",
+		"scopes":      []string{constant.PermToursWrite},
+	}, &problem)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "scopes[0]" {
+		t.Errorf("errors %+v, want scopes[0] refused", problem.Errors)
+	}
+}
+
+func TestAPIKeyAllowedIPs(t *testing.T) {
+	f := newAPIKeyFixture(t)
+
+	tests := []struct {
+		name    string
+		allowed []string
+		status  int
+	}{
+		{"address", []string{testClientIP}, http.StatusOK},
+		{"range", []string{"10.0.0.0/8", testClientIP + "/32"}, http.StatusOK},
+		{"other address", []string{"192.0.2.1"}, http.StatusForbidden},
+		{"other range", []string{"10.0.0.0/8"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, _ := f.createKey(fiber.Map{"scopes": []string{constant.PermUsersRead}, "allowed_ips": tt.allowed})
+			status, problem := f.withKey(http.MethodGet, "/api/v1/user/me", key)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusForbidden && problem.Code != "api_key_ip_denied" {
+				t.Errorf("code %q, want api_key_ip_denied", problem.Code)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRefusals(t *testing.T) {
+	f := newAPIKeyFixture(t)
+	revoked, id := f.createKey(fiber.Map{"scopes": []string{constant.PermUsersRead}})
+	if status := f.do(http.MethodDelete, "/api/v1/user/api-keys/"+id, f.admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("revoking: status %d", status)
+	}
+	// the API only issues keys expiring in the future
+	expired, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("generating a key: %v", err)
+	}
+	expiredAt := time.Now().Add(-time.Minute)
+	_, err = f.stores.APIKeys.Create(context.Background(), models.APIKey{
+		Name:       "expired",
+		Prefix:     prefix,
+		KeyHash:    utils.HashToken(expired),
+		OwnerEmail: "ada@example.com",
+		Scopes:     []string{constant.PermUsersRead},
+		ExpiresAt:  &expiredAt,
+	})
+	if err != nil {
+		t.Fatalf("storing the expired key: %v", err)
+	}
+
+	for name, key := range map[string]string{"revoked": revoked, "expired": expired, "unknown": "tk_not_a_key"} {
+		t.Run(name, func(t *testing.T) {
+			status, problem := f.withKey(http.MethodGet, "/api/v1/user/me", key)
+			if status != http.StatusUnauthorized || problem.Code != "invalid_api_key" {
+				t.Errorf("status %d code %q, want %d invalid_api_key", status, problem.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
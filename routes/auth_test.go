@@ -0,0 +1,211 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	a := newTestApp(t)
+
+	if status := a.register("ada@example.com"); status != http.StatusCreated {
+		t.Fatalf("register: status %d, want %d", status, http.StatusCreated)
+	}
+	token := a.login("ada@example.com")
+
+	var me struct {
+		User struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		} `json:"user"`
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/me", token, nil, &me); status != http.StatusOK {
+		t.Fatalf("me: status %d, want %d", status, http.StatusOK)
+	}
+	if me.User.Email != "ada@example.com" || me.User.Role != "user" {
+		t.Errorf("me: got %+v, want the registered user", me.User)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	a := newTestApp(t)
+
+	a.register("ada@example.com")
+	var problem middleware.Problem
+	if status := a.do(http.MethodPost, "/api/v1/auth/regiser", "", fiber.Map{
+		"first_name": "Other",
+		"last_name":  "User",
+		"user_name":  "otheruser",
+		"email":      "ada@example.com",
+		"password":   testPassword,
+	}, &problem); status != http.StatusConflict {
+		t.Fatalf("status %d, want %d", status, http.StatusConflict)
+	}
+	if problem.Code != "user_exists" {
+		t.Errorf("code %q, want user_exists", problem.Code)
+	}
+}
+
+func TestLoginRefusesWrongPassword(t *testing.T) {
+	a := newTestApp(t)
+	a.register("ada@example.com")
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{"wrong password", "ada@example.com"},
+		{"unknown email", "bob@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem middleware.Problem
+			status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": tt.email, "password": "not the password"}, &problem)
+			if status != http.StatusUnauthorized {
+				t.Fatalf("status %d, want %d", status, http.StatusUnauthorized)
+			}
+			if problem.Code != "invalid_credentials" {
+				t.Errorf("code %q, want invalid_credentials", problem.Code)
+			}
+		})
+	}
+}
+
+func TestProtectedRouteNeedsToken(t *testing.T) {
+	a := newTestApp(t)
+
+	if status := a.do(http.MethodGet, "/api/v1/user/me", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/me", "not-a-token", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("with a bad token: status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+// tokens are the tokens a login or a refresh answers with
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"session_id"`
+}
+
+// loginTokens signs email in and returns every token of the answer
+func (a *testApp) loginTokens(email string) tokens {
+	a.t.Helper()
+	var res tokens
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": email, "password": testPassword}, &res); status != http.StatusOK {
+		a.t.Fatalf("login of %s: status %d", email, status)
+	}
+	return res
+}
+
+// refresh exchanges refreshToken, it returns the status, the new tokens and
+// the problem of a refusal
+func (a *testApp) refresh(refreshToken string) (int, tokens, middleware.Problem) {
+	a.t.Helper()
+	var raw json.RawMessage
+	status := a.do(http.MethodPost, "/api/v1/auth/refresh", "", fiber.Map{"refresh_token": refreshToken}, &raw)
+	var res tokens
+	var problem middleware.Problem
+	if status == http.StatusOK {
+		json.Unmarshal(raw, &res)
+	} else {
+		json.Unmarshal(raw, &problem)
+	}
+	return status, res, problem
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", "user")
+	first := a.loginTokens("ada@example.com")
+
+	status, next, _ := a.refresh(first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, want %d", status, http.StatusOK)
+	}
+	if next.RefreshToken == "" || next.RefreshToken == first.RefreshToken {
+		t.Errorf("refresh token %q, want a new one", next.RefreshToken)
+	}
+	if next.SessionID != first.SessionID {
+		t.Errorf("session %s, want the session of the login %s", next.SessionID, first.SessionID)
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/me", strings.TrimPrefix(next.Token, "Bearer "), nil, nil); status != http.StatusOK {
+		t.Errorf("me with the refreshed access token: status %d, want %d", status, http.StatusOK)
+	}
+	if status, _, _ := a.refresh(next.RefreshToken); status != http.StatusOK {
+		t.Errorf("refreshing the rotated token: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestRefreshTokenReuseSignsSessionOut(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", "user")
+	stolen := a.loginTokens("ada@example.com")
+	// a second session of the same user is not affected
+	other := a.loginTokens("ada@example.com")
+
+	_, rotated, _ := a.refresh(stolen.RefreshToken)
+	status, _, problem := a.refresh(stolen.RefreshToken)
+	if status != http.StatusUnauthorized || problem.Code != "refresh_token_reused" {
+		t.Fatalf("reusing the rotated token: status %d code %q, want %d refresh_token_reused", status, problem.Code, http.StatusUnauthorized)
+	}
+	if status, _, _ := a.refresh(rotated.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("refreshing the family after the reuse: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/me", strings.TrimPrefix(rotated.Token, "Bearer "), nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("access token of the signed out session: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _, _ := a.refresh(other.RefreshToken); status != http.StatusOK {
+		t.Errorf("refreshing the other session: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestRefreshRefusals(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", "user")
+	loggedOut := a.loginTokens("ada@example.com")
+	if status := a.do(http.MethodPost, "/api/v1/auth/logout", "", fiber.Map{"refresh_token": loggedOut.RefreshToken}, nil); status != http.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+
+	for name, token := range map[string]string{"unknown": "not-a-refresh-token", "logged out": loggedOut.RefreshToken, "empty": ""} {
+		t.Run(name, func(t *testing.T) {
+			status, _, problem := a.refresh(token)
+			if status != http.StatusUnauthorized || problem.Code != "invalid_refresh_token" {
+				t.Errorf("status %d code %q, want %d invalid_refresh_token", status, problem.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestProblemHidesWrappedCause(t *testing.T) {
+	a := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		want   string
+	}{
+		// a JSON string does not decode into the login request
+		{"bad body", http.MethodPost, "/api/v1/auth/login", "", "not an object", "bad request"},
+		{"bad token", http.MethodGet, "/api/v1/user/me", "not-a-token", nil, "invalid or expired token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem middleware.Problem
+			a.do(tt.method, tt.path, tt.token, tt.body, &problem)
+			if problem.Detail != tt.want {
+				t.Errorf("detail %q, want %q without its cause", problem.Detail, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// booking is the part of a booking the tests look at
+type booking struct {
+	ID         string               `json:"ID"`
+	UserEmail  string               `json:"UserEmail"`
+	GuestSize  int64                `json:"GuestSize"`
+	Status     models.BookingStatus `json:"Status"`
+	TotalPrice float64              `json:"TotalPrice"`
+}
+
+// bookingFixture is a tour of 100 a guest for groups of up to four with a
+// departure of five seats, an admin and two verified users
+type bookingFixture struct {
+	*testApp
+	departure string
+	admin     string
+	ada       string
+	bob       string
+}
+
+func newBookingFixture(t *testing.T) *bookingFixture {
+	t.Helper()
+	a := newTestApp(t)
+	f := &bookingFixture{
+		testApp: a,
+		admin:   a.user("admin@example.com", constant.AdminRole),
+		ada:     a.user("ada@example.com", constant.UserRole),
+		bob:     a.user("bob@example.com", constant.UserRole),
+	}
+	tour := a.createTour(f.admin, "Alfama walk", "Lisbon", 3, 4)
+	start := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	var res struct {
+		Data struct {
+			ID string `json:"ID"`
+		} `json:"data"`
+	}
+	status := a.do(http.MethodPost, "/api/v1/tour/tour/"+tour.ID+"/departures", f.admin, fiber.Map{
+		"start_date": start,
+		"end_date":   start.Add(4 * time.Hour),
+		"capacity":   5,
+	}, &res)
+	if status != http.StatusCreated {
+		t.Fatalf("creating the departure: status %d", status)
+	}
+	f.departure = res.Data.ID
+	return f
+}
+
+// book books guests on the departure as the holder of token, it returns the
+// status and the booking made
+func (f *bookingFixture) book(token string, guests int) (int, booking) {
+	f.t.Helper()
+	var res struct {
+		Data booking `json:"data"`
+	}
+	status := f.do(http.MethodPost, "/api/v1/booking/me", token, fiber.Map{
+		"departure_id": f.departure,
+		"guest_size":   guests,
+		"phone":        "+14155552671",
+	}, &res)
+	return status, res.Data
+}
+
+func TestCreateAndGetBooking(t *testing.T) {
+	f := newBookingFixture(t)
+
+	status, created := f.book(f.ada, 2)
+	if status != http.StatusCreated {
+		t.Fatalf("create: status %d, want %d", status, http.StatusCreated)
+	}
+	if created.UserEmail != "ada@example.com" || created.GuestSize != 2 || created.TotalPrice != 200 || created.Status != models.BookingHeld {
+		t.Errorf("create: got %+v, want a held booking of ada for 2 guests at 200", created)
+	}
+
+	var res struct {
+		Booking booking `json:"booking"`
+	}
+	if status := f.do(http.MethodGet, "/api/v1/booking/booking/"+created.ID, f.ada, nil, &res); status != http.StatusOK {
+		t.Fatalf("get: status %d, want %d", status, http.StatusOK)
+	}
+	if res.Booking != created {
+		t.Errorf("get: got %+v, want %+v", res.Booking, created)
+	}
+
+	var departure struct {
+		SeatsAvailable int64 `json:"seats_available"`
+	}
+	if status := f.do(http.MethodGet, "/api/v1/tour/departures/"+f.departure, "", nil, &departure); status != http.StatusOK {
+		t.Fatalf("departure: status %d, want %d", status, http.StatusOK)
+	}
+	if departure.SeatsAvailable != 3 {
+		t.Errorf("departure: %d seats available, want 3", departure.SeatsAvailable)
+	}
+}
+
+func TestGetBookingOwnership(t *testing.T) {
+	f := newBookingFixture(t)
+	_, created := f.book(f.ada, 2)
+	path := "/api/v1/booking/booking/" + created.ID
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"owner", f.ada, http.StatusOK},
+		{"admin", f.admin, http.StatusOK},
+		{"other user", f.bob, http.StatusForbidden},
+		{"guest", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := f.do(http.MethodGet, path, tt.token, nil, nil); status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	if status := f.do(http.MethodGet, "/api/v1/booking/booking/000000000000000000000000", f.admin, nil, nil); status != http.StatusNotFound {
+		t.Errorf("unknown booking: status %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestModifyBookingOwnership(t *testing.T) {
+	f := newBookingFixture(t)
+	_, created := f.book(f.ada, 2)
+	path := "/api/v1/booking/booking/" + created.ID
+
+	if status := f.do(http.MethodPost, path+"/cancel", f.bob, nil, nil); status != http.StatusForbidden {
+		t.Errorf("cancel by another user: status %d, want %d", status, http.StatusForbidden)
+	}
+	if status := f.do(http.MethodDelete, path, f.bob, nil, nil); status != http.StatusForbidden {
+		t.Errorf("delete by another user: status %d, want %d", status, http.StatusForbidden)
+	}
+
+	var res struct {
+		Data booking `json:"data"`
+	}
+	if status := f.do(http.MethodPost, path+"/cancel", f.ada, nil, &res); status != http.StatusOK {
+		t.Fatalf("cancel by the owner: status %d, want %d", status, http.StatusOK)
+	}
+	if res.Data.Status != models.BookingCancelled {
+		t.Errorf("cancel by the owner: status %s, want %s", res.Data.Status, models.BookingCancelled)
+	}
+}
+
+func TestMyBookings(t *testing.T) {
+	f := newBookingFixture(t)
+	f.book(f.ada, 1)
+	f.book(f.ada, 1)
+	f.book(f.bob, 2)
+
+	for _, tt := range []struct {
+		token string
+		email string
+		want  int
+	}{
+		{f.ada, "ada@example.com", 2},
+		{f.bob, "bob@example.com", 1},
+	} {
+		var res struct {
+			Bookings []booking `json:"bookings"`
+			Total    int       `json:"total"`
+		}
+		if status := f.do(http.MethodGet, "/api/v1/booking/me", tt.token, nil, &res); status != http.StatusOK {
+			t.Fatalf("%s: status %d, want %d", tt.email, status, http.StatusOK)
+		}
+		if res.Total != tt.want || len(res.Bookings) != tt.want {
+			t.Errorf("%s: %d bookings of %d, want %d", tt.email, len(res.Bookings), res.Total, tt.want)
+		}
+		for _, b := range res.Bookings {
+			if b.UserEmail != tt.email {
+				t.Errorf("%s: got the booking of %s", tt.email, b.UserEmail)
+			}
+		}
+	}
+
+	if status := f.do(http.MethodGet, "/api/v1/booking/booking", f.ada, nil, nil); status != http.StatusForbidden {
+		t.Errorf("every booking as a user: status %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestCreateBookingRefusals(t *testing.T) {
+	f := newBookingFixture(t)
+	if status := f.register("eve@example.com"); status != http.StatusCreated {
+		t.Fatalf("registering eve: status %d", status)
+	}
+	eve := f.login("eve@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		guests int
+		status int
+		code   string
+	}{
+		{"unverified email", eve, 1, http.StatusForbidden, "email_unverified"},
+		{"group above the tour maximum", f.ada, 5, http.StatusUnprocessableEntity, "validation_failed"},
+		{"no token", "", 1, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem middleware.Problem
+			status := f.do(http.MethodPost, "/api/v1/booking/me", tt.token, fiber.Map{
+				"departure_id": f.departure,
+				"guest_size":   tt.guests,
+				"phone":        "+14155552671",
+			}, &problem)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if tt.code != "" && problem.Code != tt.code {
+				t.Errorf("code %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestCreateBookingDoesNotOversell(t *testing.T) {
+	f := newBookingFixture(t)
+
+	if status, _ := f.book(f.ada, 4); status != http.StatusCreated {
+		t.Fatalf("first booking: status %d, want %d", status, http.StatusCreated)
+	}
+	if status, _ := f.book(f.bob, 2); status != http.StatusConflict {
+		t.Errorf("booking more seats than left: status %d, want %d", status, http.StatusConflict)
+	}
+	if status, _ := f.book(f.bob, 1); status != http.StatusCreated {
+		t.Errorf("booking the last seat: status %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func TestUpdateBookingMovesSeats(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	update := func(guests int) (int, middleware.Problem) {
+		var problem middleware.Problem
+		status := f.do(http.MethodPut, "/api/v1/booking/booking/"+b.ID, f.ada, fiber.Map{"guest_size": guests, "phone": "+14155552671"}, &problem)
+		return status, problem
+	}
+
+	if status, _ := update(4); status != http.StatusCreated {
+		t.Fatalf("growing the booking: status %d, want %d", status, http.StatusCreated)
+	}
+	if status, _ := f.book(f.bob, 2); status != http.StatusConflict {
+		t.Errorf("booking past the grown booking: status %d, want %d", status, http.StatusConflict)
+	}
+
+	if status := f.do(http.MethodPost, "/api/v1/booking/booking/"+b.ID+"/cancel", f.ada, nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel: status %d", status)
+	}
+	status, problem := update(1)
+	if status != http.StatusConflict || problem.Code != "invalid_transition" {
+		t.Errorf("updating a cancelled booking: status %d code %q, want %d invalid_transition", status, problem.Code, http.StatusConflict)
+	}
+	if status, _ := f.book(f.bob, 4); status != http.StatusCreated {
+		t.Errorf("booking the released seats: status %d, want %d", status, http.StatusCreated)
+	}
+}
@@ -0,0 +1,38 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/health"
+)
+
+func TestReadyz(t *testing.T) {
+	a := newTestApp(t)
+	h := health.New(time.Second)
+	var mongo error
+	h.Register("mongo", func(context.Context) error { return mongo })
+	a.routes.HealthRoutes(a.app, h)
+
+	readyz := func(name string, want int, wantStatus string) {
+		t.Helper()
+		var report health.Report
+		if status := a.do(http.MethodGet, "/readyz", "", nil, &report); status != want || report.Status != wantStatus {
+			t.Errorf("%s: status %d %q, want %d %q", name, status, report.Status, want, wantStatus)
+		}
+	}
+	readyz("ready", http.StatusOK, health.StatusOK)
+	mongo = errors.New("connection refused")
+	readyz("mongo down", http.StatusServiceUnavailable, health.StatusUnavailable)
+	mongo = nil
+	h.ShutDown()
+	readyz("shutting down", http.StatusServiceUnavailable, health.StatusShuttingDown)
+
+	// the process stays alive while it drains
+	if status := a.do(http.MethodGet, "/healthz", "", nil, nil); status != http.StatusOK {
+		t.Errorf("healthz while shutting down: status %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,112 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/payments"
+	"github.com/tabed23/travel-api/repository/store"
+)
+
+// expireHolds runs the sweeper as if the hold window of every booking made
+// now had passed
+func (f *bookingFixture) expireHolds() int {
+	f.t.Helper()
+	n, err := f.stores.Bookings.ExpireHolds(context.Background(), time.Now().Add(store.DefaultHoldWindow+time.Second))
+	if err != nil {
+		f.t.Fatalf("expiring the holds: %v", err)
+	}
+	return n
+}
+
+func TestNewBookingHoldsSeats(t *testing.T) {
+	f := newBookingFixture(t)
+
+	before := time.Now()
+	_, b := f.book(f.ada, 4)
+	if b.Status != models.BookingHeld {
+		t.Fatalf("booking status %v, want %v", b.Status, models.BookingHeld)
+	}
+	held, err := f.stores.Bookings.GetBooking(context.Background(), b.ID)
+	if err != nil {
+		t.Fatalf("getting the booking: %v", err)
+	}
+	if held.HoldExpiresAt == nil || held.HoldExpiresAt.Before(before.Add(store.DefaultHoldWindow-time.Second)) {
+		t.Errorf("hold expires at %v, want %v after the booking", held.HoldExpiresAt, store.DefaultHoldWindow)
+	}
+	if status, _ := f.book(f.bob, 2); status != http.StatusConflict {
+		t.Errorf("booking past the held seats: status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestExpiredHoldReleasesSeats(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 4)
+
+	if n, err := f.stores.Bookings.ExpireHolds(context.Background(), time.Now()); err != nil || n != 0 {
+		t.Fatalf("expiring before the window ran out: %d, %v, want nothing expired", n, err)
+	}
+	if n := f.expireHolds(); n != 1 {
+		t.Fatalf("expired %d holds, want 1", n)
+	}
+	if got := f.getBooking(f.ada, b.ID).Status; got != models.BookingExpired {
+		t.Errorf("booking status %v, want %v", got, models.BookingExpired)
+	}
+	if status, _ := f.book(f.bob, 4); status != http.StatusCreated {
+		t.Errorf("booking the released seats: status %d, want %d", status, http.StatusCreated)
+	}
+	if n := f.expireHolds(); n != 1 {
+		t.Errorf("expired %d holds on the second run, want only the new booking", n)
+	}
+}
+
+func TestConfirmedBookingDoesNotExpire(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	if status, _ := f.pay(f.ada, b.ID, payments.FakeMethodSuccess); status != http.StatusOK {
+		t.Fatalf("paying: status %d", status)
+	}
+
+	if n := f.expireHolds(); n != 0 {
+		t.Errorf("expired %d holds, want the paid booking kept", n)
+	}
+	if got := f.getBooking(f.ada, b.ID).Status; got != models.BookingConfirmed {
+		t.Errorf("booking status %v, want %v", got, models.BookingConfirmed)
+	}
+}
+
+func TestPaymentAfterHoldExpiryIsRefunded(t *testing.T) {
+	f := newBookingFixture(t)
+	f.gateway.Delay = 200 * time.Millisecond
+	_, b := f.book(f.ada, 2)
+
+	status, p := f.pay(f.ada, b.ID, payments.FakeMethodDelayed)
+	if status != http.StatusAccepted {
+		t.Fatalf("confirm: status %d, want %d", status, http.StatusAccepted)
+	}
+	// the hold runs out while the provider is still processing
+	if n := f.expireHolds(); n != 1 {
+		t.Fatalf("expired %d holds, want 1", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := f.getPayment(f.ada, p.ID)
+		if got.Status == models.PaymentRefunded {
+			if got.RefundedAmount != got.Amount {
+				t.Errorf("refunded %v of %v, want all of it", got.RefundedAmount, got.Amount)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("payment %v, want it refunded after the late webhook", got.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := f.getBooking(f.ada, b.ID).Status; got != models.BookingExpired {
+		t.Errorf("booking status %v, want it left %v", got, models.BookingExpired)
+	}
+}
@@ -0,0 +1,178 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// lockout locks an email for 10s after its first failure, doubled with
+// every further one, and for an hour after the fourth
+func lockout(cfg *config.Config) {
+	cfg.Lockout = config.Lockout{MaxFailures: 4, IPMaxFailures: 20, Delay: 10 * time.Second, Duration: time.Hour, ForgetAfter: time.Hour}
+}
+
+// tryLogin logs email in with password and returns the response, whose body
+// is decoded into the problem of a refusal
+func (a *testApp) tryLogin(email, password string) (*http.Response, middleware.Problem) {
+	a.t.Helper()
+	body, _ := json.Marshal(fiber.Map{"email": email, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	res, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatalf("login of %s: %v", email, err)
+	}
+	defer res.Body.Close()
+	var problem middleware.Problem
+	if res.StatusCode >= http.StatusBadRequest {
+		json.NewDecoder(res.Body).Decode(&problem)
+	}
+	return res, problem
+}
+
+// waitOutLocks ends the locks of email and of the test client like their
+// time had passed. The stores only ever extend a lock, so the failures are
+// counted again on a fresh lock.
+func (a *testApp) waitOutLocks(email string) {
+	a.t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	for _, key := range []string{"email:" + email, "ip:" + testClientIP} {
+		lock, err := a.stores.LoginLocks.Get(ctx, key, now)
+		if err == nil {
+			err = a.stores.LoginLocks.Reset(ctx, key)
+		}
+		for i := 0; i < lock.Failures && err == nil; i++ {
+			_, err = a.stores.LoginLocks.Fail(ctx, key, now, time.Hour)
+		}
+		if err != nil {
+			a.t.Fatalf("ending the lock of %s: %v", key, err)
+		}
+	}
+}
+
+// history returns the login attempts of email, newest first, as the admin
+// holding token sees them
+func (a *testApp) history(token, email string) []models.LoginAttempt {
+	a.t.Helper()
+	var res struct {
+		Logins []models.LoginAttempt `json:"logins"`
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/user/"+email+"/logins", token, nil, &res); status != http.StatusOK {
+		a.t.Fatalf("history of %s: status %d", email, status)
+	}
+	return res.Logins
+}
+
+func TestLoginLockoutProgression(t *testing.T) {
+	a := newTestApp(t, lockout)
+	admin := a.user("admin@example.com", constant.AdminRole)
+	a.user("ada@example.com", constant.UserRole)
+
+	for i, wait := range []string{"10", "20", "40", "3600"} {
+		if res, problem := a.tryLogin("ada@example.com", "not the password"); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d code %q, want %d", i+1, res.StatusCode, problem.Code, http.StatusUnauthorized)
+		}
+		// the right password does not get through a lock either
+		res, problem := a.tryLogin("ada@example.com", testPassword)
+		if res.StatusCode != http.StatusTooManyRequests || problem.Code != "login_locked" {
+			t.Fatalf("after failure %d: status %d code %q, want %d login_locked", i+1, res.StatusCode, problem.Code, http.StatusTooManyRequests)
+		}
+		if got := res.Header.Get(fiber.HeaderRetryAfter); got != wait {
+			t.Errorf("after failure %d: Retry-After %q, want %s", i+1, got, wait)
+		}
+		a.waitOutLocks("ada@example.com")
+	}
+
+	if status := a.do(http.MethodPost, "/api/v1/user/user/ada@example.com/unlock?ip="+testClientIP, admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("unlock: status %d, want %d", status, http.StatusOK)
+	}
+	a.login("ada@example.com")
+	// the success forgot the failures, the next one locks for the delay
+	a.tryLogin("ada@example.com", "not the password")
+	if res, _ := a.tryLogin("ada@example.com", testPassword); res.Header.Get(fiber.HeaderRetryAfter) != "10" {
+		t.Errorf("Retry-After %q after the unlock and a failure, want 10", res.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
+
+func TestLoginSuccessForgetsEmailFailures(t *testing.T) {
+	a := newTestApp(t, lockout)
+	a.user("ada@example.com", constant.UserRole)
+	a.tryLogin("ada@example.com", "not the password")
+	a.waitOutLocks("ada@example.com")
+	a.tryLogin("ada@example.com", "not the password")
+	a.waitOutLocks("ada@example.com")
+
+	a.login("ada@example.com")
+	ctx := context.Background()
+	email, err := a.stores.LoginLocks.Get(ctx, "email:ada@example.com", time.Now())
+	if err != nil || email.Failures != 0 {
+		t.Errorf("failures of the email %d, %v, want none", email.Failures, err)
+	}
+	// logging into an own account does not unlock guessing others
+	ip, err := a.stores.LoginLocks.Get(ctx, "ip:"+testClientIP, time.Now())
+	if err != nil || ip.Failures != 2 {
+		t.Errorf("failures of the ip %d, %v, want 2", ip.Failures, err)
+	}
+}
+
+func TestLoginRefusalDoesNotRevealAccounts(t *testing.T) {
+	a := newTestApp(t, lockout)
+	admin := a.user("admin@example.com", constant.AdminRole)
+	a.user("ada@example.com", constant.UserRole)
+
+	wrong, wrongProblem := a.tryLogin("ada@example.com", "not the password")
+	a.waitOutLocks("ada@example.com")
+	unknown, unknownProblem := a.tryLogin("bob@example.com", "not the password")
+	wrongProblem.Instance, unknownProblem.Instance = "", ""
+	if wrong.StatusCode != unknown.StatusCode || wrongProblem.Code != unknownProblem.Code || wrongProblem.Detail != unknownProblem.Detail || wrongProblem.Title != unknownProblem.Title {
+		t.Errorf("wrong password %d %+v, unknown email %d %+v, want the same answer", wrong.StatusCode, wrongProblem, unknown.StatusCode, unknownProblem)
+	}
+	// unknown emails are locked like accounts
+	if res, problem := a.tryLogin("bob@example.com", "not the password"); res.StatusCode != http.StatusTooManyRequests || problem.Code != "login_locked" {
+		t.Errorf("unknown email after a failure: status %d code %q, want %d login_locked", res.StatusCode, problem.Code, http.StatusTooManyRequests)
+	}
+
+	// the history tells them apart
+	for email, reason := range map[string]string{"ada@example.com": models.LoginWrongPassword, "bob@example.com": models.LoginUnknownEmail} {
+		attempts := a.history(admin, email)
+		if !slices.ContainsFunc(attempts, func(at models.LoginAttempt) bool { return at.Reason == reason }) {
+			t.Errorf("history of %s %+v, want a %s attempt", email, attempts, reason)
+		}
+	}
+}
+
+func TestLoginFromNewIP(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.user("admin@example.com", constant.AdminRole)
+	if status := a.register("ada@example.com"); status != http.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+	// ada always logged in from another address so far
+	if _, err := a.stores.LoginAttempts.Record(context.Background(), models.LoginAttempt{
+		Email: "ada@example.com", IP: "203.0.113.7", Success: true, CreatedAt: time.Now().UTC().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("recording the earlier login: %v", err)
+	}
+
+	a.login("ada@example.com")
+	if attempts := a.history(admin, "ada@example.com"); !attempts[0].Success || !attempts[0].NewIP || attempts[0].IP != testClientIP {
+		t.Errorf("login from %s: %+v, want it flagged as a new ip", testClientIP, attempts[0])
+	}
+	a.login("ada@example.com")
+	if attempts := a.history(admin, "ada@example.com"); attempts[0].NewIP {
+		t.Errorf("second login from %s flagged as a new ip", testClientIP)
+	}
+}
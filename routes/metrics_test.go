@@ -0,0 +1,62 @@
+package routes_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tabed23/travel-api/payments"
+)
+
+// scrape returns the metrics the way Prometheus scrapes them
+func (a *testApp) scrape() string {
+	a.t.Helper()
+	res, err := a.app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+	if err != nil {
+		a.t.Fatalf("scraping: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		a.t.Fatalf("scraping: status %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		a.t.Errorf("content type %q, want the text exposition format", ct)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		a.t.Fatalf("scraping: %v", err)
+	}
+	return string(body)
+}
+
+func TestMetricsScrape(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	f.getBooking(f.ada, b.ID)
+	f.do(http.MethodGet, "/api/v1/booking/booking/"+b.ID, "", nil, nil)
+	if status, _ := f.pay(f.ada, b.ID, payments.FakeMethodSuccess); status != http.StatusOK {
+		t.Fatalf("paying: status %d", status)
+	}
+
+	body := f.scrape()
+	for _, want := range []string{
+		// requests are labelled with the route template, not the path
+		`travel_http_requests_total{method="GET",route="/api/v1/booking/booking/:id",status="200"} 1`,
+		`travel_http_requests_total{method="GET",route="/api/v1/booking/booking/:id",status="401"} 1`,
+		`travel_http_requests_total{method="POST",route="/api/v1/booking/me",status="201"} 1`,
+		`travel_http_requests_in_flight{method="POST",route="/api/v1/booking/me"} 0`,
+		`travel_store_operation_duration_seconds_count{operation="CreateBooking",outcome="ok",store="BookingStore"} 1`,
+		`travel_bookings_created_total 1`,
+		`travel_revenue_total{currency="USD"} 200`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the scrape lacks %s", want)
+		}
+	}
+	if strings.Contains(body, b.ID) {
+		t.Errorf("the scrape has a label with the booking id %s", b.ID)
+	}
+}
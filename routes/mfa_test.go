@@ -0,0 +1,135 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/totp"
+)
+
+// enrollMFA sets up the second factor of the holder of token, it returns
+// the secret, the step of the code that confirmed it and the recovery codes
+func (a *testApp) enrollMFA(token string) (string, int64, []string) {
+	a.t.Helper()
+	var enrolled struct {
+		Data struct {
+			Secret string `json:"secret"`
+		} `json:"data"`
+	}
+	if status := a.do(http.MethodPost, "/api/v1/user/me/mfa", token, nil, &enrolled); status != http.StatusCreated {
+		a.t.Fatalf("enrolling: status %d", status)
+	}
+	step := totp.Step(time.Now())
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if status := a.do(http.MethodPost, "/api/v1/user/me/mfa/confirm", token, fiber.Map{"code": a.code(enrolled.Data.Secret, step)}, &confirmed); status != http.StatusOK {
+		a.t.Fatalf("confirming: status %d", status)
+	}
+	return enrolled.Data.Secret, step, confirmed.RecoveryCodes
+}
+
+// code returns the code of secret at step
+func (a *testApp) code(secret string, step int64) string {
+	a.t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		a.t.Fatalf("code: %v", err)
+	}
+	return code
+}
+
+// mfaToken logs email in with its password and returns the token of the
+// second step
+func (a *testApp) mfaToken(email string) string {
+	a.t.Helper()
+	var res struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": email, "password": testPassword}, &res); status != http.StatusOK {
+		a.t.Fatalf("login of %s: status %d", email, status)
+	}
+	if !res.MFARequired || res.MFAToken == "" {
+		a.t.Fatalf("login of %s passed without the second factor", email)
+	}
+	return res.MFAToken
+}
+
+// verifyMFA completes the second step of a login, it returns the status and
+// the problem of a refusal
+func (a *testApp) verifyMFA(mfaToken string, body fiber.Map) (int, middleware.Problem) {
+	a.t.Helper()
+	body["mfa_token"] = mfaToken
+	var problem middleware.Problem
+	status := a.do(http.MethodPost, "/api/v1/auth/mfa/verify", "", body, &problem)
+	return status, problem
+}
+
+func TestMFACodesAreSingleUse(t *testing.T) {
+	a := newTestApp(t)
+	secret, step, _ := a.enrollMFA(a.user("ada@example.com", "user"))
+
+	// the code that confirmed the enrollment is used up
+	status, problem := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"code": a.code(secret, step)})
+	if status != http.StatusUnauthorized || problem.Code != "invalid_mfa_code" {
+		t.Fatalf("reusing the enrollment code: status %d code %q, want %d invalid_mfa_code", status, problem.Code, http.StatusUnauthorized)
+	}
+
+	next := a.code(secret, step+1)
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"code": next}); status != http.StatusOK {
+		t.Fatalf("the next code: status %d, want %d", status, http.StatusOK)
+	}
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"code": next}); status != http.StatusUnauthorized {
+		t.Errorf("the same code twice: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	// an older step is refused once a later one was used
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"code": a.code(secret, step)}); status != http.StatusUnauthorized {
+		t.Errorf("an older code: status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestMFARecoveryCodesAreSingleUse(t *testing.T) {
+	a := newTestApp(t)
+	_, _, recovery := a.enrollMFA(a.user("ada@example.com", "user"))
+	if len(recovery) == 0 {
+		t.Fatalf("no recovery codes")
+	}
+
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"recovery_code": recovery[0]}); status != http.StatusOK {
+		t.Fatalf("recovery code: status %d, want %d", status, http.StatusOK)
+	}
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"recovery_code": recovery[0]}); status != http.StatusUnauthorized {
+		t.Errorf("the same recovery code twice: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := a.verifyMFA(a.mfaToken("ada@example.com"), fiber.Map{"recovery_code": recovery[1]}); status != http.StatusOK {
+		t.Errorf("another recovery code: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestMFARefusesWrongCode(t *testing.T) {
+	a := newTestApp(t)
+	secret, step, _ := a.enrollMFA(a.user("ada@example.com", "user"))
+
+	tests := []struct {
+		name string
+		body fiber.Map
+	}{
+		{"code of another step", fiber.Map{"code": a.code(secret, step+5)}},
+		{"unknown recovery code", fiber.Map{"recovery_code": "aaaa-bbbb-cccc-dddd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, problem := a.verifyMFA(a.mfaToken("ada@example.com"), tt.body)
+			if status != http.StatusUnauthorized || problem.Code != "invalid_mfa_code" {
+				t.Errorf("status %d code %q, want %d invalid_mfa_code", status, problem.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+	if status, problem := a.verifyMFA("not-a-token", fiber.Map{"code": a.code(secret, step+1)}); status != http.StatusUnauthorized || problem.Code != "invalid_mfa_token" {
+		t.Errorf("forged mfa token: status %d code %q, want %d invalid_mfa_token", status, problem.Code, http.StatusUnauthorized)
+	}
+}
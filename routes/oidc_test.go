@@ -0,0 +1,243 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/oidc"
+)
+
+// redirectURL is the callback the fake provider sends users back to
+const redirectURL = "http://localhost/api/v1/auth/oidc/fake/callback"
+
+// oidcFixture is the API with the fake issuer, served over HTTP, as its
+// provider named fake
+type oidcFixture struct {
+	*testApp
+	issuer *oidc.FakeIssuer
+}
+
+func newOIDCFixture(t *testing.T, users ...oidc.FakeUser) *oidcFixture {
+	t.Helper()
+	issuer, err := oidc.NewFakeIssuer("travel-api", "client-secret")
+	if err != nil {
+		t.Fatalf("creating the fake issuer: %v", err)
+	}
+	issuer.Users = users
+	srv := httptest.NewServer(issuer)
+	t.Cleanup(srv.Close)
+
+	a := newTestApp(t, func(cfg *config.Config) {
+		cfg.OIDC.Providers = []config.OIDCProvider{{
+			Name:         "fake",
+			Issuer:       srv.URL,
+			ClientID:     "travel-api",
+			ClientSecret: "client-secret",
+			RedirectURL:  redirectURL,
+		}}
+	})
+	return &oidcFixture{testApp: a, issuer: issuer}
+}
+
+// authorize starts a sign-in as the user with email and returns where the
+// provider sends the browser back to, with the code and state
+func (f *oidcFixture) authorize(email string) *url.URL {
+	f.t.Helper()
+	res, err := f.app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/fake/authorize", nil), -1)
+	if err != nil {
+		f.t.Fatalf("authorize: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		f.t.Fatalf("authorize: status %d, want %d", res.StatusCode, http.StatusFound)
+	}
+	target, err := url.Parse(res.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		f.t.Fatalf("authorize: location: %v", err)
+	}
+	q := target.Query()
+	if q.Get("state") == "" || q.Get("nonce") == "" || q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		f.t.Fatalf("authorize: %v lacks the state, nonce or PKCE challenge", target)
+	}
+	q.Set("login_hint", email)
+	target.RawQuery = q.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err = client.Get(target.String())
+	if err != nil {
+		f.t.Fatalf("signing in at the provider: %v", err)
+	}
+	res.Body.Close()
+	back, err := url.Parse(res.Header.Get(fiber.HeaderLocation))
+	if err != nil || !strings.HasPrefix(back.String(), redirectURL) {
+		f.t.Fatalf("the provider sent the user back to %q, want %s", res.Header.Get(fiber.HeaderLocation), redirectURL)
+	}
+	if back.Query().Get("state") != q.Get("state") {
+		f.t.Fatalf("the provider changed the state")
+	}
+	return back
+}
+
+// callback completes the sign-in at back, it returns the status and the
+// access token, if any
+func (f *oidcFixture) callback(back *url.URL, problem *middleware.Problem) (int, string) {
+	f.t.Helper()
+	res, err := f.app.Test(httptest.NewRequest(http.MethodGet, back.RequestURI(), nil), -1)
+	if err != nil {
+		f.t.Fatalf("callback: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		if problem != nil {
+			json.NewDecoder(res.Body).Decode(problem)
+		}
+		return res.StatusCode, ""
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		f.t.Fatalf("callback: decoding the answer: %v", err)
+	}
+	return res.StatusCode, strings.TrimPrefix(body.Token, "Bearer ")
+}
+
+// me returns the email and role of the holder of token
+func (f *oidcFixture) me(token string) (string, string) {
+	f.t.Helper()
+	var res struct {
+		User struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		} `json:"user"`
+	}
+	if status := f.do(http.MethodGet, "/api/v1/user/me", token, nil, &res); status != http.StatusOK {
+		f.t.Fatalf("me: status %d", status)
+	}
+	return res.User.Email, res.User.Role
+}
+
+var fakeAda = oidc.FakeUser{Subject: "ada-1", Email: "ada@example.com", EmailVerified: true, GivenName: "Ada", FamilyName: "Lovelace"}
+
+func TestOIDCFirstSignInCreatesUser(t *testing.T) {
+	f := newOIDCFixture(t, fakeAda)
+
+	status, token := f.callback(f.authorize(fakeAda.Email), nil)
+	if status != http.StatusOK {
+		t.Fatalf("callback: status %d, want %d", status, http.StatusOK)
+	}
+	if email, role := f.me(token); email != fakeAda.Email || role != "user" {
+		t.Errorf("signed in as %s %s, want %s user", email, role, fakeAda.Email)
+	}
+	identity, err := f.stores.Identities.Get(context.Background(), "fake", fakeAda.Subject)
+	if err != nil || identity.Email != fakeAda.Email {
+		t.Fatalf("identity: %+v, %v, want it linked to %s", identity, err, fakeAda.Email)
+	}
+
+	// the second sign-in finds the identity and the same user
+	if status, token := f.callback(f.authorize(fakeAda.Email), nil); status != http.StatusOK {
+		t.Fatalf("second callback: status %d, want %d", status, http.StatusOK)
+	} else if email, _ := f.me(token); email != fakeAda.Email {
+		t.Errorf("second sign-in as %s, want %s", email, fakeAda.Email)
+	}
+}
+
+func TestOIDCLinksExistingAccount(t *testing.T) {
+	f := newOIDCFixture(t, fakeAda)
+	f.user(fakeAda.Email, "admin")
+
+	status, token := f.callback(f.authorize(fakeAda.Email), nil)
+	if status != http.StatusOK {
+		t.Fatalf("callback: status %d, want %d", status, http.StatusOK)
+	}
+	if email, role := f.me(token); email != fakeAda.Email || role != "admin" {
+		t.Errorf("signed in as %s %s, want the existing admin %s", email, role, fakeAda.Email)
+	}
+	identities, err := f.stores.Identities.GetByEmail(context.Background(), fakeAda.Email)
+	if err != nil || len(identities) != 1 || identities[0].Subject != fakeAda.Subject {
+		t.Errorf("identities of %s: %+v, %v, want the fake one", fakeAda.Email, identities, err)
+	}
+	// the password still works on the verified account
+	f.login(fakeAda.Email)
+}
+
+func TestOIDCRefusesUnverifiedEmail(t *testing.T) {
+	bob := oidc.FakeUser{Subject: "bob-1", Email: "bob@example.com", GivenName: "Bob"}
+	f := newOIDCFixture(t, bob)
+
+	var problem middleware.Problem
+	if status, _ := f.callback(f.authorize(bob.Email), &problem); status != http.StatusForbidden {
+		t.Fatalf("callback: status %d, want %d", status, http.StatusForbidden)
+	}
+	if problem.Code != "oidc_email_unverified" {
+		t.Errorf("code %q, want oidc_email_unverified", problem.Code)
+	}
+	if _, err := f.stores.Users.GetUserByEmail(context.Background(), bob.Email); err == nil {
+		t.Errorf("a user was created for the unverified email")
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	f := newOIDCFixture(t, fakeAda)
+	back := f.authorize(fakeAda.Email)
+
+	if status, _ := f.callback(back, nil); status != http.StatusOK {
+		t.Fatalf("callback: status %d, want %d", status, http.StatusOK)
+	}
+	var problem middleware.Problem
+	if status, _ := f.callback(back, &problem); status != http.StatusBadRequest || problem.Code != "invalid_oidc_state" {
+		t.Errorf("replayed callback: status %d code %q, want %d invalid_oidc_state", status, problem.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCCallbackRefusals(t *testing.T) {
+	f := newOIDCFixture(t, fakeAda)
+
+	tests := []struct {
+		name   string
+		back   func() *url.URL
+		status int
+		code   string
+	}{
+		{"unknown state", func() *url.URL {
+			back := f.authorize(fakeAda.Email)
+			q := back.Query()
+			q.Set("state", "forged")
+			back.RawQuery = q.Encode()
+			return back
+		}, http.StatusBadRequest, "invalid_oidc_state"},
+		{"code of another sign-in", func() *url.URL {
+			// the verifier of the state does not match the challenge the
+			// code was issued for
+			other := f.authorize(fakeAda.Email)
+			back := f.authorize(fakeAda.Email)
+			q := back.Query()
+			q.Set("code", other.Query().Get("code"))
+			back.RawQuery = q.Encode()
+			return back
+		}, http.StatusUnauthorized, "oidc_login_failed"},
+		{"sign-in denied by the provider", func() *url.URL {
+			return f.authorize("nobody@example.com")
+		}, http.StatusUnauthorized, "oidc_login_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem middleware.Problem
+			status, _ := f.callback(tt.back(), &problem)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if problem.Code != tt.code {
+				t.Errorf("code %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+}
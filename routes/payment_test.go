@@ -0,0 +1,260 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// payment is the part of a payment the tests look at
+type payment struct {
+	ID             string               `json:"ID"`
+	Amount         float64              `json:"Amount"`
+	RefundedAmount float64              `json:"RefundedAmount"`
+	RefundPending  float64              `json:"RefundPending"`
+	Status         models.PaymentStatus `json:"Status"`
+}
+
+// pay starts a payment of the booking with method as the holder of token and
+// confirms it, it returns the status of the confirmation and the payment
+func (f *bookingFixture) pay(token, bookingID, method string) (int, payment) {
+	f.t.Helper()
+	var started struct {
+		Data payment `json:"data"`
+	}
+	if status := f.do(http.MethodPost, "/api/v1/booking/booking/"+bookingID+"/payment", token, fiber.Map{"payment_method": method}, &started); status != http.StatusCreated {
+		f.t.Fatalf("starting the payment: status %d", status)
+	}
+	var confirmed struct {
+		Data payment `json:"data"`
+	}
+	status := f.do(http.MethodPost, "/api/v1/payment/"+started.Data.ID+"/confirm", token, nil, &confirmed)
+	if confirmed.Data.ID == "" {
+		confirmed.Data = started.Data
+	}
+	return status, confirmed.Data
+}
+
+// getPayment returns the payment with id as the holder of token sees it
+func (f *bookingFixture) getPayment(token, id string) payment {
+	f.t.Helper()
+	var res struct {
+		Payment payment `json:"payment"`
+	}
+	if status := f.do(http.MethodGet, "/api/v1/payment/"+id, token, nil, &res); status != http.StatusOK {
+		f.t.Fatalf("getting payment %s: status %d", id, status)
+	}
+	return res.Payment
+}
+
+// getBooking returns the booking with id as the holder of token sees it
+func (f *bookingFixture) getBooking(token, id string) booking {
+	f.t.Helper()
+	var res struct {
+		Booking booking `json:"booking"`
+	}
+	if status := f.do(http.MethodGet, "/api/v1/booking/booking/"+id, token, nil, &res); status != http.StatusOK {
+		f.t.Fatalf("getting booking %s: status %d", id, status)
+	}
+	return res.Booking
+}
+
+func TestPaymentConfirmsBooking(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+
+	status, p := f.pay(f.ada, b.ID, payments.FakeMethodSuccess)
+	if status != http.StatusOK {
+		t.Fatalf("confirm: status %d, want %d", status, http.StatusOK)
+	}
+	if p.Status != models.PaymentSucceeded || p.Amount != b.TotalPrice {
+		t.Errorf("payment: got %+v, want %v succeeded", p, b.TotalPrice)
+	}
+	if got := f.getBooking(f.ada, b.ID).Status; got != models.BookingConfirmed {
+		t.Errorf("booking status %v, want %v", got, models.BookingConfirmed)
+	}
+}
+
+func TestPaymentDeclined(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+
+	var problem middleware.Problem
+	var started struct {
+		Data payment `json:"data"`
+	}
+	if status := f.do(http.MethodPost, "/api/v1/booking/booking/"+b.ID+"/payment", f.ada, fiber.Map{"payment_method": payments.FakeMethodDecline}, &started); status != http.StatusCreated {
+		t.Fatalf("starting the payment: status %d", status)
+	}
+	status := f.do(http.MethodPost, "/api/v1/payment/"+started.Data.ID+"/confirm", f.ada, nil, &problem)
+	if status != http.StatusPaymentRequired {
+		t.Fatalf("confirm: status %d, want %d", status, http.StatusPaymentRequired)
+	}
+	if problem.Code != "payment_declined" {
+		t.Errorf("code %q, want payment_declined", problem.Code)
+	}
+	if got := f.getPayment(f.ada, started.Data.ID).Status; got != models.PaymentFailed {
+		t.Errorf("payment status %v, want %v", got, models.PaymentFailed)
+	}
+	if got := f.getBooking(f.ada, b.ID).Status; got != models.BookingHeld {
+		t.Errorf("booking status %v, want it still %v", got, models.BookingHeld)
+	}
+}
+
+func TestPaymentDelayedWebhook(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+
+	status, p := f.pay(f.ada, b.ID, payments.FakeMethodDelayed)
+	if status != http.StatusAccepted {
+		t.Fatalf("confirm: status %d, want %d", status, http.StatusAccepted)
+	}
+	if p.Status != models.PaymentPending {
+		t.Errorf("payment status %v, want %v until the webhook", p.Status, models.PaymentPending)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for f.getBooking(f.ada, b.ID).Status != models.BookingConfirmed {
+		if time.Now().After(deadline) {
+			t.Fatalf("booking not confirmed by the webhook")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := f.getPayment(f.ada, p.ID).Status; got != models.PaymentSucceeded {
+		t.Errorf("payment status %v, want %v", got, models.PaymentSucceeded)
+	}
+}
+
+func TestPaymentWebhookRefusesBadSignature(t *testing.T) {
+	f := newBookingFixture(t)
+
+	var problem middleware.Problem
+	if status := f.do(http.MethodPost, "/api/v1/payment/webhook", "", fiber.Map{"intent_id": "pi_forged", "status": "succeeded"}, &problem); status != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", status, http.StatusBadRequest)
+	}
+	if problem.Code != "invalid_signature" {
+		t.Errorf("code %q, want invalid_signature", problem.Code)
+	}
+}
+
+func TestCancelRefundsPayment(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	if status, _ := f.pay(f.ada, b.ID, payments.FakeMethodSuccess); status != http.StatusOK {
+		t.Fatalf("paying: status %d", status)
+	}
+	payment, err := f.stores.Payments.GetSucceededByBooking(context.Background(), mustObjectID(t, b.ID))
+	if err != nil {
+		t.Fatalf("finding the payment: %v", err)
+	}
+
+	var cancelled struct {
+		Data struct {
+			RefundAmount float64 `json:"RefundAmount"`
+		} `json:"data"`
+	}
+	if status := f.do(http.MethodPost, "/api/v1/booking/booking/"+b.ID+"/cancel", f.ada, nil, &cancelled); status != http.StatusOK {
+		t.Fatalf("cancel: status %d, want %d", status, http.StatusOK)
+	}
+	want := cancelled.Data.RefundAmount
+	if want <= 0 {
+		t.Fatalf("refund amount %v, want a refund under the default policy", want)
+	}
+	got := f.getPayment(f.ada, payment.ID.Hex())
+	if got.RefundedAmount != want || got.RefundPending != 0 {
+		t.Errorf("payment: got %+v, want %v refunded and nothing pending", got, want)
+	}
+
+	refunded, err := f.routes.Refunds().RetryRefunds(context.Background())
+	if err != nil || refunded != 0 {
+		t.Errorf("retry: %d refunded, %v, want nothing left to refund", refunded, err)
+	}
+	if again := f.getPayment(f.ada, payment.ID.Hex()); again.RefundedAmount != want {
+		t.Errorf("refunded %v after the retry, want %v", again.RefundedAmount, want)
+	}
+}
+
+func TestPendingRefundIsClaimedOnce(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	if status, _ := f.pay(f.ada, b.ID, payments.FakeMethodSuccess); status != http.StatusOK {
+		t.Fatalf("paying: status %d", status)
+	}
+	ctx := context.Background()
+	p, err := f.stores.Payments.GetSucceededByBooking(ctx, mustObjectID(t, b.ID))
+	if err != nil {
+		t.Fatalf("finding the payment: %v", err)
+	}
+	if err := f.stores.Payments.SetRefundPending(ctx, p.ID, 50); err != nil {
+		t.Fatalf("recording the pending refund: %v", err)
+	}
+
+	now := time.Now().UTC()
+	claimed, ok, err := f.stores.Payments.ClaimRefund(ctx, p.ID, now, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first claim: %v, %v, want it claimed", ok, err)
+	}
+	if _, ok, _ := f.stores.Payments.ClaimRefund(ctx, p.ID, now, time.Minute); ok {
+		t.Fatalf("second claim succeeded while the first lease runs")
+	}
+	if refunded, _ := f.routes.Refunds().RetryRefunds(ctx); refunded != 0 {
+		t.Errorf("retry refunded %d claimed refunds, want 0", refunded)
+	}
+
+	if ok, err := f.stores.Payments.AddRefund(ctx, p.ID, "not the claim", 50, false); err != nil || ok {
+		t.Errorf("refund recorded without the claim: %v, %v", ok, err)
+	}
+	if ok, err := f.stores.Payments.AddRefund(ctx, p.ID, claimed.RefundClaim, 50, false); err != nil || !ok {
+		t.Fatalf("recording the claimed refund: %v, %v", ok, err)
+	}
+	if ok, _ := f.stores.Payments.AddRefund(ctx, p.ID, claimed.RefundClaim, 50, false); ok {
+		t.Errorf("the same claim recorded the refund twice")
+	}
+	if got := f.getPayment(f.ada, p.ID.Hex()).RefundedAmount; got != 50 {
+		t.Errorf("refunded %v, want 50", got)
+	}
+}
+
+func TestExpiredRefundLeaseIsClaimedAgain(t *testing.T) {
+	f := newBookingFixture(t)
+	_, b := f.book(f.ada, 2)
+	if status, _ := f.pay(f.ada, b.ID, payments.FakeMethodSuccess); status != http.StatusOK {
+		t.Fatalf("paying: status %d", status)
+	}
+	ctx := context.Background()
+	p, err := f.stores.Payments.GetSucceededByBooking(ctx, mustObjectID(t, b.ID))
+	if err != nil {
+		t.Fatalf("finding the payment: %v", err)
+	}
+	if err := f.stores.Payments.SetRefundPending(ctx, p.ID, 50); err != nil {
+		t.Fatalf("recording the pending refund: %v", err)
+	}
+
+	past := time.Now().UTC().Add(-time.Hour)
+	if _, ok, _ := f.stores.Payments.ClaimRefund(ctx, p.ID, past, time.Minute); !ok {
+		t.Fatalf("first claim failed")
+	}
+	refunded, err := f.routes.Refunds().RetryRefunds(ctx)
+	if err != nil || refunded != 1 {
+		t.Fatalf("retry: %d refunded, %v, want the abandoned refund sent", refunded, err)
+	}
+	if got := f.getPayment(f.ada, p.ID.Hex()); got.RefundedAmount != 50 || got.RefundPending != 0 {
+		t.Errorf("payment: got %+v, want 50 refunded", got)
+	}
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatalf("id %q: %v", hex, err)
+	}
+	return id
+}
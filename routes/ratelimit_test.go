@@ -0,0 +1,136 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// limited limits the tours by ip and the users by user to three requests a
+// minute each, in memory
+func limited(cfg *config.Config) {
+	cfg.RateLimit.Backend = "memory"
+	cfg.RateLimit.Tours = config.Rule{Requests: 3, Window: time.Minute, Key: "ip"}
+	cfg.RateLimit.Users = config.Rule{Requests: 3, Window: time.Minute, Key: "user"}
+}
+
+// get sends a GET of path with headers and returns the response, whose body
+// is decoded into the problem of a refusal
+func (a *testApp) get(path string, headers map[string]string) (*http.Response, middleware.Problem) {
+	a.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer res.Body.Close()
+	var problem middleware.Problem
+	if res.StatusCode >= http.StatusBadRequest {
+		json.NewDecoder(res.Body).Decode(&problem)
+	}
+	return res, problem
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{fiber.HeaderAuthorization: "Bearer " + token}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	a := newTestApp(t, limited)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		res, _ := a.get("/api/v1/tour/tour", nil)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status %d with %d remaining, want %d", res.StatusCode, remaining, http.StatusOK)
+		}
+		headers := map[string]string{
+			"RateLimit-Policy":    "3;w=60",
+			"RateLimit-Limit":     "3",
+			"RateLimit-Remaining": fmt.Sprint(remaining),
+		}
+		for name, want := range headers {
+			if got := res.Header.Get(name); got != want {
+				t.Errorf("%s %q, want %q", name, got, want)
+			}
+		}
+		if res.Header.Get("RateLimit-Reset") == "" {
+			t.Errorf("no RateLimit-Reset")
+		}
+	}
+
+	res, problem := a.get("/api/v1/tour/tour", nil)
+	if res.StatusCode != http.StatusTooManyRequests || problem.Code != "rate_limited" {
+		t.Fatalf("beyond the limit: status %d code %q, want %d rate_limited", res.StatusCode, problem.Code, http.StatusTooManyRequests)
+	}
+	// a token comes back every 20 seconds
+	if got := res.Header.Get(fiber.HeaderRetryAfter); got != "20" {
+		t.Errorf("Retry-After %q, want 20", got)
+	}
+}
+
+func TestRateLimitIsPerRouteGroup(t *testing.T) {
+	a := newTestApp(t, limited)
+	for i := 0; i < 3; i++ {
+		a.get("/api/v1/tour/tour", nil)
+	}
+	if res, _ := a.get("/api/v1/tour/tours/search/count", nil); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("another tour route: status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	// the review routes have a bucket of their own
+	if res, _ := a.get("/api/v1/review/tour", nil); res.StatusCode != http.StatusOK {
+		t.Errorf("review route: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	a := newTestApp(t, limited)
+	ada := a.user("ada@example.com", constant.UserRole)
+	bob := a.user("bob@example.com", constant.UserRole)
+
+	for i := 0; i < 3; i++ {
+		a.get("/api/v1/user/me", bearer(ada))
+	}
+	if res, _ := a.get("/api/v1/user/me", bearer(ada)); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("ada beyond the limit: status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	// bob calls from the same address
+	if res, _ := a.get("/api/v1/user/me", bearer(bob)); res.StatusCode != http.StatusOK {
+		t.Errorf("bob: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestRateLimitRandomAPIKeysShareTheIPBucket(t *testing.T) {
+	f := newAPIKeyFixture(t, limited)
+	key, _ := f.createKey(fiber.Map{"scopes": []string{constant.PermUsersRead}})
+
+	// made up keys and tokens are no way around the limit of the address
+	for i := 0; i < 3; i++ {
+		res, _ := f.get("/api/v1/user/me", map[string]string{middleware.APIKeyHeader: fmt.Sprintf("made-up-key-%d", i)})
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("made up key %d: status %d, want %d", i, res.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	for name, headers := range map[string]map[string]string{
+		"made up key":   {middleware.APIKeyHeader: "yet-another-key"},
+		"made up token": bearer("made-up-token"),
+	} {
+		if res, _ := f.get("/api/v1/user/me", headers); res.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("%s: status %d, want %d", name, res.StatusCode, http.StatusTooManyRequests)
+		}
+	}
+	// a real key has a bucket of its own
+	if res, _ := f.get("/api/v1/user/me", map[string]string{middleware.APIKeyHeader: key}); res.StatusCode != http.StatusOK {
+		t.Errorf("real key: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
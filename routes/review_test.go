@@ -0,0 +1,67 @@
+package routes_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// postReview writes a review of the tour as the holder of token and returns
+// its id
+func (a *testApp) postReview(token, tourID string) string {
+	a.t.Helper()
+	var res struct {
+		Data struct {
+			ID string `json:"ID"`
+		} `json:"data"`
+	}
+	if status := a.do(http.MethodPost, "/api/v1/review/"+tourID+"/review", token, fiber.Map{"username": "ada", "reviewText": "Lovely", "rating": 5}, &res); status != http.StatusCreated {
+		a.t.Fatalf("reviewing tour %s: status %d", tourID, status)
+	}
+	return res.Data.ID
+}
+
+// tourReviews returns the ids of the reviews of the tour
+func (a *testApp) tourReviews(tourID string) []string {
+	a.t.Helper()
+	var res struct {
+		Tour struct {
+			Reviews []string `json:"Reviews"`
+		} `json:"tour"`
+	}
+	if status := a.do(http.MethodGet, "/api/v1/tour/tour/"+tourID, "", nil, &res); status != http.StatusOK {
+		a.t.Fatalf("getting tour %s: status %d", tourID, status)
+	}
+	return res.Tour.Reviews
+}
+
+func TestDeleteReviewOfAnotherTour(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.user("admin@example.com", constant.AdminRole)
+	ada := a.user("ada@example.com", constant.UserRole)
+	alfama := a.createTour(admin, "Alfama walk", "Lisbon", 3, 10)
+	douro := a.createTour(admin, "Douro cruise", "Porto", 60, 40)
+	review := a.postReview(ada, alfama.ID)
+
+	var problem middleware.Problem
+	if status := a.do(http.MethodDelete, "/api/v1/review/"+douro.ID+"/review/"+review, ada, nil, &problem); status != http.StatusNotFound || problem.Code != "review_not_found" {
+		t.Fatalf("through another tour: status %d code %q, want %d review_not_found", status, problem.Code, http.StatusNotFound)
+	}
+	if status := a.do(http.MethodGet, "/api/v1/review/review/"+review, "", nil, nil); status != http.StatusOK {
+		t.Errorf("the review is gone: status %d", status)
+	}
+	if got := a.tourReviews(alfama.ID); !slices.Contains(got, review) {
+		t.Errorf("reviews of the tour %v, want %s", got, review)
+	}
+
+	if status := a.do(http.MethodDelete, "/api/v1/review/"+alfama.ID+"/review/"+review, ada, nil, nil); status != http.StatusOK {
+		t.Fatalf("through its tour: status %d, want %d", status, http.StatusOK)
+	}
+	if got := a.tourReviews(alfama.ID); len(got) != 0 {
+		t.Errorf("reviews of the tour %v after the delete, want none", got)
+	}
+}
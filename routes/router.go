@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/ratelimit"
+)
+
+// router is a route group whose routes record HTTP metrics, name their logs
+// and spans after the route template and share the rate limit of the group.
+// The route template is only known once a request has been routed, so the
+// middlewares are added to each route rather than to the app.
+type router struct {
+	fiber.Router
+	prefix  string
+	metrics *metrics.Metrics
+	limit   fiber.Handler
+}
+
+// group returns the route group of prefix, limited by rule
+func (r *Routes) group(app *fiber.App, prefix string, rule config.Rule) fiber.Router {
+	g := &router{Router: app.Group(prefix), prefix: prefix, metrics: r.metrics}
+	if r.rateLimits != nil && !rule.Off() {
+		name := strings.TrimPrefix(prefix, "/api/v1/")
+		limit := ratelimit.Limit{Requests: rule.Requests, Window: rule.Window}
+		g.limit = middleware.RateLimit(r.rateLimits, name, limit, middleware.ClientKey(rule.Key, r.jwt, r.apiKeys), r.logger)
+	}
+	return g
+}
+
+func (g *router) instrument(method, path string, handlers []fiber.Handler) []fiber.Handler {
+	route := g.prefix + path
+	chain := []fiber.Handler{middleware.Route(route), middleware.Metrics(g.metrics, method, route)}
+	if g.limit != nil {
+		chain = append(chain, g.limit)
+	}
+	return append(chain, handlers...)
+}
+
+func (g *router) Get(path string, handlers ...fiber.Handler) fiber.Router {
+	return g.Router.Get(path, g.instrument(fiber.MethodGet, path, handlers)...)
+}
+
+func (g *router) Post(path string, handlers ...fiber.Handler) fiber.Router {
+	return g.Router.Post(path, g.instrument(fiber.MethodPost, path, handlers)...)
+}
+
+func (g *router) Put(path string, handlers ...fiber.Handler) fiber.Router {
+	return g.Router.Put(path, g.instrument(fiber.MethodPut, path, handlers)...)
+}
+
+func (g *router) Patch(path string, handlers ...fiber.Handler) fiber.Router {
+	return g.Router.Patch(path, g.instrument(fiber.MethodPatch, path, handlers)...)
+}
+
+func (g *router) Delete(path string, handlers ...fiber.Handler) fiber.Router {
+	return g.Router.Delete(path, g.instrument(fiber.MethodDelete, path, handlers)...)
+}
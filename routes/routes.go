@@ -3,116 +3,344 @@ package routes
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
 	"github.com/tabed23/travel-api/controller"
+	"github.com/tabed23/travel-api/health"
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/mail"
+	"github.com/tabed23/travel-api/metrics"
 	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/oidc"
+	"github.com/tabed23/travel-api/payments"
 	"github.com/tabed23/travel-api/repository/store"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/tabed23/travel-api/utils"
+	"github.com/tabed23/travel-api/utils/constant"
 )
 
 type Routes struct {
-	db     *mongo.Database
-	logger *slog.Logger
+	stores       store.Stores
+	gateway      payments.Gateway
+	mailer       mail.Mailer
+	jwt          *utils.JWT
+	keys         *keyring.Keyring
+	metrics      *metrics.Metrics
+	limits       config.RateLimit
+	rateLimits   store.RateLimitRepository
+	lockout      controller.LockoutPolicy
+	account      controller.AccountPolicy
+	mfa          controller.MFAPolicy
+	apiKeys      *controller.APIKeyController
+	sessions     *controller.SessionController
+	providers    []*oidc.Provider
+	oidcStateTTL time.Duration
+	auth         fiber.Handler
+	optionalAuth fiber.Handler
+	logger       *slog.Logger
 }
 
-func NewRoutes(db *mongo.Database, l *slog.Logger) *Routes {
-	return &Routes{db: db, logger: l}
+func NewRoutes(cfg *config.Config, s store.Stores, g payments.Gateway, mailer mail.Mailer, keys *keyring.Keyring, m *metrics.Metrics, l *slog.Logger) *Routes {
+	j := utils.NewJWT(keys, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.AccessTTL, cfg.JWT.RefreshTTL)
+	apiKeys := controller.NewAPIKeyController(s.APIKeys, s.Users, l)
+	sessions := controller.NewSessionController(s.Sessions, s.RefreshTokens, l)
+	providers := make([]*oidc.Provider, 0, len(cfg.OIDC.Providers))
+	for _, p := range cfg.OIDC.Providers {
+		providers = append(providers, oidc.New(p, nil))
+	}
+	rateLimits := s.RateLimits
+	if cfg.RateLimit.Backend == "off" {
+		rateLimits = nil
+	}
+	return &Routes{
+		stores:       s,
+		gateway:      g,
+		mailer:       mailer,
+		jwt:          j,
+		keys:         keys,
+		metrics:      m,
+		limits:       cfg.RateLimit,
+		rateLimits:   rateLimits,
+		lockout:      controller.LockoutPolicy(cfg.Lockout),
+		account:      controller.AccountPolicy(cfg.Account),
+		mfa:          controller.MFAPolicy(cfg.MFA),
+		apiKeys:      apiKeys,
+		sessions:     sessions,
+		providers:    providers,
+		oidcStateTTL: cfg.OIDC.StateTTL,
+		auth:         middleware.Auth(j, apiKeys, sessions),
+		optionalAuth: middleware.OptionalAuth(j, apiKeys, sessions),
+		logger:       l,
+	}
 }
+
+// Bookings returns the booking store, it is also used by the hold sweeper
+func (r *Routes) Bookings() store.BookingRepository {
+	return r.stores.Bookings
+}
+
+// Refunds returns the payments controller retrying the pending refunds, it
+// is used by the hold sweeper
+func (r *Routes) Refunds() *controller.PaymentController {
+	return controller.NewPaymentController(r.stores.Payments, r.stores.Bookings, r.gateway, r.metrics, r.logger)
+}
+
+// Keys returns the keyring signing the tokens, it is also rotated by the
+// server
+func (r *Routes) Keys() *keyring.Keyring {
+	return r.keys
+}
+
+// RequireRole restricts a route to callers with one of roles, API keys are refused. It must follow an auth middleware
+func (r *Routes) RequireRole(roles ...string) fiber.Handler {
+	return middleware.RequireRole(roles...)
+}
+
+// RequirePermission restricts a route to callers whose role, and API key scopes, grant perms. It must follow an auth middleware
+func (r *Routes) RequirePermission(perms ...string) fiber.Handler {
+	return middleware.RequirePermission(perms...)
+}
+
+// HealthRoutes serves the probes of load balancers and orchestrators, they
+// need no authentication
+func (r *Routes) HealthRoutes(app *fiber.App, h *health.Health) {
+	healthController := controller.NewHealthController(h)
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return healthController.Live(c)
+	})
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		return healthController.Ready(c)
+	})
+	app.Get("/version", func(c *fiber.Ctx) error {
+		return healthController.Version(c)
+	})
+}
+
+// WellKnownRoutes serves the public keys verifying the tokens, for other
+// services
+func (r *Routes) WellKnownRoutes(app *fiber.App) {
+	keyController := controller.NewKeyController(r.keys)
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		return keyController.JWKS(c)
+	})
+}
+
+// MetricsRoutes serves the metrics for Prometheus to scrape
+func (r *Routes) MetricsRoutes(app *fiber.App) {
+	app.Get("/metrics", r.metrics.Handler())
+}
+
 func (r *Routes) TourRoutes(app *fiber.App) {
 	r.logger.Log(context.Background(), slog.LevelInfo, "Tour routes initialized")
-	tourColl := r.db.Collection("Tour")
-	tourStore := store.NewTourStore(*tourColl, r.logger)
-	tourController := controller.NewTourController(*tourStore, r.logger)
-	routes := app.Group("/api/v1/tour")
-	routes.Post("/tour", func(c *fiber.Ctx) error {
+	tourController := controller.NewTourController(r.stores.Tours, r.logger)
+	departureController := controller.NewDepartureController(r.stores.Departures, r.logger)
+	routes := r.group(app, "/api/v1/tour", r.limits.Tours)
+	routes.Post("/tour", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
 
 		return tourController.CreateTour(c)
 	})
-	routes.Get("/tour", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tour", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
 		return tourController.Get(c)
 	})
 
-	routes.Get("/tour/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tour/:id", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
 		return tourController.GetTour(c)
 	})
-	routes.Delete("/tour/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Delete("/tour/:id", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
 		return tourController.Delete(c)
 	})
-	routes.Put("/tour/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Put("/tour/:id", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
 		return tourController.UpdateTour(c)
 	})
-	routes.Get("/tours/search/tour", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tours/search/tour", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
 		return tourController.SearchTour(c)
 	})
 
-	routes.Get("/tours/search/featured", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tours/search/featured", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
 		return tourController.ShowFeaturedTour(c)
 	})
-	routes.Get("/tours/search/count", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tours/search/count", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
 		return tourController.CountTotalTours(c)
 	})
 
+	routes.Post("/tour/:id/departures", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
+		return departureController.CreateDeparture(c)
+	})
+	routes.Get("/tour/:id/departures", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
+		return departureController.GetTourDepartures(c)
+	})
+	routes.Get("/departures/:id", r.optionalAuth, r.RequirePermission(constant.PermToursRead), func(c *fiber.Ctx) error {
+		return departureController.GetDeparture(c)
+	})
+	routes.Put("/departures/:id", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
+		return departureController.UpdateDeparture(c)
+	})
+	routes.Delete("/departures/:id", r.auth, r.RequirePermission(constant.PermToursWrite), func(c *fiber.Ctx) error {
+		return departureController.Delete(c)
+	})
+
 }
 func (r *Routes) UserRoutes(app *fiber.App) {
 	r.logger.Log(context.Background(), slog.LevelInfo, "User routes initialized")
 
-	userColl := r.db.Collection("User")
-	userStore := store.NewUserStore(*userColl, r.logger)
-	userController := controller.NewUserController(*userStore, r.logger)
-	routes := app.Group("/api/v1/user")
-	routes.Post("/user", func(c *fiber.Ctx) error {
+	userController := controller.NewUserController(r.stores.Users, r.stores.Identities, r.sessions, r.logger)
+	loginController := controller.NewLoginController(r.stores.LoginAttempts, r.stores.LoginLocks, r.lockout, r.logger)
+	mfaController := controller.NewMFAController(r.stores.MFA, r.stores.Users, r.jwt, r.mfa, r.logger)
+	routes := r.group(app, "/api/v1/user", r.limits.Users)
+	routes.Get("/me", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return userController.Me(c)
+	})
+	routes.Get("/me/logins", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return loginController.MyHistory(c)
+	})
+	routes.Put("/me", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return userController.UpdateMe(c)
+	})
+	routes.Get("/me/mfa", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return mfaController.Status(c)
+	})
+	routes.Post("/me/mfa", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return mfaController.StartEnrollment(c)
+	})
+	routes.Post("/me/mfa/confirm", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return mfaController.ConfirmEnrollment(c)
+	})
+	routes.Post("/me/mfa/recovery-codes", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return mfaController.RegenerateRecoveryCodes(c)
+	})
+	routes.Delete("/me/mfa", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return mfaController.Disable(c)
+	})
+	routes.Get("/me/identities", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return userController.MyIdentities(c)
+	})
+	routes.Get("/me/sessions", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return r.sessions.Mine(c)
+	})
+	routes.Delete("/me/sessions", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return r.sessions.RevokeOthers(c)
+	})
+	routes.Delete("/me/sessions/:id", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
+		return r.sessions.RevokeMine(c)
+	})
+	routes.Post("/user", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
 		return userController.CreateUser(c)
 	})
-	routes.Get("/user", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/user", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
 		return userController.Get(c)
 	})
-	routes.Get("/user/:email", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/user/:email", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
 		return userController.GetUser(c)
 	})
-	routes.Delete("/user/:email", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Delete("/user/:email", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
 		return userController.Delete(c)
 	})
-	routes.Put("/user/:email", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Put("/user/:email", r.auth, r.RequirePermission(constant.PermUsersWrite), func(c *fiber.Ctx) error {
 		return userController.UpdateUser(c)
 	})
+	routes.Put("/user/:email/role", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return userController.UpdateRole(c)
+	})
+	routes.Get("/user/:email/logins", r.auth, r.RequirePermission(constant.PermUsersRead), func(c *fiber.Ctx) error {
+		return loginController.UserHistory(c)
+	})
+	routes.Post("/user/:email/unlock", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return loginController.Unlock(c)
+	})
+	routes.Delete("/user/:email/mfa", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return mfaController.Reset(c)
+	})
+	routes.Get("/user/:email/sessions", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.sessions.UserSessions(c)
+	})
+	routes.Delete("/user/:email/sessions", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.sessions.ForceLogout(c)
+	})
+	routes.Post("/api-keys", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.apiKeys.Create(c)
+	})
+	routes.Get("/api-keys", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.apiKeys.Get(c)
+	})
+	routes.Get("/api-keys/:id", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.apiKeys.GetKey(c)
+	})
+	routes.Delete("/api-keys/:id", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return r.apiKeys.Revoke(c)
+	})
 }
 
 func (r *Routes) AuthRoutes(app *fiber.App) {
 	r.logger.Log(context.Background(), slog.LevelInfo, "Auth routes initialized")
 
-	userColl := r.db.Collection("User")
-	userStore := store.NewUserStore(*userColl, r.logger)
-	authController := controller.NewAuthController(*userStore, r.logger)
-	routes := app.Group("/api/v1/auth")
+	loginController := controller.NewLoginController(r.stores.LoginAttempts, r.stores.LoginLocks, r.lockout, r.logger)
+	accountController := controller.NewAccountController(r.stores.Users, r.stores.UserTokens, r.sessions, r.mailer, r.account, r.logger)
+	mfaController := controller.NewMFAController(r.stores.MFA, r.stores.Users, r.jwt, r.mfa, r.logger)
+	authController := controller.NewAuthController(r.stores.Users, r.stores.RefreshTokens, r.sessions, loginController, accountController, mfaController, r.jwt, r.logger)
+	oidcController := controller.NewOIDCController(r.providers, r.stores.OIDCStates, r.stores.Identities, r.stores.Users, authController, r.oidcStateTTL, r.logger)
+	routes := r.group(app, "/api/v1/auth", r.limits.Auth)
 	routes.Post("/regiser", func(c *fiber.Ctx) error {
 		return authController.Register(c)
 	})
 	routes.Post("/login", func(c *fiber.Ctx) error {
 		return authController.Login(c)
 	})
+	routes.Post("/mfa/verify", func(c *fiber.Ctx) error {
+		return authController.VerifyMFA(c)
+	})
+	routes.Post("/mfa/enroll", func(c *fiber.Ctx) error {
+		return authController.EnrollMFA(c)
+	})
+	routes.Post("/mfa/enroll/confirm", func(c *fiber.Ctx) error {
+		return authController.ConfirmMFAEnrollment(c)
+	})
+	routes.Post("/refresh", func(c *fiber.Ctx) error {
+		return authController.Refresh(c)
+	})
+	routes.Post("/logout", func(c *fiber.Ctx) error {
+		return authController.Logout(c)
+	})
+	routes.Post("/password/forgot", func(c *fiber.Ctx) error {
+		return accountController.ForgotPassword(c)
+	})
+	routes.Post("/password/reset", func(c *fiber.Ctx) error {
+		return accountController.ResetPassword(c)
+	})
+	routes.Post("/verify", func(c *fiber.Ctx) error {
+		return accountController.Verify(c)
+	})
+	routes.Post("/verify/request", r.auth, func(c *fiber.Ctx) error {
+		return accountController.RequestVerification(c)
+	})
+	routes.Get("/oidc", func(c *fiber.Ctx) error {
+		return oidcController.Providers(c)
+	})
+	routes.Get("/oidc/:provider/authorize", func(c *fiber.Ctx) error {
+		return oidcController.Authorize(c)
+	})
+	routes.Get("/oidc/:provider/callback", func(c *fiber.Ctx) error {
+		return oidcController.Callback(c)
+	})
 }
 
 func (r *Routes) ReviewRoutes(app *fiber.App) {
 	r.logger.Log(context.Background(), slog.LevelInfo, "Review routes initialized")
 
-	tourColl := r.db.Collection("Tour")
-	reviewColl := r.db.Collection("Reviews")
-	reviewStore := store.NewReviewStore(*reviewColl, *tourColl, r.logger)
-	reviewController := controller.NewReviewController(*reviewStore, r.logger)
-	routes := app.Group("/api/v1/review")
-	routes.Post("/:id/review", func(c *fiber.Ctx) error {
+	reviewController := controller.NewReviewController(r.stores.Reviews, r.metrics, r.logger)
+	routes := r.group(app, "/api/v1/review", r.limits.Reviews)
+	routes.Post("/:id/review", r.auth, r.RequirePermission(constant.PermReviewsWrite), func(c *fiber.Ctx) error {
 
 		return reviewController.CreateReview(c)
 	})
-	routes.Get("/tour", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/tour", r.optionalAuth, r.RequirePermission(constant.PermReviewsRead), func(c *fiber.Ctx) error {
 		return reviewController.Get(c)
 	})
 
-	routes.Get("/review/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/review/:id", r.optionalAuth, r.RequirePermission(constant.PermReviewsRead), func(c *fiber.Ctx) error {
 		return reviewController.GetReview(c)
 	})
-	routes.Delete("/:tourid/review/:reviewid", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Delete("/:tourid/review/:reviewid", r.auth, r.RequirePermission(constant.PermReviewsWrite), func(c *fiber.Ctx) error {
 		return reviewController.Delete(c)
 	})
 }
@@ -120,28 +348,72 @@ func (r *Routes) ReviewRoutes(app *fiber.App) {
 func (r *Routes) BookingRoutes(app *fiber.App) {
 	r.logger.Log(context.Background(), slog.LevelInfo, "booking routes initialized")
 
-	bookingColl := r.db.Collection("Booking")
-	bookingStore := store.NewBookStore(*bookingColl, r.logger)
-	bookingController := controller.NewBookingController(*bookingStore, r.logger)
-	routes := app.Group("/api/v1/booking")
+	paymentController := controller.NewPaymentController(r.stores.Payments, r.stores.Bookings, r.gateway, r.metrics, r.logger)
+	bookingController := controller.NewBookingController(r.stores.Bookings, r.stores.Users, paymentController, r.metrics, r.logger)
+	routes := r.group(app, "/api/v1/booking", r.limits.Bookings)
 
-	routes.Post("/:id/booking", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Post("/me", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
+		return bookingController.CreateMyBooking(c)
+	})
+	routes.Get("/me", r.auth, r.RequirePermission(constant.PermBookingsRead), func(c *fiber.Ctx) error {
+		return bookingController.GetMine(c)
+	})
+	routes.Post("/:id/booking", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
 		return bookingController.CreatBooking(c)
 	})
 
-	routes.Get("/booking/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/booking/:id", r.auth, r.RequirePermission(constant.PermBookingsRead), func(c *fiber.Ctx) error {
 		return bookingController.GetBooking(c)
 	})
 
-	routes.Delete("/booking/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Delete("/booking/:id", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
 		return bookingController.Delete(c)
 	})
-	routes.Put("/booking/:id", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Put("/booking/:id", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
 		return bookingController.UpdateBooking(c)
 	})
+	routes.Post("/booking/:id/cancel", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
+		return bookingController.Cancel(c)
+	})
+	routes.Post("/booking/:id/confirm", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return bookingController.Confirm(c)
+	})
+	routes.Post("/booking/:id/complete", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return bookingController.Complete(c)
+	})
+	routes.Post("/booking/:id/no-show", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
+		return bookingController.NoShow(c)
+	})
+	routes.Post("/booking/:id/payment", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
+		return paymentController.StartPayment(c)
+	})
 
-	routes.Get("/booking", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+	routes.Get("/booking", r.auth, r.RequireRole(constant.AdminRole), func(c *fiber.Ctx) error {
 		return bookingController.Get(c)
 	})
 
 }
+
+func (r *Routes) PaymentRoutes(app *fiber.App) {
+	r.logger.Log(context.Background(), slog.LevelInfo, "payment routes initialized")
+
+	paymentController := controller.NewPaymentController(r.stores.Payments, r.stores.Bookings, r.gateway, r.metrics, r.logger)
+	if fake, ok := r.gateway.(*payments.FakeGateway); ok {
+		fake.Sink = func(payload []byte, signature string) {
+			if err := paymentController.ProcessWebhook(context.Background(), payload, signature); err != nil {
+				r.logger.Error("fake gateway webhook failed", "error", err)
+			}
+		}
+	}
+	routes := r.group(app, "/api/v1/payment", r.limits.Payments)
+
+	routes.Post("/webhook", func(c *fiber.Ctx) error {
+		return paymentController.Webhook(c)
+	})
+	routes.Get("/:id", r.auth, r.RequirePermission(constant.PermBookingsRead), func(c *fiber.Ctx) error {
+		return paymentController.GetPayment(c)
+	})
+	routes.Post("/:id/confirm", r.auth, r.RequirePermission(constant.PermBookingsWrite), func(c *fiber.Ctx) error {
+		return paymentController.ConfirmPayment(c)
+	})
+}
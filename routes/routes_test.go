@@ -0,0 +1,155 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/config"
+	"github.com/tabed23/travel-api/keyring"
+	"github.com/tabed23/travel-api/mail"
+	"github.com/tabed23/travel-api/metrics"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/payments"
+	"github.com/tabed23/travel-api/repository/memory"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/routes"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// testPassword is the password of every user of the tests
+const testPassword = "correct horse battery"
+
+// testApp is the API wired like the server, on the in-memory stores
+type testApp struct {
+	t       *testing.T
+	app     *fiber.App
+	stores  store.Stores
+	gateway *payments.FakeGateway
+	outbox  *mail.Outbox
+	routes  *routes.Routes
+}
+
+// newTestApp returns the API wired on fresh stores, configure changes the
+// configuration of the tests before the routes are built
+func newTestApp(t *testing.T, configure ...func(*config.Config)) *testApp {
+	t.Helper()
+	cfg := config.Default()
+	cfg.JWT.Secret = "test-secret"
+	cfg.RateLimit.Backend = "off"
+	cfg.Lockout.Delay = 0
+	for _, c := range configure {
+		c(&cfg)
+	}
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	m := metrics.New()
+	stores := store.Instrument(memory.New(0).Stores(), m)
+	keys := keyring.New(stores.SigningKeys, keyring.Policy{
+		Algorithm:   cfg.JWT.Algorithm,
+		RotateEvery: cfg.JWT.RotateEvery,
+		Overlap:     cfg.JWT.RotationOverlap,
+		TokenTTL:    cfg.JWT.AccessTTL,
+	}, cfg.JWT.Secret)
+	if err := keys.Rotate(context.Background(), time.Now()); err != nil {
+		t.Fatalf("rotating the signing keys: %v", err)
+	}
+	gateway := payments.NewFakeGateway("test-webhook")
+	gateway.Delay = 10 * time.Millisecond
+	outbox := mail.NewOutbox(t.TempDir(), cfg.Mail.From)
+	r := routes.NewRoutes(&cfg, stores, gateway, outbox, keys, m, l)
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler(l)})
+	r.TourRoutes(app)
+	r.UserRoutes(app)
+	r.AuthRoutes(app)
+	r.ReviewRoutes(app)
+	r.BookingRoutes(app)
+	r.PaymentRoutes(app)
+	r.MetricsRoutes(app)
+	return &testApp{t: t, app: app, stores: stores, gateway: gateway, outbox: outbox, routes: r}
+}
+
+// do sends a request with a JSON body, if any, as the holder of token, if
+// any, and decodes the JSON answer into out, if any. It returns the status.
+func (a *testApp) do(method, path, token string, body, out interface{}) int {
+	a.t.Helper()
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("encoding the body of %s %s: %v", method, path, err)
+		}
+		payload = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, payload)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	res, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			a.t.Fatalf("decoding the answer of %s %s: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+// register signs up email through the API and returns its status
+func (a *testApp) register(email string) int {
+	a.t.Helper()
+	return a.do(http.MethodPost, "/api/v1/auth/regiser", "", fiber.Map{
+		"first_name": "Test",
+		"last_name":  "User",
+		"user_name":  "testuser",
+		"email":      email,
+		"password":   testPassword,
+	}, nil)
+}
+
+// login signs email in through the API and returns its access token
+func (a *testApp) login(email string) string {
+	a.t.Helper()
+	var res struct {
+		Token string `json:"token"`
+	}
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": email, "password": testPassword}, &res); status != http.StatusOK {
+		a.t.Fatalf("login of %s: status %d", email, status)
+	}
+	token, ok := strings.CutPrefix(res.Token, "Bearer ")
+	if !ok {
+		a.t.Fatalf("login of %s: token %q without the Bearer prefix", email, res.Token)
+	}
+	return token
+}
+
+// user registers a verified user with role and returns its access token
+func (a *testApp) user(email, role string) string {
+	a.t.Helper()
+	if status := a.register(email); status != http.StatusCreated {
+		a.t.Fatalf("registering %s: status %d", email, status)
+	}
+	ctx := context.Background()
+	if _, err := a.stores.Users.MarkVerified(ctx, email); err != nil {
+		a.t.Fatalf("verifying %s: %v", email, err)
+	}
+	if role != constant.UserRole {
+		if _, err := a.stores.Users.UpdateRole(ctx, email, role); err != nil {
+			a.t.Fatalf("making %s %s: %v", email, role, err)
+		}
+	}
+	return a.login(email)
+}
@@ -0,0 +1,148 @@
+package routes_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// access returns the access token of a login without its Bearer prefix
+func (t tokens) access() string {
+	return strings.TrimPrefix(t.Token, "Bearer ")
+}
+
+// meStatus returns the status of /me for the holder of token and the code of
+// a refusal
+func (a *testApp) meStatus(token string) (int, string) {
+	a.t.Helper()
+	var problem middleware.Problem
+	status := a.do(http.MethodGet, "/api/v1/user/me", token, nil, &problem)
+	return status, problem.Code
+}
+
+// assertSignedOut checks that neither the access nor the refresh token of
+// login work any longer
+func (a *testApp) assertSignedOut(name string, login tokens) {
+	a.t.Helper()
+	if status, code := a.meStatus(login.access()); status != http.StatusUnauthorized || code != "session_revoked" {
+		a.t.Errorf("%s: access token status %d code %q, want %d session_revoked", name, status, code, http.StatusUnauthorized)
+	}
+	if status, _, _ := a.refresh(login.RefreshToken); status != http.StatusUnauthorized {
+		a.t.Errorf("%s: refresh status %d, want %d", name, status, http.StatusUnauthorized)
+	}
+}
+
+// assertSignedIn checks that the access token of login still works
+func (a *testApp) assertSignedIn(name string, login tokens) {
+	a.t.Helper()
+	if status, code := a.meStatus(login.access()); status != http.StatusOK {
+		a.t.Errorf("%s: access token status %d code %q, want %d", name, status, code, http.StatusOK)
+	}
+}
+
+func TestMySessions(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	laptop := a.loginTokens("ada@example.com")
+	phone := a.loginTokens("ada@example.com")
+
+	var res struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	if status := a.do(http.MethodGet, "/api/v1/user/me/sessions", phone.access(), nil, &res); status != http.StatusOK {
+		t.Fatalf("status %d, want %d", status, http.StatusOK)
+	}
+	// a.user logged in once too
+	if len(res.Sessions) != 3 {
+		t.Fatalf("%d sessions, want 3", len(res.Sessions))
+	}
+	for _, s := range res.Sessions {
+		if s.Current != (s.ID == phone.SessionID) {
+			t.Errorf("session %s current %v, want only %s current", s.ID, s.Current, phone.SessionID)
+		}
+	}
+	a.assertSignedIn("laptop", laptop)
+}
+
+func TestRevokedSessionIsRejected(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	laptop := a.loginTokens("ada@example.com")
+	stolen := a.loginTokens("ada@example.com")
+
+	if status := a.do(http.MethodDelete, "/api/v1/user/me/sessions/"+stolen.SessionID, laptop.access(), nil, nil); status != http.StatusOK {
+		t.Fatalf("revoking: status %d, want %d", status, http.StatusOK)
+	}
+	a.assertSignedOut("revoked session", stolen)
+	a.assertSignedIn("revoking session", laptop)
+}
+
+func TestLogoutEndsSession(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	login := a.loginTokens("ada@example.com")
+
+	if status := a.do(http.MethodPost, "/api/v1/auth/logout", "", fiber.Map{"refresh_token": login.RefreshToken}, nil); status != http.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+	a.assertSignedOut("logged out session", login)
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	others := []tokens{a.loginTokens("ada@example.com"), a.loginTokens("ada@example.com")}
+	current := a.loginTokens("ada@example.com")
+
+	var res struct {
+		Revoked int `json:"revoked"`
+	}
+	if status := a.do(http.MethodDelete, "/api/v1/user/me/sessions", current.access(), nil, &res); status != http.StatusOK {
+		t.Fatalf("status %d, want %d", status, http.StatusOK)
+	}
+	// the two others and the login of a.user
+	if res.Revoked != 3 {
+		t.Errorf("revoked %d sessions, want 3", res.Revoked)
+	}
+	for _, other := range others {
+		a.assertSignedOut("other session", other)
+	}
+	a.assertSignedIn("current session", current)
+}
+
+func TestForceLogout(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.user("admin@example.com", constant.AdminRole)
+	a.user("ada@example.com", constant.UserRole)
+	logins := []tokens{a.loginTokens("ada@example.com"), a.loginTokens("ada@example.com")}
+
+	if status := a.do(http.MethodDelete, "/api/v1/user/user/ada@example.com/sessions", admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("status %d, want %d", status, http.StatusOK)
+	}
+	for _, login := range logins {
+		a.assertSignedOut("forced out session", login)
+	}
+	if status, _ := a.meStatus(admin); status != http.StatusOK {
+		t.Errorf("the admin was signed out too: status %d", status)
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	bob := a.user("bob@example.com", constant.UserRole)
+	ada := a.loginTokens("ada@example.com")
+
+	var problem middleware.Problem
+	if status := a.do(http.MethodDelete, "/api/v1/user/me/sessions/"+ada.SessionID, bob, nil, &problem); status != http.StatusNotFound || problem.Code != "session_not_found" {
+		t.Errorf("status %d code %q, want %d session_not_found", status, problem.Code, http.StatusNotFound)
+	}
+	a.assertSignedIn("session of ada", ada)
+}
@@ -0,0 +1,190 @@
+package routes_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// tour is the part of a tour the tests look at
+type tour struct {
+	ID           string `json:"ID"`
+	Title        string `json:"Title"`
+	City         string `json:"City"`
+	Distance     int    `json:"Distance"`
+	MaxGroupSize int    `json:"MaxGroupSize"`
+}
+
+// createTour creates a tour through the API as the admin holding token
+func (a *testApp) createTour(token, title, city string, distance, maxGroupSize int) tour {
+	a.t.Helper()
+	var res struct {
+		Data tour `json:"data"`
+	}
+	status := a.do(http.MethodPost, "/api/v1/tour/tour", token, fiber.Map{
+		"title":        title,
+		"city":         city,
+		"address":      "1 Main Street",
+		"distance":     distance,
+		"photo":        "https://example.com/tour.jpg",
+		"desc":         "A tour of " + city,
+		"price":        100,
+		"maxGroupSize": maxGroupSize,
+	}, &res)
+	if status != http.StatusCreated {
+		a.t.Fatalf("creating tour %s: status %d", title, status)
+	}
+	return res.Data
+}
+
+// seedTours creates five tours, three of them in Lisbon
+func seedTours(a *testApp) {
+	a.t.Helper()
+	admin := a.user("admin@example.com", constant.AdminRole)
+	a.createTour(admin, "Alfama walk", "Lisbon", 3, 10)
+	a.createTour(admin, "Belem by bike", "Lisbon", 12, 6)
+	a.createTour(admin, "Sintra day trip", "Lisbon", 30, 20)
+	a.createTour(admin, "Ribeira walk", "Porto", 4, 10)
+	a.createTour(admin, "Douro cruise", "Porto", 60, 40)
+}
+
+func TestTourPagination(t *testing.T) {
+	a := newTestApp(t)
+	seedTours(a)
+
+	tests := []struct {
+		query string
+		want  int
+		// page is set for the queries walking the tours two by two
+		page bool
+	}{
+		{"page=1&limit=2", 2, true},
+		{"page=2&limit=2", 2, true},
+		{"page=3&limit=2", 1, true},
+		{"page=4&limit=2", 0, true},
+		{"limit=100", 5, false},
+		{"", 5, false},
+	}
+	// every tour is on exactly one of the pages
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var res struct {
+				Tours []tour `json:"tours"`
+				Total int    `json:"total"`
+			}
+			if status := a.do(http.MethodGet, "/api/v1/tour/tour?"+tt.query, "", nil, &res); status != http.StatusOK {
+				t.Fatalf("status %d, want %d", status, http.StatusOK)
+			}
+			if len(res.Tours) != tt.want {
+				t.Errorf("got %d tours, want %d", len(res.Tours), tt.want)
+			}
+			if res.Total != 5 {
+				t.Errorf("total %d, want 5", res.Total)
+			}
+			if tt.page {
+				for _, tour := range res.Tours {
+					if seen[tour.ID] {
+						t.Errorf("tour %s on two pages", tour.Title)
+					}
+					seen[tour.ID] = true
+				}
+			}
+		})
+	}
+	if len(seen) != 5 {
+		t.Errorf("the pages held %d tours, want 5", len(seen))
+	}
+}
+
+func TestTourPaginationRefusesBadPages(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, query := range []string{"page=0", "page=x", "limit=-1", "limit=0", "limit=101"} {
+		if status := a.do(http.MethodGet, "/api/v1/tour/tour?"+query, "", nil, nil); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want %d", query, status, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestTourCount(t *testing.T) {
+	a := newTestApp(t)
+
+	var res struct {
+		Total int `json:"total"`
+	}
+	if status := a.do(http.MethodGet, "/api/v1/tour/tours/search/count", "", nil, &res); status != http.StatusOK {
+		t.Fatalf("status %d, want %d", status, http.StatusOK)
+	}
+	if res.Total != 0 {
+		t.Errorf("total %d before any tour, want 0", res.Total)
+	}
+
+	seedTours(a)
+	if status := a.do(http.MethodGet, "/api/v1/tour/tours/search/count", "", nil, &res); status != http.StatusOK {
+		t.Fatalf("status %d, want %d", status, http.StatusOK)
+	}
+	if res.Total != 5 {
+		t.Errorf("total %d, want 5", res.Total)
+	}
+}
+
+func TestTourSearch(t *testing.T) {
+	a := newTestApp(t)
+	seedTours(a)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"city=Lisbon", []string{"Alfama walk", "Belem by bike", "Sintra day trip"}},
+		{"city=Lisbon&distance=10", []string{"Belem by bike", "Sintra day trip"}},
+		{"city=Lisbon&maxGroupSize=10", []string{"Alfama walk", "Sintra day trip"}},
+		{"city=Lisbon&distance=10&maxGroupSize=10", []string{"Sintra day trip"}},
+		{"city=Porto&distance=100", nil},
+		{"city=Madrid", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var res struct {
+				Tours []tour `json:"tour"`
+			}
+			if status := a.do(http.MethodGet, "/api/v1/tour/tours/search/tour?"+tt.query, "", nil, &res); status != http.StatusOK {
+				t.Fatalf("status %d, want %d", status, http.StatusOK)
+			}
+			got := make([]string, 0, len(res.Tours))
+			for _, tour := range res.Tours {
+				got = append(got, tour.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTourSearchRefusesBadNumbers(t *testing.T) {
+	a := newTestApp(t)
+
+	for _, query := range []string{"city=Lisbon&distance=far", "city=Lisbon&maxGroupSize=many"} {
+		if status := a.do(http.MethodGet, "/api/v1/tour/tours/search/tour?"+query, "", nil, nil); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want %d", query, status, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestCreateTourNeedsAdmin(t *testing.T) {
+	a := newTestApp(t)
+	user := a.user("ada@example.com", constant.UserRole)
+	body := fiber.Map{"title": "Alfama walk", "city": "Lisbon", "address": "1 Main Street", "photo": "https://example.com/tour.jpg", "desc": "A walk", "price": 10, "maxGroupSize": 4}
+
+	if status := a.do(http.MethodPost, "/api/v1/tour/tour", "", body, nil); status != http.StatusUnauthorized {
+		t.Errorf("guest: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := a.do(http.MethodPost, "/api/v1/tour/tour", user, body, nil); status != http.StatusForbidden {
+		t.Errorf("user: status %d, want %d", status, http.StatusForbidden)
+	}
+}
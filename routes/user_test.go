@@ -0,0 +1,65 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tabed23/travel-api/middleware"
+	"github.com/tabed23/travel-api/utils/constant"
+)
+
+// newPassword is the password the tests change testPassword to
+const newPassword = "staple battery horse correct"
+
+// changePassword sets the password of the holder of token through
+// PUT /me, with current as the current password if it is not empty
+func (a *testApp) changePassword(token, current string) (int, middleware.Problem) {
+	a.t.Helper()
+	body := fiber.Map{"first_name": "Test", "last_name": "User", "password": newPassword}
+	if current != "" {
+		body["current_password"] = current
+	}
+	var problem middleware.Problem
+	status := a.do(http.MethodPut, "/api/v1/user/me", token, body, &problem)
+	return status, problem
+}
+
+func TestChangePasswordNeedsCurrentPassword(t *testing.T) {
+	a := newTestApp(t)
+	token := a.user("ada@example.com", constant.UserRole)
+
+	status, problem := a.changePassword(token, "")
+	if status != http.StatusUnprocessableEntity || len(problem.Errors) != 1 || problem.Errors[0].Field != "current_password" {
+		t.Errorf("without the current password: status %d errors %+v, want %d on current_password", status, problem.Errors, http.StatusUnprocessableEntity)
+	}
+	if status, problem := a.changePassword(token, "not the password"); status != http.StatusUnauthorized || problem.Code != "invalid_password" {
+		t.Errorf("with a wrong current password: status %d code %q, want %d invalid_password", status, problem.Code, http.StatusUnauthorized)
+	}
+	// the password did not change
+	a.login("ada@example.com")
+}
+
+func TestChangePasswordSignsOtherSessionsOut(t *testing.T) {
+	a := newTestApp(t)
+	a.user("ada@example.com", constant.UserRole)
+	other := a.loginTokens("ada@example.com")
+	current := a.loginTokens("ada@example.com")
+
+	if status, problem := a.changePassword(current.access(), testPassword); status != http.StatusCreated {
+		t.Fatalf("status %d code %q, want %d", status, problem.Code, http.StatusCreated)
+	}
+	a.assertSignedOut("other session", other)
+	a.assertSignedIn("current session", current)
+	if status, _, _ := a.refresh(current.RefreshToken); status != http.StatusOK {
+		t.Errorf("refreshing the current session: status %d, want %d", status, http.StatusOK)
+	}
+
+	var problem middleware.Problem
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": "ada@example.com", "password": testPassword}, &problem); status != http.StatusUnauthorized {
+		t.Errorf("login with the old password: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := a.do(http.MethodPost, "/api/v1/auth/login", "", fiber.Map{"email": "ada@example.com", "password": newPassword}, nil); status != http.StatusOK {
+		t.Errorf("login with the new password: status %d, want %d", status, http.StatusOK)
+	}
+}
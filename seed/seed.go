@@ -0,0 +1,230 @@
+// Package seed loads demo data from JSON fixtures into the stores. A fixtures
+// directory may hold users.json, tours.json, bookings.json and reviews.json,
+// each holding a JSON array; missing files are skipped.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tabed23/travel-api/models"
+	"github.com/tabed23/travel-api/repository/store"
+	"github.com/tabed23/travel-api/utils/constant"
+	"github.com/tabed23/travel-api/utils/errors"
+)
+
+// User is a user fixture, the password is given in clear text
+type User struct {
+	models.UserCreate
+}
+
+// Tour is a tour fixture with its departures
+type Tour struct {
+	models.TourInput
+	Departures []models.UpdateDeparture `json:"departures" validate:"dive"`
+}
+
+// Booking is a booking fixture, it refers to its user by email and to its
+// departure by tour title and position in the tour departures
+type Booking struct {
+	UserEmail string               `json:"user_email" validate:"required,email"`
+	Tour      string               `json:"tour" validate:"required"`
+	Departure int                  `json:"departure" validate:"gte=0"`
+	GuestSize int64                `json:"guest_size" validate:"required,gt=0"`
+	Phone     models.Phone         `json:"phone" validate:"required,e164"`
+	Status    models.BookingStatus `json:"status" validate:"omitempty,oneof=held confirmed cancelled completed"`
+}
+
+// Review is a review fixture of the tour with the given title
+type Review struct {
+	models.ReviewInput
+	Tour      string `json:"tour" validate:"required"`
+	UserEmail string `json:"user_email" validate:"omitempty,email"`
+}
+
+// Fixtures is the content of a fixtures directory
+type Fixtures struct {
+	Users    []User
+	Tours    []Tour
+	Bookings []Booking
+	Reviews  []Review
+}
+
+// Summary counts what Apply created and skipped
+type Summary struct {
+	Users, Tours, Departures, Bookings, Reviews int
+	Skipped                                     int
+}
+
+// Load reads and validates the fixtures of dir
+func Load(dir string) (*Fixtures, error) {
+	f := &Fixtures{}
+	files := []struct {
+		name string
+		into interface{}
+	}{
+		{"users.json", &f.Users},
+		{"tours.json", &f.Tours},
+		{"bookings.json", &f.Bookings},
+		{"reviews.json", &f.Reviews},
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, file.into); err != nil {
+			return nil, fmt.Errorf("%v: %w", file.name, err)
+		}
+	}
+	v := validator.New()
+	for i, u := range f.Users {
+		if err := v.Struct(u); err != nil {
+			return nil, fmt.Errorf("users.json[%d]: %w", i, err)
+		}
+	}
+	for i, t := range f.Tours {
+		if err := v.Struct(t); err != nil {
+			return nil, fmt.Errorf("tours.json[%d]: %w", i, err)
+		}
+	}
+	for i, b := range f.Bookings {
+		if err := v.Struct(b); err != nil {
+			return nil, fmt.Errorf("bookings.json[%d]: %w", i, err)
+		}
+	}
+	for i, r := range f.Reviews {
+		if err := v.Struct(r); err != nil {
+			return nil, fmt.Errorf("reviews.json[%d]: %w", i, err)
+		}
+	}
+	return f, nil
+}
+
+// Apply creates the fixtures in s. Users and tours that already exist are
+// kept as they are and the departures, bookings and reviews of existing
+// tours are skipped, so seeding the same fixtures twice is harmless.
+func Apply(ctx context.Context, s store.Stores, f *Fixtures, l *slog.Logger) (Summary, error) {
+	var sum Summary
+	users := map[string]models.User{}
+	for _, u := range f.Users {
+		usr := u.User()
+		usr.Role = u.Role
+		if usr.Role == "" {
+			usr.Role = constant.UserRole
+		}
+		created, err := s.Users.CreaterUser(ctx, usr)
+		if errors.Is(err, errors.ErrUserExists) {
+			if created, err = s.Users.GetUserByEmail(ctx, usr.Email); err != nil {
+				return sum, err
+			}
+			sum.Skipped++
+		} else if err != nil {
+			return sum, fmt.Errorf("user %v: %w", usr.Email, err)
+		} else {
+			sum.Users++
+		}
+		users[created.Email] = created
+	}
+
+	// departures of the tours created now, by tour title
+	departures := map[string][]models.Departure{}
+	tours := map[string]models.Tour{}
+	for _, t := range f.Tours {
+		tour, err := s.Tours.CreateTour(ctx, t.Tour())
+		if errors.Is(err, errors.ErrTourExists) {
+			l.Info("seed", "Apply", fmt.Sprintf("tour %q exists, skipping it", t.Title))
+			sum.Skipped++
+			continue
+		}
+		if err != nil {
+			return sum, fmt.Errorf("tour %q: %w", t.Title, err)
+		}
+		sum.Tours++
+		tours[tour.Title] = tour
+		for _, d := range t.Departures {
+			dep, err := s.Departures.Create(ctx, tour.ID.Hex(), d.Departure())
+			if err != nil {
+				return sum, fmt.Errorf("departure of %q: %w", t.Title, err)
+			}
+			sum.Departures++
+			departures[tour.Title] = append(departures[tour.Title], dep)
+		}
+	}
+
+	for _, b := range f.Bookings {
+		deps, ok := departures[b.Tour]
+		if !ok {
+			sum.Skipped++
+			continue
+		}
+		if b.Departure >= len(deps) {
+			return sum, fmt.Errorf("booking of %v: tour %q has no departure %d", b.UserEmail, b.Tour, b.Departure)
+		}
+		usr, err := seededUser(ctx, s, users, b.UserEmail)
+		if err != nil {
+			return sum, fmt.Errorf("booking of %v: %w", b.UserEmail, err)
+		}
+		input := models.BookingInput{DepartureID: deps[b.Departure].ID, GuestSize: b.GuestSize, Phone: b.Phone}
+		booking, err := s.Bookings.CreateBooking(ctx, usr.ID.Hex(), input.Booking())
+		if err != nil {
+			return sum, fmt.Errorf("booking of %v: %w", b.UserEmail, err)
+		}
+		if err := transition(ctx, s.Bookings, booking, b.Status); err != nil {
+			return sum, fmt.Errorf("booking of %v: %w", b.UserEmail, err)
+		}
+		sum.Bookings++
+	}
+
+	for _, r := range f.Reviews {
+		tour, ok := tours[r.Tour]
+		if !ok {
+			sum.Skipped++
+			continue
+		}
+		review := r.Review()
+		review.UserEmail = r.UserEmail
+		if _, err := s.Reviews.CreateReviw(ctx, tour.ID.Hex(), review); err != nil {
+			return sum, fmt.Errorf("review of %q: %w", r.Tour, err)
+		}
+		sum.Reviews++
+	}
+	return sum, nil
+}
+
+func seededUser(ctx context.Context, s store.Stores, users map[string]models.User, email string) (models.User, error) {
+	if usr, ok := users[email]; ok {
+		return usr, nil
+	}
+	return s.Users.GetUserByEmail(ctx, email)
+}
+
+// transition moves a new booking to status through the allowed transitions
+func transition(ctx context.Context, bookings store.BookingRepository, b models.Booking, status models.BookingStatus) error {
+	var path []models.BookingStatus
+	switch status {
+	case "", b.Status:
+	case models.BookingCompleted:
+		path = []models.BookingStatus{models.BookingConfirmed, models.BookingCompleted}
+	default:
+		path = []models.BookingStatus{status}
+	}
+	for _, to := range path {
+		if b.Status == to {
+			continue
+		}
+		var err error
+		if b, err = bookings.Transition(ctx, b.ID.Hex(), to, "seed", "loaded from fixtures"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
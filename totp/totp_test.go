@@ -0,0 +1,97 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tabed23/travel-api/totp"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, the ASCII string
+// 12345678901234567890, base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The test vectors of RFC 6238 appendix B for SHA1, cut to the last six of
+// their eight digits
+func TestCodeRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("T=%d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("T=%d: code %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseAndPaddedSecrets(t *testing.T) {
+	want, _ := totp.Code(rfcSecret, 1)
+	for _, secret := range []string{"gezdgnbvgy3tqojqgezdgnbvgy3tqojq", rfcSecret + "===="} {
+		if got, err := totp.Code(secret, 1); err != nil || got != want {
+			t.Errorf("%s: code %s, %v, want %s", secret, got, err, want)
+		}
+	}
+	if _, err := totp.Code("not base32!", 1); err == nil {
+		t.Errorf("an invalid secret gave a code")
+	}
+}
+
+func TestValidateReturnsStepWithinSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totp.Step(now)
+
+	for offset := int64(-totp.Skew - 1); offset <= totp.Skew+1; offset++ {
+		code, _ := totp.Code(rfcSecret, current+offset)
+		step, ok := totp.Validate(rfcSecret, code, now)
+		inWindow := offset >= -totp.Skew && offset <= totp.Skew
+		if ok != inWindow {
+			t.Errorf("offset %d: accepted %v, want %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("offset %d: step %d, want %d", offset, step, current+offset)
+		}
+	}
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := totp.Validate(rfcSecret, code, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q of %d characters, want 160 bits in 32", secret, len(secret))
+	}
+	if other, _ := totp.GenerateSecret(); other == secret {
+		t.Errorf("two secrets are the same")
+	}
+
+	u, err := url.Parse(totp.URI("Travel API", "ada@example.com", secret))
+	if err != nil {
+		t.Fatalf("URI: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Travel API:ada@example.com" {
+		t.Errorf("URI %v, want otpauth://totp/Travel API:ada@example.com", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != secret || q.Get("issuer") != "Travel API" || q.Get("digits") != "6" || q.Get("period") != "30" || q.Get("algorithm") != "SHA1" {
+		t.Errorf("URI parameters %v", q)
+	}
+}
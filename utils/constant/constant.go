@@ -1,8 +1,44 @@
 package constant
 
-
 const (
 	AdminRole string = "admin"
 	UserRole  string = "user"
 	GuestRole string = "guest"
 )
+
+const (
+	PermToursRead     string = "tours:read"
+	PermToursWrite    string = "tours:write"
+	PermReviewsRead   string = "reviews:read"
+	PermReviewsWrite  string = "reviews:write"
+	PermBookingsRead  string = "bookings:read"
+	PermBookingsWrite string = "bookings:write"
+	PermUsersRead     string = "users:read"
+	PermUsersWrite    string = "users:write"
+)
+
+// RolePermissions lists what each role is allowed to do. Guests are anonymous
+// or unprivileged callers that may only browse the catalogue.
+var RolePermissions = map[string][]string{
+	GuestRole: {PermToursRead, PermReviewsRead},
+	UserRole: {PermToursRead, PermReviewsRead, PermReviewsWrite,
+		PermBookingsRead, PermBookingsWrite, PermUsersRead, PermUsersWrite},
+	AdminRole: {PermToursRead, PermToursWrite, PermReviewsRead, PermReviewsWrite,
+		PermBookingsRead, PermBookingsWrite, PermUsersRead, PermUsersWrite},
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants perm
+func HasPermission(role, perm string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,162 @@
+// Package errors defines the domain errors of the service. Every error has a
+// Kind the HTTP layer maps to a status code and a stable Code clients can
+// rely on, unlike the message which may change.
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an Error
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindPaymentRequired
+	KindUpstream
+	KindTooManyRequests
+)
+
+// FieldError describes why one field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+// Validation returns an error listing the invalid fields of a request
+func Validation(fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "request validation failed", Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so wrapped copies of a sentinel
+// still match it
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// Withf returns a copy of e with a more specific message
+func (e *Error) Withf(format string, args ...any) *Error {
+	c := *e
+	c.Message = fmt.Sprintf(format, args...)
+	return &c
+}
+
+// KindOf returns the kind of the first Error in err's chain
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// Is and As forward to the standard library so callers need one import
+func Is(err, target error) bool { return errors.Is(err, target) }
+
+func As(err error, target any) bool { return errors.As(err, target) }
+
 var (
-	ErrUnAuthorized = errors.New("unAuthorized user")
-	ErrInValidRole = errors.New("invalid role")
-	ErrInvalidPassword = errors.New("invalid password")
-	ErrUserNotFound = errors.New("user not found")
-	ErrBadRequest = errors.New("bad request")
-	ErrInternalServerError = errors.New("internal server error")
-)
\ No newline at end of file
+	ErrUnAuthorized         = New(KindUnauthorized, "unauthorized", "unauthorized user")
+	ErrInvalidToken         = New(KindUnauthorized, "invalid_token", "invalid or expired token")
+	ErrInValidRole          = New(KindValidation, "invalid_role", "invalid role")
+	ErrInvalidPassword      = New(KindUnauthorized, "invalid_password", "invalid password")
+	ErrUserNotFound         = NotFound("user_not_found", "user not found")
+	ErrBadRequest           = New(KindBadRequest, "bad_request", "bad request")
+	ErrInternalServerError  = New(KindInternal, "internal_error", "internal server error")
+	ErrInvalidRefreshToken  = New(KindUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused   = New(KindUnauthorized, "refresh_token_reused", "refresh token reused")
+	ErrForbidden            = Forbidden("forbidden", "forbidden")
+	ErrTourNotFound         = NotFound("tour_not_found", "tour not found")
+	ErrBookingNotFound      = NotFound("booking_not_found", "booking not found")
+	ErrReviewNotFound       = NotFound("review_not_found", "review not found")
+	ErrDepartureNotFound    = NotFound("departure_not_found", "departure not found")
+	ErrNoSeatsAvailable     = Conflict("no_seats_available", "not enough seats available")
+	ErrDepartureHasSeats    = Conflict("departure_has_seats", "departure has seats held or sold")
+	ErrInvalidTransition    = Conflict("invalid_transition", "invalid booking status transition")
+	ErrPaymentNotFound      = NotFound("payment_not_found", "payment not found")
+	ErrPaymentNotPending    = Conflict("payment_not_pending", "payment is not pending")
+	ErrPaymentDeclined      = New(KindPaymentRequired, "payment_declined", "payment declined")
+	ErrPaymentGateway       = New(KindUpstream, "payment_gateway_error", "payment provider error")
+	ErrRefundPending        = New(KindUpstream, "refund_pending", "the booking was cancelled but its refund failed, it will be retried")
+	ErrInvalidSignature     = New(KindBadRequest, "invalid_signature", "invalid webhook signature")
+	ErrHoldExpired          = Conflict("hold_expired", "booking hold expired")
+	ErrDuplicate            = Conflict("duplicate", "resource already exists")
+	ErrUserExists           = Conflict("user_exists", "a user with this email already exists")
+	ErrTourExists           = Conflict("tour_exists", "a tour with this title already exists")
+	ErrRateLimited          = New(KindTooManyRequests, "rate_limited", "too many requests, retry later")
+	ErrInvalidCredentials   = New(KindUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrLoginLocked          = New(KindTooManyRequests, "login_locked", "too many failed logins, retry later")
+	ErrInvalidAccountToken  = New(KindBadRequest, "invalid_account_token", "invalid, used or expired token")
+	ErrEmailUnverified      = Forbidden("email_unverified", "verify your email address first")
+	ErrEmailVerified        = Conflict("email_already_verified", "email address already verified")
+	ErrInvalidMFACode       = New(KindUnauthorized, "invalid_mfa_code", "invalid or already used authentication code")
+	ErrInvalidMFAToken      = New(KindUnauthorized, "invalid_mfa_token", "invalid or expired mfa token")
+	ErrMFANotEnrolled       = Conflict("mfa_not_enrolled", "two-factor authentication is not set up")
+	ErrMFAEnabled           = Conflict("mfa_enabled", "two-factor authentication is already enabled")
+	ErrMFARequired          = Forbidden("mfa_required", "two-factor authentication is required for this account")
+	ErrInvalidAPIKey        = New(KindUnauthorized, "invalid_api_key", "invalid, revoked or expired API key")
+	ErrAPIKeyNotFound       = NotFound("api_key_not_found", "API key not found")
+	ErrAPIKeyScope          = Forbidden("api_key_scope", "the scopes of the API key do not allow this")
+	ErrAPIKeyIPDenied       = Forbidden("api_key_ip_denied", "API key not allowed from this address")
+	ErrIdentityNotFound     = NotFound("identity_not_found", "identity not found")
+	ErrOIDCProviderNotFound = NotFound("oidc_provider_not_found", "unknown identity provider")
+	ErrInvalidOIDCState     = New(KindBadRequest, "invalid_oidc_state", "invalid or expired sign-in, start it again")
+	ErrOIDCLoginFailed      = New(KindUnauthorized, "oidc_login_failed", "the identity provider did not sign the user in")
+	ErrOIDCEmailUnverified  = Forbidden("oidc_email_unverified", "the identity provider did not verify the email address")
+	ErrOIDCProvider         = New(KindUpstream, "oidc_provider_error", "identity provider error")
+	ErrSessionNotFound      = NotFound("session_not_found", "session not found")
+	ErrSessionRevoked       = New(KindUnauthorized, "session_revoked", "the session was signed out, log in again")
+	ErrGroupTooLarge        = Validation(FieldError{Field: "guest_size", Code: "lte", Message: "guest_size must not exceed the tour maximum group size"})
+)
@@ -1,24 +1,43 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	fiberutils "github.com/gofiber/fiber/v2/utils"
 	"github.com/golang-jwt/jwt"
+	"github.com/tabed23/travel-api/keyring"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Claims struct {
 	Role  string `json:"role"`
 	Email string `json:"email"`
+	// MFA marks the short lived tokens of a login waiting for its second
+	// factor, one of the MFA stages. They are no access tokens.
+	MFA string `json:"mfa,omitempty"`
+	// Session is the id of the session of the login an access token was
+	// issued to, tokens of revoked sessions are refused
+	Session string `json:"sid,omitempty"`
+	// APIKey is the prefix of the API key of callers authenticated by one,
+	// they only get the permissions of its Scopes. Tokens never carry them.
+	APIKey string   `json:"-"`
+	Scopes []string `json:"-"`
 	jwt.StandardClaims
 }
 
-var (
-	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// Stages of a login waiting for its second factor
+const (
+	// MFAVerify waits for a code of the enabled second factor
+	MFAVerify = "verify"
+	// MFAEnroll waits for a second factor the account must have to be set up
+	MFAEnroll = "enroll"
 )
 
 func EnscryptPassword(password string) (string, error) {
@@ -33,36 +52,118 @@ func VerifyPassword(hash, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func GenrateNewToken(role, email string) (string, error) {
-	claims := Claims{
-		Role:  role,
-		Email: email,
-		StandardClaims: jwt.StandardClaims{
-			Id:        email,
-			Issuer:    email,
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(time.Minute * 30).Unix(), // 30 mint expiration time
-		},
+// JWT issues and verifies the access tokens of the service. Tokens are
+// signed by the current key of the keyring and name it in their kid header,
+// they only verify with the key they name and its algorithm.
+type JWT struct {
+	keys       *keyring.Keyring
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewJWT(keys *keyring.Keyring, issuer, audience string, accessTTL, refreshTTL time.Duration) *JWT {
+	return &JWT{keys: keys, issuer: issuer, audience: audience, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// AccessTTL returns how long access tokens are valid
+func (j *JWT) AccessTTL() time.Duration {
+	return j.accessTTL
+}
+
+// RefreshTTL returns how long refresh tokens are valid
+func (j *JWT) RefreshTTL() time.Duration {
+	return j.refreshTTL
+}
+
+// GenrateNewToken returns a signed access token for a user in session
+func (j *JWT) GenrateNewToken(role, email, session string) (string, error) {
+	return j.sign(Claims{Role: role, Email: email, Session: session}, j.accessTTL)
+}
+
+// sign completes the registered claims of claims, valid for ttl, and signs
+// them with the current key
+func (j *JWT) sign(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	key, err := j.keys.Signer(now)
+	if err != nil {
+		return "", err
+	}
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        fiberutils.UUIDv4(),
+		Issuer:    j.issuer,
+		Audience:  j.audience,
+		Subject:   claims.Email,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
 	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+// ParseToken verifies an access token, mfa pending tokens are refused
+func (j *JWT) ParseToken(tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.MFA != "" {
+		return nil, fmt.Errorf("mfa pending token is no access token")
+	}
+	return claims, nil
+}
 
+// GenerateMFAToken returns a token of a login of email that passed the
+// password check and waits for the second factor in stage
+func (j *JWT) GenerateMFAToken(email, stage string, ttl time.Duration) (string, error) {
+	return j.sign(Claims{Email: email, MFA: stage}, ttl)
 }
 
-func ParseToken(tokenString string) (*Claims, error) {
+// ParseMFAToken verifies a token of GenerateMFAToken of stage
+func (j *JWT) ParseMFAToken(tokenString, stage string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.MFA != stage {
+		return nil, fmt.Errorf("not an mfa %v token", stage)
+	}
+	return claims, nil
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+// parse verifies the signature, algorithm, issuer, audience and lifetime
+// of a token
+func (j *JWT) parse(tokenString string) (*Claims, error) {
+	parser := jwt.Parser{ValidMethods: keyring.Algorithms}
+	token, err := parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.Lookup(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("key %v does not sign %v", kid, token.Method.Alg())
+		}
+		return key.Public(), nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
-	var ok bool
 	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid Token: %v", tokenString)
+		return nil, fmt.Errorf("invalid token")
+	}
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if !claims.VerifyIssuer(j.issuer, true) {
+		return nil, fmt.Errorf("token issued by %q", claims.Issuer)
+	}
+	if !claims.VerifyAudience(j.audience, true) {
+		return nil, fmt.Errorf("token is for %q", claims.Audience)
 	}
 	return claims, nil
 }
@@ -77,3 +178,41 @@ func ExtractToken(c *fiber.Ctx) string {
 
 	return ""
 }
+
+// GenerateRefreshToken returns a random opaque refresh token
+func GenerateRefreshToken() (string, error) {
+	return GenerateToken()
+}
+
+// GenerateToken returns a random URL safe token of 256 bits
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot
+const APIKeyPrefix = "tak_"
+
+// GenerateAPIKey returns a random API key and its prefix, the part of the key
+// shown to tell keys apart
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret, err := GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(b)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token, used to store tokens at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}